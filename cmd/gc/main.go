@@ -0,0 +1,80 @@
+// Команда сверки хранилища файлов с таблицей images: выводит файлы без записей и записи без файлов,
+// с -delete удаляет найденные файлы без записей. Читает те же .env и config/local.yaml, что и сервис
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/janitor"
+	"imageProcessor/internal/storage/db"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	remove := flag.Bool("delete", false, "remove orphan files; without it orphan files and missing files are only reported")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	wbzlog.Init()
+	if err := run(!*remove, *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, "gc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dryRun, asJSON bool) error {
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return err
+	}
+	postgres, err := db.NewPostgres(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = postgres.Close()
+	}()
+	blobs, err := blob.NewStores(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := janitor.NewCollector(postgres, blobs, cfg).Collect(ctx, dryRun)
+	if report != nil {
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(report)
+		} else {
+			printReport(report)
+		}
+	}
+	return err
+}
+
+func printReport(report *janitor.GCReport) {
+	for _, key := range report.OrphanOriginals {
+		fmt.Println("orphan original", key)
+	}
+	for _, key := range report.OrphanOutputs {
+		fmt.Println("orphan processed", key)
+	}
+	for _, m := range report.Missing {
+		fmt.Println("missing", m.Kind, m.ImageID, m.Status, m.Key)
+	}
+	fmt.Printf("orphans: %d originals, %d processed; removed: %d; rows with missing files: %d",
+		len(report.OrphanOriginals), len(report.OrphanOutputs), report.Removed, len(report.Missing))
+	if report.DryRun {
+		fmt.Print(" (dry run)")
+	}
+	fmt.Println()
+}
@@ -9,11 +9,19 @@ import (
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"go.uber.org/fx"
 	"imageProcessor/internal/app"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/broker/kafka_producer"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/di"
+	"imageProcessor/internal/janitor"
+	"imageProcessor/internal/oidc"
+	"imageProcessor/internal/pubsub"
+	"imageProcessor/internal/ratelimit"
 	"imageProcessor/internal/storage/db"
+	"imageProcessor/internal/tus"
+	"imageProcessor/internal/urlsign"
 	"imageProcessor/internal/web"
+	"imageProcessor/internal/webhook"
 )
 
 func main() {
@@ -26,23 +34,79 @@ func main() {
 			func(db *db.Postgres) app.StorageProvider {
 				return db
 			},
+			func(db *db.Postgres) webhook.DeliveryStorage {
+				return db
+			},
+			func(db *db.Postgres) janitor.OriginalStorage {
+				return db
+			},
+			func(db *db.Postgres) janitor.TrashStorage {
+				return db
+			},
+			func(db *db.Postgres) janitor.GCStorage {
+				return db
+			},
+			func(db *db.Postgres) app.APIKeyStorage {
+				return db
+			},
+			func(cfg *config.AppConfig, db *db.Postgres) (ratelimit.Store, error) {
+				return ratelimit.NewStore(cfg, db)
+			},
+			ratelimit.NewLimiter,
 
 			kafkaproducer.NewKafkaProducer,
 			func(kafka *kafkaproducer.KafkaProducerService) app.BrokerProvider {
 				return kafka
 			},
+			pubsub.NewHub,
+			func(kafka *kafkaproducer.KafkaProducerService, hub *pubsub.Hub) app.EventPublisher {
+				return app.EventPublishers{kafka, hub}
+			},
+			func(hub *pubsub.Hub) web.StatusSubscriber {
+				return hub
+			},
 
+			blob.NewStores,
 			app.NewImageService,
 
 			func(service *app.ImageService) web.ImageProcessorProvider {
 				return service
 			},
+			func(service *app.ImageService) janitor.ImagePurger {
+				return service
+			},
 			web.NewCommentHandler,
+			app.NewAPIKeyService,
+			func(service *app.APIKeyService) web.KeyProvider {
+				return service
+			},
+			web.NewKeyHandler,
+			oidc.NewVerifier,
+			urlsign.NewSigner,
+			func(signer *urlsign.Signer) web.URLSigner {
+				return signer
+			},
+			web.NewSignedURLHandler,
+			tus.NewStore,
+			func(store *tus.Store) web.ResumableStore {
+				return store
+			},
+			web.NewTusHandler,
+			webhook.NewDispatcher,
+			janitor.NewJanitor,
+			janitor.NewTrash,
+			janitor.NewCollector,
 		),
 		fx.Invoke(
 			di.StartHTTPServer,
 			di.StartKafkaProducer,
 			di.StartKafkaConsumer,
+			di.StartWebhookDispatcher,
+			di.StartRetentionJanitor,
+			di.StartTrashPurger,
+			di.StartOrphanCollector,
+			di.StartRateLimitSweeper,
+			di.StartTusSweeper,
 			di.ClosePostgresOnStop,
 		),
 	)
@@ -15,9 +15,50 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/batch/{id}": {
+            "get": {
+                "description": "Возвращает агрегированный прогресс обработки всех изображений пакета",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Batches"
+                ],
+                "summary": "Прогресс пакета",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.BatchProgress"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/image/{id}": {
             "get": {
-                "description": "Возвращает обработанное изображение, если оно готово, иначе — статус обработки",
+                "description": "Возвращает обработанное изображение, если оно готово, иначе — статус обработки; variant=original отдаёт исходник. Открывается без токена по подписанной ссылке (POST /api/image/{id}/signed-url), с signed_urls.required — только по ней или с токеном арендатора",
                 "produces": [
                     "application/json"
                 ],
@@ -32,11 +73,47 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "processed (default) or original",
+                        "name": "variant",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Signed URL expiry, unix seconds",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signing key ID",
+                        "name": "kid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signed URL signature",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Processed image file",
+                        "description": "Processed image file with ETag, Last-Modified and Cache-Control",
                         "schema": {
                             "type": "file"
                         }
@@ -47,6 +124,36 @@ const docTemplate = `{
                             "$ref": "#/definitions/web.ImageResponse"
                         }
                     },
+                    "206": {
+                        "description": "Requested byte range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature, or signature required",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -56,7 +163,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Удаляет изображение из хранилища (помечает, как удаленное)",
+                "description": "Перемещает изображение в корзину: в течение trash.period его можно восстановить, затем файлы и запись удаляются. С hard=true файлы и запись удаляются сразу, в том числе из корзины",
                 "tags": [
                     "Images"
                 ],
@@ -68,6 +175,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete files and record immediately",
+                        "name": "hard",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -77,6 +190,24 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Image already deleted",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -86,59 +217,159 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/upload": {
+        "/api/image/{id}/cancel": {
             "post": {
-                "description": "Загружает изображение и ставит его на обработку (ресайз, миниатюра, водяной знак)",
-                "consumes": [
-                    "multipart/form-data"
+                "description": "Отменяет обработку изображения, ожидающего в очереди или обрабатываемого в данный момент",
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Отмена обработки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Processing already finished",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/image/{id}/events": {
+            "get": {
+                "description": "Отправляет текущий статус и далее каждую смену статуса как событие ` + "`" + `status` + "`" + `, закрывает поток после конечного статуса",
                 "produces": [
-                    "application/json"
+                    "text/event-stream"
                 ],
                 "tags": [
                     "Images"
                 ],
-                "summary": "Загрузка изображения",
+                "summary": "Поток статусов изображения (SSE)",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "Image file",
-                        "name": "file",
-                        "in": "formData",
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of status events",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StatusEvent"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/image/{id}/file": {
+            "get": {
+                "description": "Возвращает обработанное изображение; пока обработка не завершена — 409 с текущим статусом. Поддерживает If-None-Match, If-Modified-Since и Range. С signed_urls.required без токена арендатора отвечает 403",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Файл обработанного изображения",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Watermark text",
-                        "name": "watermark",
-                        "in": "formData"
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Resize in format WIDTHxHEIGHT, e.g., 500x500",
-                        "name": "resize",
-                        "in": "formData"
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     },
                     {
                         "type": "string",
-                        "description": "Generate thumbnail, 1 = true, 0 = false",
-                        "name": "mini",
-                        "in": "formData"
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Processed image file with ETag, Last-Modified and Cache-Control",
                         "schema": {
-                            "$ref": "#/definitions/web.ImageResponse"
+                            "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "206": {
+                        "description": "Requested byte range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Signature required",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/web.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Image is not processed",
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageStatusError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -147,36 +378,1927 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "web.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "invalid input data"
+        },
+        "/api/image/{id}/meta": {
+            "get": {
+                "description": "Всегда возвращает JSON: статус, размеры и объём исходника и результата, формат, операции, временные метки, причину ошибки и ссылки на файлы",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Метаданные изображения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageMetaResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "web.ImageResponse": {
-            "type": "object",
-            "properties": {
-                "ID": {
-                    "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174000"
-                },
-                "Name": {
-                    "type": "string",
-                    "example": "example.png"
-                },
-                "Status": {
-                    "type": "string",
-                    "example": "Processing"
-                },
-                "URL": {
-                    "type": "string",
-                    "example": "/data_img/processed/example.png"
+        "/api/image/{id}/original": {
+            "get": {
+                "description": "Возвращает загруженный исходник. Без аутентификации арендаторов требует токен ORIGINALS_TOKEN в заголовке Authorization, с ней — токен арендатора-владельца; исходник, удалённый по политике хранения, отдаёт 410",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Исходный файл изображения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003cORIGINALS_TOKEN\u003e or tenant token or API key",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Original image file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested byte range",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Original download is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Original removed by retention policy",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/image/{id}/restore": {
+            "post": {
+                "description": "Возвращает удалённое изображение в статус, в котором оно было удалено; незавершённая обработка запускается заново. После trash.period отвечает 410",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Восстановление изображения из корзины",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageMetaResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Image is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Trash period has expired",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/image/{id}/signed-url": {
+            "post": {
+                "description": "Выпускает ссылку на GET /api/image/{id}, которая открывается без токена до истечения срока. Ссылку можно ограничить вариантом (processed — результат обработки, original — исходник, требует аутентификации арендатора) и IP-адресом клиента. Требует настроенных URL_SIGNING_KEYS и права images:read",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Подписанная ссылка на изображение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Link restrictions",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/web.SignedURLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.SignedURLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/image/{id}/webhooks": {
+            "get": {
+                "description": "Возвращает все попытки доставки webhook по изображению",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Журнал доставки webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Image ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/images": {
+            "get": {
+                "description": "Возвращает изображения с фильтрами и курсорной пагинацией по (created_at, id). Удалённые изображения возвращаются только при явном status=deleted. Для следующей страницы передайте next_cursor с теми же фильтрами и сортировкой.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Список изображений",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Statuses, repeated or comma-separated",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Formats, repeated or comma-separated (jpg and jpeg are the same)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after, RFC3339",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before, RFC3339",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag the image must have",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner label",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "-created_at",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Sort order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, max 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ImagePage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/keys": {
+            "get": {
+                "description": "Возвращает ключи арендатора запроса, в том числе отозванные, с префиксом и временем последнего использования (с точностью до минуты). Требует права admin",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Keys"
+                ],
+                "summary": "Список ключей API",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.APIKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создаёт ключ арендатора запроса с указанными правами (images:read, images:write, images:delete, admin). Сам ключ возвращается только в этом ответе, сервис хранит лишь его хэш. Требует права admin",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Keys"
+                ],
+                "summary": "Выпуск ключа API",
+                "parameters": [
+                    {
+                        "description": "Key name and scopes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/web.CreateKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/web.CreateKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/keys/{id}": {
+            "delete": {
+                "description": "Отзывает ключ арендатора запроса; следующие запросы с ним получают 401. Требует права admin",
+                "tags": [
+                    "Keys"
+                ],
+                "summary": "Отзыв ключа API",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stream-ticket": {
+            "post": {
+                "description": "Выпускает короткоживущий билет для /api/ws и /api/image/{id}/events: браузер не может передать Authorization в WebSocket и EventSource, поэтому билет передаётся параметрами запроса. Билет действует минуту, уже открытый поток живёт дольше. Требует настроенных URL_SIGNING_KEYS и права images:read",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Билет на потоки статусов",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.StreamTicketResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tus/": {
+            "post": {
+                "description": "Создаёт загрузку заданного размера. Upload-Metadata: filename (обязательно), resize, mini, watermark, callback_url, priority, tags, owner — значения в base64. Адрес загрузки возвращается в Location.",
+                "tags": [
+                    "Tus"
+                ],
+                "summary": "Создание возобновляемой загрузки (tus)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "1.0.0",
+                        "description": "Protocol version",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Total upload size in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated 'key base64value' pairs, filename is required",
+                        "name": "Upload-Metadata",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created, see Location and Upload-Expires headers",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Unsupported tus version",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Upload-Length exceeds Tus-Max-Size",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported file extension",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Tenant quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    }
+                }
+            },
+            "options": {
+                "description": "Возвращает поддерживаемую версию протокола, расширения и максимальный размер загрузки",
+                "tags": [
+                    "Tus"
+                ],
+                "summary": "Возможности tus-сервера",
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tus/{id}": {
+            "delete": {
+                "description": "Удаляет незавершённую загрузку и полученные байты",
+                "tags": [
+                    "Tus"
+                ],
+                "summary": "Отмена загрузки (tus)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "1.0.0",
+                        "description": "Protocol version",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Upload is being written by another request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Возвращает количество уже полученных байт в Upload-Offset; после завершения — ID изображения в X-Image-ID",
+                "tags": [
+                    "Tus"
+                ],
+                "summary": "Текущее смещение загрузки (tus)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "1.0.0",
+                        "description": "Protocol version",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload-Offset and Upload-Length headers",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Дописывает тело запроса начиная с Upload-Offset. Когда получен весь файл, изображение ставится на обработку, его ID возвращается в X-Image-ID. Если постановка не удалась, её можно повторить пустым PATCH с конечным смещением.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "Tus"
+                ],
+                "summary": "Загрузка части файла (tus)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "1.0.0",
+                        "description": "Protocol version",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset the chunk starts at",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "New Upload-Offset header",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Upload-Offset or the completed file was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Upload-Offset does not match",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Wrong Content-Type or the completed file is not a supported image",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Upload is being written by another request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Tenant quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/upload": {
+            "post": {
+                "description": "Загружает изображение и ставит его на обработку (ресайз, миниатюра, водяной знак). Формат определяется по содержимому файла и должен совпадать с расширением, если оно указано.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Загрузка изображения",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Image file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Watermark text",
+                        "name": "watermark",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resize in format WIDTHxHEIGHT, e.g., 500x500",
+                        "name": "resize",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Generate thumbnail, 1 = true, 0 = false",
+                        "name": "mini",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "URL to POST a signed webhook to when processing finishes or fails",
+                        "name": "callback_url",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "interactive",
+                            "bulk"
+                        ],
+                        "type": "string",
+                        "description": "Processing lane: interactive (default) or bulk",
+                        "name": "priority",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated tags, up to 20",
+                        "name": "tags",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner label used for filtering",
+                        "name": "owner",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters, corrupted image or extension/content mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "File exceeds upload.max_bytes or image dimensions exceed limits",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Content is not a supported image",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or concurrent upload limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Tenant quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/upload/batch": {
+            "post": {
+                "description": "Загружает несколько файлов с общими параметрами; параметры отдельных файлов можно переопределить JSON-массивом params (по порядку файлов). Ошибка одного файла не мешает остальным.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Batches"
+                ],
+                "summary": "Пакетная загрузка изображений",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Image files (repeat the field for each file)",
+                        "name": "files",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared watermark text",
+                        "name": "watermark",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared resize in format WIDTHxHEIGHT",
+                        "name": "resize",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared thumbnail flag, 1 = true, 0 = false",
+                        "name": "mini",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared webhook URL",
+                        "name": "callback_url",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared processing lane: interactive or bulk",
+                        "name": "priority",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared comma-separated tags",
+                        "name": "tags",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shared owner label",
+                        "name": "owner",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON array of per-file overrides, e.g. [{\\",
+                        "name": "params",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.BatchUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "A file exceeds upload.max_bytes",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or concurrent upload limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/upload/url": {
+            "post": {
+                "description": "Скачивает изображение с указанного адреса (с ограничением размера и времени, только image/png, image/jpeg, image/gif) и ставит его на обработку. Приватные и служебные адреса запрещены, если не перечислены в fetch.allowlist.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Загрузка изображения по URL",
+                "parameters": [
+                    {
+                        "description": "Source URL and processing parameters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageReqUploadURL"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/web.ImageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or forbidden source URL",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Source file or image dimensions are too large",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Source is not a supported image",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit or concurrent upload limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Source could not be fetched",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    },
+                    "507": {
+                        "description": "Tenant quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/web.UploadErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/usage": {
+            "get": {
+                "description": "Возвращает число изображений, объём исходников и результатов арендатора запроса и его квоты; 0 в квоте — без ограничения. Изображения в корзине учитываются до окончательного удаления",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Usage"
+                ],
+                "summary": "Использование хранилища",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.TenantUsage"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/web.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ws": {
+            "get": {
+                "description": "Клиент отправляет {\"action\":\"subscribe\",\"ids\":[...]} или unsubscribe, сервер присылает текущий статус каждого нового ID и далее события смены статуса",
+                "tags": [
+                    "Images"
+                ],
+                "summary": "Мультиплексированные статусы изображений (WebSocket)",
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StatusEvent"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "ipk_3q2-7w1Z"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "images:read",
+                        "images:write"
+                    ]
+                },
+                "tenant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "error_code": {
+                    "$ref": "#/definitions/domain.UploadErrorCode"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "image": {
+                    "$ref": "#/definitions/domain.Image"
+                },
+                "index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.BatchProgress": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "integer"
+                },
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "done": {
+                    "type": "boolean"
+                },
+                "finished": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "progress": {
+                    "type": "number"
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "delivered",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "DeliveryPending",
+                "DeliveryDelivered",
+                "DeliveryFailed"
+            ]
+        },
+        "domain.EventType": {
+            "type": "string",
+            "enum": [
+                "image.created",
+                "image.processing",
+                "image.processed",
+                "image.failed",
+                "image.cancelled",
+                "image.deleted"
+            ],
+            "x-enum-varnames": [
+                "EventImageCreated",
+                "EventImageProcessing",
+                "EventImageProcessed",
+                "EventImageFailed",
+                "EventImageCancelled",
+                "EventImageDeleted"
+            ]
+        },
+        "domain.FileInfo": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size_bytes": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.Image": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "string"
+                },
+                "callback_url": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt — когда изображение помещено в корзину",
+                    "type": "string"
+                },
+                "error_reason": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "mini": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "original_deleted_at": {
+                    "description": "OriginalDeletedAt — когда исходник удалён по политике хранения",
+                    "type": "string"
+                },
+                "output": {
+                    "$ref": "#/definitions/domain.FileInfo"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "priority": {
+                    "$ref": "#/definitions/domain.Priority"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "resize": {
+                    "$ref": "#/definitions/domain.Resize"
+                },
+                "source": {
+                    "$ref": "#/definitions/domain.FileInfo"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.StatusType"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "watermark": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ImagePage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Image"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Priority": {
+            "type": "string",
+            "enum": [
+                "interactive",
+                "bulk"
+            ],
+            "x-enum-varnames": [
+                "PriorityInteractive",
+                "PriorityBulk"
+            ]
+        },
+        "domain.QuotaLimits": {
+            "type": "object",
+            "properties": {
+                "max_bytes": {
+                    "type": "integer"
+                },
+                "max_images": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.Resize": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.StatusEvent": {
+            "type": "object",
+            "properties": {
+                "event_id": {
+                    "type": "string"
+                },
+                "image_id": {
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "schema_version": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.StatusType"
+                },
+                "type": {
+                    "$ref": "#/definitions/domain.EventType"
+                }
+            }
+        },
+        "domain.StatusType": {
+            "type": "string",
+            "enum": [
+                "created",
+                "processing",
+                "processed",
+                "failed",
+                "cancelled",
+                "deleted"
+            ],
+            "x-enum-varnames": [
+                "Created",
+                "Processing",
+                "Processed",
+                "Failed",
+                "Cancelled",
+                "Deleted"
+            ]
+        },
+        "domain.TenantUsage": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "images": {
+                    "type": "integer"
+                },
+                "limits": {
+                    "$ref": "#/definitions/domain.QuotaLimits"
+                },
+                "original_bytes": {
+                    "type": "integer"
+                },
+                "processed_bytes": {
+                    "type": "integer"
+                },
+                "tenant": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.UploadErrorCode": {
+            "type": "string",
+            "enum": [
+                "invalid_params",
+                "unsupported_media_type",
+                "format_mismatch",
+                "invalid_image",
+                "file_too_large",
+                "image_too_large",
+                "quota_exceeded"
+            ],
+            "x-enum-varnames": [
+                "CodeInvalidParams",
+                "CodeUnsupportedMedia",
+                "CodeFormatMismatch",
+                "CodeInvalidImage",
+                "CodeFileTooLarge",
+                "CodeImageTooLarge",
+                "CodeQuotaExceeded"
+            ]
+        },
+        "domain.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event": {
+                    "$ref": "#/definitions/domain.EventType"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image_id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.DeliveryStatus"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "web.BatchUploadResponse": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.BatchItemResult"
+                    }
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "web.CreateKeyRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "ci-uploader"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "images:read",
+                            "images:write",
+                            "images:delete",
+                            "admin"
+                        ]
+                    },
+                    "example": [
+                        "images:read",
+                        "images:write"
+                    ]
+                }
+            }
+        },
+        "web.CreateKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "ipk_3q2-7w1ZkJ0rQ8xY4mN6bV2cT9aL5eH1uS7dF3gW0pI"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "ipk_3q2-7w1Z"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "images:read",
+                        "images:write"
+                    ]
+                },
+                "tenant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "web.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "invalid input data"
+                }
+            }
+        },
+        "web.ImageLinks": {
+            "type": "object",
+            "properties": {
+                "file": {
+                    "type": "string",
+                    "example": "/api/image/123e4567-e89b-12d3-a456-426614174000/file"
+                },
+                "meta": {
+                    "type": "string",
+                    "example": "/api/image/123e4567-e89b-12d3-a456-426614174000/meta"
+                },
+                "original": {
+                    "type": "string",
+                    "example": "/api/image/123e4567-e89b-12d3-a456-426614174000/original"
+                }
+            }
+        },
+        "web.ImageMetaResponse": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "string"
+                },
+                "callback_url": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt — когда изображение помещено в корзину",
+                    "type": "string"
+                },
+                "error_reason": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "links": {
+                    "$ref": "#/definitions/web.ImageLinks"
+                },
+                "mini": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "watermark",
+                        "resize"
+                    ]
+                },
+                "original_deleted_at": {
+                    "description": "OriginalDeletedAt — когда исходник удалён по политике хранения",
+                    "type": "string"
+                },
+                "output": {
+                    "$ref": "#/definitions/domain.FileInfo"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "priority": {
+                    "$ref": "#/definitions/domain.Priority"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "resize": {
+                    "$ref": "#/definitions/domain.Resize"
+                },
+                "source": {
+                    "$ref": "#/definitions/domain.FileInfo"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.StatusType"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "watermark": {
+                    "type": "string"
+                }
+            }
+        },
+        "web.ImageReqUploadURL": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "callback_url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/image"
+                },
+                "mini": {
+                    "type": "boolean"
+                },
+                "owner": {
+                    "type": "string",
+                    "example": "partner-42"
+                },
+                "priority": {
+                    "type": "string",
+                    "example": "interactive"
+                },
+                "resize": {
+                    "type": "string",
+                    "example": "500x500"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "catalog",
+                        "summer"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://cdn.example.com/images/photo.jpg"
+                },
+                "watermark": {
+                    "type": "string",
+                    "example": "Мой Водяной Знак"
+                }
+            }
+        },
+        "web.ImageResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "Name": {
+                    "type": "string",
+                    "example": "example.png"
+                },
+                "Status": {
+                    "type": "string",
+                    "example": "Processing"
+                },
+                "URL": {
+                    "type": "string",
+                    "example": "/data_img/processed/example.png"
+                }
+            }
+        },
+        "web.ImageStatusError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "image is not processed"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "processing"
+                }
+            }
+        },
+        "web.SignedURLRequest": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "ttl": {
+                    "type": "integer",
+                    "example": 900
+                },
+                "variant": {
+                    "type": "string",
+                    "enum": [
+                        "processed",
+                        "original"
+                    ],
+                    "example": "processed"
+                }
+            }
+        },
+        "web.SignedURLResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string",
+                    "example": "2026-01-01T00:00:00Z"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "http://localhost:8080/api/image/123e4567-e89b-12d3-a456-426614174000?expires=1767225600\u0026kid=2026\u0026sig=Q2hhbmdlTWU"
+                }
+            }
+        },
+        "web.StreamTicketResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string",
+                    "example": "2026-01-01T00:00:00Z"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "expires=1767225600\u0026kid=2026\u0026sig=Q2hhbmdlTWU\u0026tenant=acme"
+                }
+            }
+        },
+        "web.UploadErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "enum": [
+                        "invalid_params",
+                        "unsupported_media_type",
+                        "format_mismatch",
+                        "invalid_image",
+                        "file_too_large",
+                        "image_too_large",
+                        "quota_exceeded"
+                    ],
+                    "example": "format_mismatch"
+                },
+                "detected_format": {
+                    "type": "string",
+                    "example": "jpeg"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "file extension .png does not match content (jpeg)"
                 }
             }
         }
@@ -0,0 +1,80 @@
+package app
+
+import (
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+type APIKeyStorage interface {
+	SaveAPIKey(key *domain.APIKey) error
+	GetAPIKeyByHash(hash string) (*domain.APIKey, error)
+	ListAPIKeys(tenant string) ([]domain.APIKey, error)
+	RevokeAPIKey(tenant, id string) error
+	TouchAPIKey(id string, at time.Time) error
+}
+
+// APIKeyService выпускает, отзывает и проверяет ключи API арендаторов
+type APIKeyService struct {
+	repo APIKeyStorage
+}
+
+func NewAPIKeyService(repo APIKeyStorage) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// CreateAPIKey выпускает ключ арендатора и возвращает его вместе с секретом; секрет больше нигде не сохраняется
+func (s *APIKeyService) CreateAPIKey(tenant, name string, scopes []string) (*domain.APIKey, string, error) {
+	parsed, err := domain.ParseScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	key, secret, err := domain.NewAPIKey(tenant, name, parsed)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.SaveAPIKey(key); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to save api key into storage")
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(tenant string) ([]domain.APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to list api keys from storage")
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(tenant, id string) error {
+	if _, err := idParse(id); err != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+	if err := s.repo.RevokeAPIKey(tenant, id); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to revoke api key")
+		return err
+	}
+	return nil
+}
+
+// AuthenticateKey находит действующий ключ по секрету и отмечает его использование не чаще
+// domain.APIKeyTouchInterval; ошибка отметки не мешает запросу
+func (s *APIKeyService) AuthenticateKey(secret string) (*domain.Principal, error) {
+	if !domain.IsAPIKey(secret) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	key, err := s.repo.GetAPIKeyByHash(domain.HashAPIKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key.NeedsTouch(now) {
+		if err := s.repo.TouchAPIKey(key.ID.String(), now); err != nil {
+			wbzlog.Logger.Warn().Err(err).Str("key_id", key.ID.String()).Msg("Failed to record api key usage")
+		}
+	}
+	return &domain.Principal{Tenant: key.TenantID, Scopes: key.Scopes, KeyID: key.ID.String()}, nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/domain"
+	"testing"
+	"time"
+)
+
+type MockAPIKeyStorage struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyStorage) SaveAPIKey(key *domain.APIKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyStorage) GetAPIKeyByHash(hash string) (*domain.APIKey, error) {
+	args := m.Called(hash)
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStorage) ListAPIKeys(tenant string) ([]domain.APIKey, error) {
+	args := m.Called(tenant)
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStorage) RevokeAPIKey(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyStorage) TouchAPIKey(id string, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+	storage.On("SaveAPIKey", mock.AnythingOfType("*domain.APIKey")).Return(nil)
+
+	key, secret, err := service.CreateAPIKey("acme", "ci", []string{"images:write"})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Scopes{domain.ScopeImagesWrite}, key.Scopes)
+	saved := storage.Calls[0].Arguments.Get(0).(*domain.APIKey)
+	assert.Equal(t, "acme", saved.TenantID)
+	assert.Equal(t, domain.HashAPIKey(secret), saved.Hash)
+}
+
+func TestCreateAPIKey_InvalidScope(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+
+	_, _, err := service.CreateAPIKey("acme", "ci", []string{"images:all"})
+	assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+	storage.AssertNotCalled(t, "SaveAPIKey", mock.Anything)
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+	key, secret, err := domain.NewAPIKey("acme", "ci", domain.Scopes{domain.ScopeImagesRead})
+	assert.NoError(t, err)
+	storage.On("GetAPIKeyByHash", key.Hash).Return(key, nil)
+	storage.On("TouchAPIKey", key.ID.String(), mock.AnythingOfType("time.Time")).Return(nil)
+
+	principal, err := service.AuthenticateKey(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Principal{Tenant: "acme", Scopes: key.Scopes, KeyID: key.ID.String()}, principal)
+	storage.AssertNumberOfCalls(t, "TouchAPIKey", 1)
+}
+
+func TestAuthenticateKey_RecentlyUsed(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+	used := time.Now().Add(-10 * time.Second)
+	key := &domain.APIKey{ID: uuid.New(), TenantID: "acme", Scopes: domain.Scopes{domain.ScopeAdmin}, LastUsedAt: &used}
+	storage.On("GetAPIKeyByHash", domain.HashAPIKey("ipk_0123456789abcdef")).Return(key, nil)
+
+	_, err := service.AuthenticateKey("ipk_0123456789abcdef")
+	assert.NoError(t, err)
+	storage.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateKey_TouchErrorIgnored(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+	key := &domain.APIKey{ID: uuid.New(), TenantID: "acme", Scopes: domain.Scopes{domain.ScopeImagesRead}}
+	storage.On("GetAPIKeyByHash", domain.HashAPIKey("ipk_0123456789abcdef")).Return(key, nil)
+	storage.On("TouchAPIKey", key.ID.String(), mock.Anything).Return(errors.New("db down"))
+
+	principal, err := service.AuthenticateKey("ipk_0123456789abcdef")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", principal.Tenant)
+}
+
+func TestAuthenticateKey_NotAKey(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+
+	_, err := service.AuthenticateKey("some-other-token")
+	assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+	storage.AssertNotCalled(t, "GetAPIKeyByHash", mock.Anything)
+}
+
+func TestRevokeAPIKey_InvalidID(t *testing.T) {
+	storage := new(MockAPIKeyStorage)
+	service := NewAPIKeyService(storage)
+
+	assert.ErrorIs(t, service.RevokeAPIKey("acme", "not-a-uuid"), domain.ErrAPIKeyNotFound)
+	storage.AssertNotCalled(t, "RevokeAPIKey", mock.Anything, mock.Anything)
+}
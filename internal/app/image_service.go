@@ -1,81 +1,220 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"github.com/google/uuid"
 	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
+	"imageProcessor/internal/fetcher"
 	"io"
 	"mime/multipart"
-	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 type ImageService struct {
 	repo     StorageProvider
 	producer BrokerProvider
+	events   EventPublisher
+	blobs    *blob.Stores
+	fetcher  *fetcher.Fetcher
 	config   *config.AppConfig
+
+	jobsMu sync.Mutex
+	// jobs — выполняющиеся в этом процессе задачи по ID изображения; повторно доставленное сообщение
+	// может обрабатываться рядом с прежним, поэтому у каждой задачи свой номер из jobSeq
+	jobs   map[string]map[uint64]context.CancelFunc
+	jobSeq uint64
 }
 
 type StorageProvider interface {
-	SaveImage(img *domain.Image) error
-	GetImage(id string) (*domain.Image, error)
-	DeleteImage(id string) error
-	SetProcessing(id string) error
-	SetProcessed(id string) error
+	SaveImage(img *domain.Image, limits domain.QuotaLimits) error
+	GetImage(tenant, id string) (*domain.Image, error)
+	FindProcessedDuplicate(img *domain.Image) (*domain.Image, error)
+	ListImages(filter domain.ImageFilter) ([]domain.Image, error)
+	DeleteImage(tenant, id string) error
+	GetImageWithDeleted(tenant, id string) (*domain.Image, error)
+	RestoreImage(tenant, id string, deletedAfter time.Time) (*domain.Image, error)
+	PurgeImage(tenant, id string) error
+	OriginalInUse(key string, exceptID string) (bool, error)
+	OutputInUse(key string, exceptID string) (bool, error)
+	LockBlob(ctx context.Context, key string) (func(), error)
+	SetProcessing(tenant, id string) error
+	SetProcessed(tenant, id string, output *domain.FileInfo) error
+	SetFailed(tenant, id string, reason string) error
+	SetCancelled(tenant, id string) error
 	UploadInProducer() ([]domain.Image, error)
+	SaveWebhookDelivery(d *domain.WebhookDelivery) error
+	GetWebhookDeliveries(imageID string) ([]domain.WebhookDelivery, error)
+	SaveBatch(b *domain.Batch) error
+	GetBatchProgress(tenant, id string) (*domain.BatchProgress, error)
+	GetUsage(tenant string) (*domain.Usage, error)
 }
 
 type BrokerProvider interface {
 	CreateMessage(*domain.Image) error
 }
 
-func NewImageService(repo StorageProvider, producer BrokerProvider, config *config.AppConfig) *ImageService {
+type EventPublisher interface {
+	PublishEvent(*domain.StatusEvent) error
+}
+
+// EventPublishers рассылает событие всем публикаторам (Kafka, внутрипроцессный хаб)
+type EventPublishers []EventPublisher
+
+func (p EventPublishers) PublishEvent(event *domain.StatusEvent) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.PublishEvent(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func NewImageService(repo StorageProvider, producer BrokerProvider, events EventPublisher, blobs *blob.Stores, config *config.AppConfig) *ImageService {
 	return &ImageService{
 		repo:     repo,
 		producer: producer,
+		events:   events,
+		blobs:    blobs,
+		fetcher:  fetcher.NewFetcher(config),
 		config:   config,
+		jobs:     make(map[string]map[uint64]context.CancelFunc),
 	}
 }
 
-func (s *ImageService) UploadImage(filename, watermark, resize string, mini bool, file multipart.File) (*domain.Image, error) {
-
-	format := strings.Split(filepath.Ext(filename), ".")[1]
+// UploadImage загружает изображение от имени арендатора tenant; его файлы лежат в пространстве арендатора
+func (s *ImageService) UploadImage(tenant, filename string, params domain.ImageParams, file multipart.File) (*domain.Image, error) {
+	return s.uploadImage(tenant, filename, params, file, nil)
+}
 
-	img, err := domain.NewImage(strings.ToLower(format), watermark, resize, mini, s.config)
+// UploadFromURL скачивает исходник с ограничениями fetch-конфигурации и ставит его в обработку как обычную загрузку
+func (s *ImageService) UploadFromURL(ctx context.Context, tenant, rawURL string, params domain.ImageParams) (*domain.Image, error) {
+	download, err := s.fetcher.Download(ctx, rawURL)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to create new image model")
+		wbzlog.Logger.Error().Err(err).Str("url", rawURL).Msg("Failed to fetch image by URL")
 		return nil, err
 	}
+	defer func() {
+		_ = download.Close()
+	}()
+	return s.uploadImage(tenant, download.Filename, params, download.File, nil)
+}
+
+// UploadBatch загружает файлы пакета по одному; ошибка одного файла не прерывает остальные
+// и возвращается в его результате
+func (s *ImageService) UploadBatch(tenant string, files []domain.BatchFile) (*domain.Batch, []domain.BatchItemResult, error) {
+	batch := domain.NewBatch(tenant, len(files))
+	if err := s.repo.SaveBatch(batch); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to save batch to storage")
+		return nil, nil, err
+	}
 
-	err = s.repo.SaveImage(img)
+	results := make([]domain.BatchItemResult, 0, len(files))
+	for i, f := range files {
+		result := domain.BatchItemResult{Index: i, Filename: f.Filename}
+		img, err := s.uploadImage(tenant, f.Filename, f.Params, f.File, &batch.ID)
+		if err != nil {
+			result.Error = err.Error()
+			var uploadErr *domain.UploadError
+			if errors.As(err, &uploadErr) {
+				result.ErrorCode = uploadErr.Code
+			}
+		} else {
+			result.Image = img
+		}
+		results = append(results, result)
+	}
+	return batch, results, nil
+}
+
+func (s *ImageService) GetBatch(tenant, id string) (*domain.BatchProgress, error) {
+	_, err := idParse(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to parse batch ID")
+		return nil, err
+	}
+	progress, err := s.repo.GetBatchProgress(tenant, id)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to save image metadata to storage")
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get batch progress from storage")
 		return nil, err
 	}
+	return progress, nil
+}
 
-	outDir := s.config.StoragePathConfig.InputDir
+func (s *ImageService) uploadImage(tenant, filename string, params domain.ImageParams, file io.Reader, batchID *uuid.UUID) (*domain.Image, error) {
+	// параметры проверяем до чтения файла, формат — по содержимому, а не по имени
+	if err := domain.ValidateParams(params, s.config); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Invalid image processing parameters")
+		return nil, err
+	}
+	source, content, err := domain.DetectFormat(filename, file, s.config)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Str("filename", filename).Msg("Rejected uploaded file content")
+		return nil, err
+	}
 
-	if err := os.MkdirAll(outDir, 0755); err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to create input directory")
+	img, err := domain.NewImage(source.Format, params, s.config)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to create new image model")
 		return nil, err
 	}
+	img.TenantID = tenant
+	img.BatchID = batchID
 
-	out, err := os.Create(filepath.Join(outDir, img.Name))
+	// квоту проверяем до чтения файла и ещё раз, когда известен его размер; окончательно и атомарно
+	// с параллельными загрузками её проверяет SaveImage
+	usage, err := s.repo.GetUsage(tenant)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to create image file in storage")
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get tenant usage from storage")
+		return nil, err
+	}
+	limits := domain.QuotaFor(&s.config.QuotaConfig, tenant)
+	if err := limits.Check(*usage, 0); err != nil {
+		wbzlog.Logger.Warn().Err(err).Str("tenant", tenant).Msg("Rejected upload over tenant quota")
 		return nil, err
 	}
-	defer func() {
-		_ = out.Close()
-	}()
 
-	_, err = io.Copy(out, file)
+	// файл пишется до записи в БД: размер и хэш исходника известны только после копирования,
+	// а запись без файла воркер не смог бы обработать
+	ctx := context.Background()
+	// ключ исходника заблокирован до сохранения записи, чтобы найденный по хэшу файл не удалили до того,
+	// как на него сошлётся новое изображение
+	stored, err := blob.PutContent(ctx, s.blobs.Originals, s.repo, domain.TenantNamespace(tenant), content, source.Format)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to save uploaded file")
 		return nil, err
 	}
+	img.Source = &domain.FileInfo{Width: source.Width, Height: source.Height, Size: stored.Info.Size, SHA256: stored.SHA256, Key: stored.Info.Key}
+
+	if err := limits.Check(*usage, stored.Info.Size); err != nil {
+		wbzlog.Logger.Warn().Err(err).Str("tenant", tenant).Msg("Rejected upload over tenant quota")
+		s.discardOriginal(ctx, stored)
+		return nil, err
+	}
+
+	err = s.repo.SaveImage(img, limits)
+	if err != nil {
+		var uploadErr *domain.UploadError
+		if errors.As(err, &uploadErr) {
+			wbzlog.Logger.Warn().Err(err).Str("tenant", tenant).Msg("Rejected upload over tenant quota")
+		} else {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to save image metadata to storage")
+		}
+		s.discardOriginal(ctx, stored)
+		return nil, err
+	}
+	stored.Release()
+	s.publishStatus(img.ID, domain.Created)
+
+	if s.reuseOutput(img) {
+		return img, nil
+	}
 
 	err = s.producer.CreateMessage(img)
 	if err != nil {
@@ -86,13 +225,67 @@ func (s *ImageService) UploadImage(filename, watermark, resize string, mini bool
 	return img, nil
 }
 
-func (s *ImageService) GetImage(id string) (*domain.Image, error) {
+// discardOriginal удаляет исходник отклонённой загрузки и снимает блокировку его ключа. Файл, который уже был
+// в хранилище, принадлежит другим загрузкам; записанный этим вызовом под блокировкой никто другой найти не мог
+func (s *ImageService) discardOriginal(ctx context.Context, stored *blob.Content) {
+	defer stored.Release()
+	if stored.Created {
+		_ = s.blobs.Originals.Delete(ctx, stored.Info.Key)
+	}
+}
+
+// LockBlob блокирует ключ объекта хранилища файлов, см. blob.Locker; воркер держит блокировку результата,
+// пока не сохранит ссылку на него
+func (s *ImageService) LockBlob(ctx context.Context, key string) (func(), error) {
+	return s.repo.LockBlob(ctx, key)
+}
+
+// reuseOutput переводит изображение сразу в processed, если тот же исходник с теми же операциями
+// уже обработан: результат адресуется по содержимому, поэтому новое изображение ссылается на тот же файл
+func (s *ImageService) reuseOutput(img *domain.Image) bool {
+	done, err := s.repo.FindProcessedDuplicate(img)
+	if err != nil {
+		if !errors.Is(err, domain.ErrImageNotFound) {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to look up processed duplicate")
+		}
+		return false
+	}
+	// результат не должен пропасть между проверкой и ссылкой на него: его удаляют под той же блокировкой
+	ctx := context.Background()
+	unlock, err := s.repo.LockBlob(ctx, done.Output.Key)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock processed duplicate")
+		return false
+	}
+	defer unlock()
+	if _, err := s.blobs.Processed.Stat(ctx, done.Output.Key); err != nil {
+		wbzlog.Logger.Warn().Err(err).Str("duplicate_of", done.ID.String()).Msg("Processed duplicate file is unavailable")
+		return false
+	}
+	id := img.ID.String()
+	if err := s.SetProcessing(img.TenantID, id); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set duplicate image status to processing")
+		return false
+	}
+	if err := s.SetProcessed(img.TenantID, id, done.Output); err != nil {
+		// задача остаётся в processing и будет отправлена в очередь
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set duplicate image status to processed")
+		return false
+	}
+	wbzlog.Logger.Info().Str("image_id", id).Str("duplicate_of", done.ID.String()).Msg("Reused processed output of identical upload")
+	img.Status = domain.Processed
+	img.Output = done.Output
+	return true
+}
+
+// GetImage возвращает изображение арендатора; изображение другого арендатора — domain.ErrImageNotFound
+func (s *ImageService) GetImage(tenant, id string) (*domain.Image, error) {
 	_, err := idParse(id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to parse image ID")
 		return nil, err
 	}
-	img, err := s.repo.GetImage(id)
+	img, err := s.repo.GetImage(tenant, id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to get image metadata from storage")
 		return nil, err
@@ -100,36 +293,188 @@ func (s *ImageService) GetImage(id string) (*domain.Image, error) {
 	return img, nil
 }
 
-func (s *ImageService) DeleteImage(id string) error {
-	_, err := idParse(id)
+// ListImages возвращает страницу изображений арендатора filter.TenantID; jpg и jpeg считаются одним форматом
+func (s *ImageService) ListImages(filter domain.ImageFilter) (*domain.ImagePage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultListLimit
+	}
+	if filter.Limit > domain.MaxListLimit {
+		filter.Limit = domain.MaxListLimit
+	}
+	formats := make([]string, 0, len(filter.Formats))
+	for _, f := range filter.Formats {
+		f = strings.ToLower(f)
+		formats = append(formats, f)
+		switch f {
+		case "jpg":
+			formats = append(formats, "jpeg")
+		case "jpeg":
+			formats = append(formats, "jpg")
+		}
+	}
+	filter.Formats = formats
+
+	images, err := s.repo.ListImages(filter)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to list images from storage")
+		return nil, err
+	}
+	return domain.NewImagePage(images, filter.Limit), nil
+}
+
+// DeleteImage помещает изображение в корзину, файлы остаются до очистки или восстановления
+func (s *ImageService) DeleteImage(tenant, id string) error {
+	uid, err := idParse(id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to parse image ID")
 		return err
 	}
-	err = s.repo.DeleteImage(id)
+	err = s.repo.DeleteImage(tenant, id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to delete image metadata from storage")
 		return err
 	}
+	s.publishStatus(*uid, domain.Deleted)
 	return nil
 }
 
-func (s *ImageService) SetProcessing(id string) error {
-	_, err := idParse(id)
+func (s *ImageService) SetProcessing(tenant, id string) error {
+	uid, err := idParse(id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to set image status to processing")
 		return err
 	}
-	return s.repo.SetProcessing(id)
+	if err := s.repo.SetProcessing(tenant, id); err != nil {
+		return err
+	}
+	s.publishStatus(*uid, domain.Processing)
+	return nil
 }
 
-func (s *ImageService) SetProcessed(id string) error {
-	_, err := idParse(id)
+// SetProcessed сохраняет размеры результата вместе со статусом
+func (s *ImageService) SetProcessed(tenant, id string, output *domain.FileInfo) error {
+	uid, err := idParse(id)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to set image status to processed")
 		return err
 	}
-	return s.repo.SetProcessed(id)
+	if err := s.repo.SetProcessed(tenant, id, output); err != nil {
+		return err
+	}
+	s.publishStatus(*uid, domain.Processed)
+	s.enqueueWebhook(tenant, id, domain.Processed)
+	return nil
+}
+
+// SetFailed сохраняет причину ошибки, её видно в метаданных изображения
+func (s *ImageService) SetFailed(tenant, id string, reason string) error {
+	uid, err := idParse(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set image status to failed")
+		return err
+	}
+	if err := s.repo.SetFailed(tenant, id, reason); err != nil {
+		return err
+	}
+	s.publishStatus(*uid, domain.Failed)
+	s.enqueueWebhook(tenant, id, domain.Failed)
+	return nil
+}
+
+// CancelImage переводит задачу в cancelled: воркер, получивший её из очереди, пропустит её,
+// а выполняющаяся в этом процессе обработка будет прервана через контекст
+func (s *ImageService) CancelImage(tenant, id string) error {
+	uid, err := idParse(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to parse image ID")
+		return err
+	}
+	if err := s.repo.SetCancelled(tenant, id); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set image status to cancelled")
+		return err
+	}
+	s.publishStatus(*uid, domain.Cancelled)
+	s.cancelJob(id)
+	return nil
+}
+
+// cancelJob прерывает все задачи изображения, выполняющиеся в этом процессе; задачи других
+// воркеров не прерываются и завершаются сами, не перезаписывая статус cancelled
+func (s *ImageService) cancelJob(id string) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	for _, cancel := range s.jobs[id] {
+		cancel()
+	}
+}
+
+// JobContext регистрирует выполняющуюся задачу, чтобы CancelImage мог её прервать;
+// возвращённую функцию нужно вызвать по завершении обработки
+func (s *ImageService) JobContext(parent context.Context, id string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	s.jobsMu.Lock()
+	s.jobSeq++
+	seq := s.jobSeq
+	if s.jobs[id] == nil {
+		s.jobs[id] = make(map[uint64]context.CancelFunc)
+	}
+	s.jobs[id][seq] = cancel
+	s.jobsMu.Unlock()
+	return ctx, func() {
+		s.jobsMu.Lock()
+		delete(s.jobs[id], seq)
+		if len(s.jobs[id]) == 0 {
+			delete(s.jobs, id)
+		}
+		s.jobsMu.Unlock()
+		cancel()
+	}
+}
+
+// publishStatus отправляет событие смены статуса; ошибка публикации не откатывает
+// уже сохранённый переход, поэтому только логируется
+func (s *ImageService) publishStatus(id uuid.UUID, status domain.StatusType) {
+	if err := s.events.PublishEvent(domain.NewStatusEvent(id, status)); err != nil {
+		wbzlog.Logger.Error().Err(err).Str("image_id", id.String()).Msg("Failed to publish image status event")
+	}
+}
+
+// enqueueWebhook ставит в очередь доставку webhook, если клиент передал callback_url;
+// саму отправку с повторами выполняет webhook.Dispatcher
+func (s *ImageService) enqueueWebhook(tenant, id string, status domain.StatusType) {
+	img, err := s.repo.GetImage(tenant, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get image for webhook delivery")
+		return
+	}
+	if img.CallbackURL == "" {
+		return
+	}
+	var resultURL string
+	if status == domain.Processed {
+		resultURL = strings.TrimSuffix(s.config.WebhookConfig.PublicBaseURL, "/") + "/api/image/" + id
+	}
+	delivery, err := domain.NewWebhookDelivery(img, status, resultURL)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to create webhook delivery")
+		return
+	}
+	if err := s.repo.SaveWebhookDelivery(delivery); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to save webhook delivery")
+	}
+}
+
+// GetWebhookDeliveries возвращает журнал доставки, если изображение принадлежит арендатору
+func (s *ImageService) GetWebhookDeliveries(tenant, id string) ([]domain.WebhookDelivery, error) {
+	if _, err := s.GetImage(tenant, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repo.GetWebhookDeliveries(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get webhook deliveries from storage")
+		return nil, err
+	}
+	return deliveries, nil
 }
 
 func idParse(id string) (*uuid.UUID, error) {
@@ -1,47 +1,155 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"image"
+	"image/png"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
+	"imageProcessor/internal/fetcher"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type MockStorage struct {
 	mock.Mock
+	mu   sync.Mutex
+	held map[string]bool
 }
 
-func (m *MockStorage) SaveImage(img *domain.Image) error {
+// LockBlob не идёт через mock: тесты проверяют, удерживается ли блокировка ключа в нужный момент
+func (m *MockStorage) LockBlob(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held == nil {
+		m.held = make(map[string]bool)
+	}
+	m.held[key] = true
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.held, key)
+	}, nil
+}
+
+func (m *MockStorage) isHeld(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.held[key]
+}
+
+func (m *MockStorage) SaveImage(img *domain.Image, limits domain.QuotaLimits) error {
+	args := m.Called(img, limits)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetImage(tenant, id string) (*domain.Image, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).(*domain.Image), args.Error(1)
+}
+
+func (m *MockStorage) FindProcessedDuplicate(img *domain.Image) (*domain.Image, error) {
 	args := m.Called(img)
+	return args.Get(0).(*domain.Image), args.Error(1)
+}
+
+func (m *MockStorage) ListImages(filter domain.ImageFilter) ([]domain.Image, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]domain.Image), args.Error(1)
+}
+
+func (m *MockStorage) DeleteImage(tenant, id string) error {
+	args := m.Called(tenant, id)
 	return args.Error(0)
 }
 
-func (m *MockStorage) GetImage(id string) (*domain.Image, error) {
-	args := m.Called(id)
+func (m *MockStorage) GetImageWithDeleted(tenant, id string) (*domain.Image, error) {
+	args := m.Called(tenant, id)
 	return args.Get(0).(*domain.Image), args.Error(1)
 }
 
-func (m *MockStorage) DeleteImage(id string) error {
-	args := m.Called(id)
+func (m *MockStorage) RestoreImage(tenant, id string, deletedAfter time.Time) (*domain.Image, error) {
+	args := m.Called(tenant, id, deletedAfter)
+	return args.Get(0).(*domain.Image), args.Error(1)
+}
+
+func (m *MockStorage) PurgeImage(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func (m *MockStorage) OriginalInUse(key string, exceptID string) (bool, error) {
+	args := m.Called(key, exceptID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) OutputInUse(key string, exceptID string) (bool, error) {
+	args := m.Called(key, exceptID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) SetProcessing(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func (m *MockStorage) SetProcessed(tenant, id string, output *domain.FileInfo) error {
+	args := m.Called(tenant, id, output)
+	return args.Error(0)
+}
+
+func (m *MockStorage) SetFailed(tenant, id string, reason string) error {
+	args := m.Called(tenant, id, reason)
 	return args.Error(0)
 }
 
-func (m *MockStorage) SetProcessing(id string) error {
-	args := m.Called(id)
+func (m *MockStorage) SetCancelled(tenant, id string) error {
+	args := m.Called(tenant, id)
 	return args.Error(0)
 }
 
-func (m *MockStorage) SetProcessed(id string) error {
-	args := m.Called(id)
+func (m *MockStorage) SaveWebhookDelivery(d *domain.WebhookDelivery) error {
+	args := m.Called(d)
 	return args.Error(0)
 }
 
+func (m *MockStorage) GetWebhookDeliveries(imageID string) ([]domain.WebhookDelivery, error) {
+	args := m.Called(imageID)
+	return args.Get(0).([]domain.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockStorage) SaveBatch(b *domain.Batch) error {
+	args := m.Called(b)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetBatchProgress(tenant, id string) (*domain.BatchProgress, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).(*domain.BatchProgress), args.Error(1)
+}
+
+func (m *MockStorage) GetUsage(tenant string) (*domain.Usage, error) {
+	args := m.Called(tenant)
+	return args.Get(0).(*domain.Usage), args.Error(1)
+}
+
 func (m *MockStorage) UploadInProducer() ([]domain.Image, error) {
 	args := m.Called()
 	return args.Get(0).([]domain.Image), args.Error(1)
@@ -56,6 +164,31 @@ func (m *MockBroker) CreateMessage(img *domain.Image) error {
 	return args.Error(0)
 }
 
+type MockEvents struct {
+	mock.Mock
+}
+
+func (m *MockEvents) PublishEvent(event *domain.StatusEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func newMockEvents() *MockEvents {
+	events := new(MockEvents)
+	events.On("PublishEvent", mock.Anything).Return(nil)
+	return events
+}
+
+// pngData — минимальный валидный PNG: загрузка проверяет содержимое файла
+func pngData(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
 func makeTempFile(t *testing.T, content string) multipart.File {
 	tmpFile, err := os.CreateTemp("", "test-*.txt")
 	if err != nil {
@@ -79,47 +212,55 @@ func TestUploadImage(t *testing.T) {
 			},
 		},
 		StoragePathConfig: config.StoragePathConfig{
-			InputDir:  "./tmp/input/",
-			OutputDir: "./tmp/output/",
+			InputDir:  t.TempDir() + "/",
+			OutputDir: t.TempDir() + "/",
 		},
 	}
-	defer func() {
-		_ = os.RemoveAll("./tmp")
-	}()
 
-	service := NewImageService(storage, broker, cfg)
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "test")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 	filename := "test.png"
 	watermark := "WM"
 	resize := "500x500"
 
-	storage.On("SaveImage", mock.Anything).Return(nil)
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
 	broker.On("CreateMessage", mock.Anything).Return(nil)
 
-	result, err := service.UploadImage(filename, watermark, resize, true, file)
+	result, err := service.UploadImage("", filename, domain.ImageParams{Watermark: watermark, Resize: resize, Mini: true}, file)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	storage.AssertCalled(t, "SaveImage", mock.Anything)
+	hash := sha256.Sum256([]byte(pngData(t)))
+	sum := hex.EncodeToString(hash[:])
+	stat, err := os.Stat(filepath.Join(cfg.StoragePathConfig.InputDir, filepath.FromSlash(result.OriginalKey())))
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.FileInfo{Width: 2, Height: 2, Size: stat.Size(), SHA256: sum, Key: blob.ContentKey(sum, "png")}, result.Source)
+	storage.AssertCalled(t, "SaveImage", mock.Anything, mock.Anything)
 	broker.AssertCalled(t, "CreateMessage", mock.Anything)
 }
 
 func TestUploadImage_ErrorSave(t *testing.T) {
 	storage := new(MockStorage)
 	broker := new(MockBroker)
-	cfg := &config.AppConfig{}
-	service := NewImageService(storage, broker, cfg)
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "test")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 	filename := "test.png"
 	watermark := "WM"
 	resize := "500x500"
 
-	storage.On("SaveImage", mock.Anything).Return(errors.New("fail save"))
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(errors.New("fail save"))
 
-	result, err := service.UploadImage(filename, watermark, resize, true, file)
+	result, err := service.UploadImage("", filename, domain.ImageParams{Watermark: watermark, Resize: resize, Mini: true}, file)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 }
@@ -127,61 +268,376 @@ func TestUploadImage_ErrorSave(t *testing.T) {
 func TestGetImage(t *testing.T) {
 	storage := new(MockStorage)
 	broker := new(MockBroker)
-	cfg := &config.AppConfig{}
-	service := NewImageService(storage, broker, cfg)
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
 	id := uuid.New().String()
 	img := &domain.Image{ID: uuid.New()}
 
-	storage.On("GetImage", id).Return(img, nil)
+	storage.On("GetImage", "", id).Return(img, nil)
 
-	result, err := service.GetImage(id)
+	result, err := service.GetImage("", id)
 	assert.NoError(t, err)
 	assert.Equal(t, img, result)
 }
 
 func TestGetImage_ParseError(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 
-	_, err := service.GetImage("invalid-uuid")
+	_, err := service.GetImage("", "invalid-uuid")
 	assert.Error(t, err)
 }
 
+func TestGetImage_OtherTenant(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	// репозиторий ищет только среди изображений арендатора, чужое для него не существует
+	storage.On("GetImage", "globex", id).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	_, err := service.GetImage("globex", id)
+	assert.ErrorIs(t, err, domain.ErrImageNotFound)
+	_, err = service.GetWebhookDeliveries("globex", id)
+	assert.ErrorIs(t, err, domain.ErrImageNotFound)
+	storage.AssertNotCalled(t, "GetWebhookDeliveries", mock.Anything)
+}
+
 func TestDeleteImage(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 	id := uuid.New().String()
 
-	storage.On("DeleteImage", id).Return(nil)
-	err := service.DeleteImage(id)
+	storage.On("DeleteImage", "", id).Return(nil)
+	err := service.DeleteImage("", id)
 	assert.NoError(t, err)
 }
 
 func TestSetProcessing(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 	id := uuid.New().String()
 
-	storage.On("SetProcessing", id).Return(nil)
-	err := service.SetProcessing(id)
+	storage.On("SetProcessing", "", id).Return(nil)
+	err := service.SetProcessing("", id)
 	assert.NoError(t, err)
 }
 
 func TestSetProcessed(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	storage.On("SetProcessed", "", id, (*domain.FileInfo)(nil)).Return(nil)
+	storage.On("GetImage", "", id).Return(&domain.Image{}, nil)
+	err := service.SetProcessed("", id, nil)
+	assert.NoError(t, err)
+	storage.AssertNotCalled(t, "SaveWebhookDelivery", mock.Anything)
+}
+
+func TestSetFailed(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	storage.On("SetFailed", "", id, "decode error").Return(nil)
+	storage.On("GetImage", "", id).Return(&domain.Image{}, nil)
+	err := service.SetFailed("", id, "decode error")
+	assert.NoError(t, err)
+}
+
+func TestSetProcessed_EnqueuesWebhook(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{
+		WebhookConfig:     config.WebhookConfig{PublicBaseURL: "http://img.local/"},
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+	}
+	service := NewImageService(storage, nil, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+	id := uuid.New()
+	img := &domain.Image{ID: id, CallbackURL: "https://client.example/hook"}
+
+	storage.On("SetProcessed", "", id.String(), (*domain.FileInfo)(nil)).Return(nil)
+	storage.On("GetImage", "", id.String()).Return(img, nil)
+	storage.On("SaveWebhookDelivery", mock.Anything).Return(nil)
+
+	err := service.SetProcessed("", id.String(), nil)
+	assert.NoError(t, err)
+
+	storage.AssertCalled(t, "SaveWebhookDelivery", mock.MatchedBy(func(d *domain.WebhookDelivery) bool {
+		var payload domain.WebhookPayload
+		_ = json.Unmarshal(d.Payload, &payload)
+		return d.URL == img.CallbackURL &&
+			d.Status == domain.DeliveryPending &&
+			payload.Status == domain.Processed &&
+			payload.ResultURL == "http://img.local/api/image/"+id.String()
+	}))
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+	deliveries := []domain.WebhookDelivery{{ID: uuid.New(), Status: domain.DeliveryDelivered}}
+
+	storage.On("GetImage", "", id).Return(&domain.Image{}, nil)
+	storage.On("GetWebhookDeliveries", id).Return(deliveries, nil)
+
+	result, err := service.GetWebhookDeliveries("", id)
+	assert.NoError(t, err)
+	assert.Equal(t, deliveries, result)
+}
+
+func TestSetProcessed_TransitionError(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	storage.On("SetProcessed", "", id, (*domain.FileInfo)(nil)).Return(&domain.TransitionError{From: domain.Deleted, To: domain.Processed})
+	err := service.SetProcessed("", id, nil)
+
+	var trErr *domain.TransitionError
+	assert.ErrorAs(t, err, &trErr)
+	assert.Equal(t, domain.Deleted, trErr.From)
+}
+
+func TestSetProcessed_PublishesEvent(t *testing.T) {
+	storage := new(MockStorage)
+	events := newMockEvents()
+	service := NewImageService(storage, nil, events, nil, &config.AppConfig{})
+	id := uuid.New()
+
+	storage.On("SetProcessed", "", id.String(), (*domain.FileInfo)(nil)).Return(nil)
+	storage.On("GetImage", "", id.String()).Return(&domain.Image{}, nil)
+	err := service.SetProcessed("", id.String(), nil)
+	assert.NoError(t, err)
+
+	events.AssertCalled(t, "PublishEvent", mock.MatchedBy(func(e *domain.StatusEvent) bool {
+		return e.ImageID == id && e.Type == domain.EventImageProcessed && e.SchemaVersion == domain.EventSchemaVersion
+	}))
+}
+
+func TestSetProcessing_NoEventOnError(t *testing.T) {
+	storage := new(MockStorage)
+	events := newMockEvents()
+	service := NewImageService(storage, nil, events, nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	storage.On("SetProcessing", "", id).Return(&domain.TransitionError{From: domain.Deleted, To: domain.Processing})
+	err := service.SetProcessing("", id)
+	assert.Error(t, err)
+
+	events.AssertNotCalled(t, "PublishEvent", mock.Anything)
+}
+
+func TestDeleteImage_PublishErrorIgnored(t *testing.T) {
+	storage := new(MockStorage)
+	events := new(MockEvents)
+	service := NewImageService(storage, nil, events, nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	storage.On("DeleteImage", "", id).Return(nil)
+	events.On("PublishEvent", mock.Anything).Return(errors.New("kafka down"))
+
+	err := service.DeleteImage("", id)
+	assert.NoError(t, err)
+	events.AssertNumberOfCalls(t, "PublishEvent", 1)
+}
+
+func TestCancelImage_InFlight(t *testing.T) {
+	storage := new(MockStorage)
+	events := newMockEvents()
+	service := NewImageService(storage, nil, events, nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	jobCtx, done := service.JobContext(context.Background(), id)
+	defer done()
+
+	storage.On("SetCancelled", "", id).Return(nil)
+	err := service.CancelImage("", id)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, jobCtx.Err(), context.Canceled)
+	events.AssertCalled(t, "PublishEvent", mock.MatchedBy(func(e *domain.StatusEvent) bool {
+		return e.Type == domain.EventImageCancelled
+	}))
+}
+
+func TestCancelImage_TransitionError(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	jobCtx, done := service.JobContext(context.Background(), id)
+	defer done()
+
+	storage.On("SetCancelled", "", id).Return(&domain.TransitionError{From: domain.Processed, To: domain.Cancelled})
+	err := service.CancelImage("", id)
+	assert.Error(t, err)
+	assert.NoError(t, jobCtx.Err())
+}
+
+func TestJobContext_DoneUnregisters(t *testing.T) {
+	service := NewImageService(new(MockStorage), nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	_, done := service.JobContext(context.Background(), id)
+	done()
+
+	assert.Empty(t, service.jobs)
+}
+
+func TestCancelImage_CancelsRedeliveredJobs(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	id := uuid.New().String()
+
+	// повторная доставка сообщения запускает вторую задачу, пока первая ещё выполняется
+	first, doneFirst := service.JobContext(context.Background(), id)
+	second, doneSecond := service.JobContext(context.Background(), id)
+	defer doneSecond()
+	// завершение одной задачи не снимает регистрацию другой
+	doneFirst()
+	third, doneThird := service.JobContext(context.Background(), id)
+	defer doneThird()
+
+	storage.On("SetCancelled", "", id).Return(nil)
+	assert.NoError(t, service.CancelImage("", id))
+
+	assert.ErrorIs(t, first.Err(), context.Canceled)
+	assert.ErrorIs(t, second.Err(), context.Canceled)
+	assert.ErrorIs(t, third.Err(), context.Canceled)
+}
+
+func TestUploadFromURL(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(pngData(t)))
+	}))
+	defer source.Close()
+
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	inputDir := t.TempDir() + "/"
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: inputDir},
+		FetchConfig:       config.FetchConfig{Timeout: time.Second, MaxSize: 1024, Allowlist: []string{"127.0.0.1"}},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	broker.On("CreateMessage", mock.Anything).Return(nil)
+
+	img, err := service.UploadFromURL(context.Background(), "", source.URL+"/cdn/photo", domain.ImageParams{Resize: "10x10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "png", img.Format)
+	data, err := os.ReadFile(filepath.Join(inputDir, filepath.FromSlash(img.OriginalKey())))
+	assert.NoError(t, err)
+	assert.Equal(t, pngData(t), string(data))
+}
+
+func TestUploadFromURL_ForbiddenAddress(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{
+		FetchConfig:       config.FetchConfig{Timeout: time.Second},
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+	}
+	service := NewImageService(storage, new(MockBroker), newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	_, err := service.UploadFromURL(context.Background(), "", "http://127.0.0.1:1/a.png", domain.ImageParams{})
+	assert.ErrorIs(t, err, fetcher.ErrForbiddenAddress)
+	storage.AssertNotCalled(t, "SaveImage", mock.Anything, mock.Anything)
+}
+
+func TestUploadBatch_PartialFailure(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{
+			SupportedFormats: map[string]bool{"png": true},
+		},
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  t.TempDir() + "/",
+			OutputDir: t.TempDir() + "/",
+		},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	storage.On("SaveBatch", mock.Anything).Return(nil)
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	broker.On("CreateMessage", mock.Anything).Return(nil)
+
+	batch, results, err := service.UploadBatch("", []domain.BatchFile{
+		{Filename: "a.png", Params: domain.ImageParams{Resize: "10x10"}, File: strings.NewReader(pngData(t))},
+		{Filename: "b.gif", File: strings.NewReader("not an image")},
+		{Filename: "c", Params: domain.ImageParams{Priority: "bulk"}, File: strings.NewReader(pngData(t))},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, batch.Total)
+	assert.Len(t, results, 3)
+
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, &batch.ID, results[0].Image.BatchID)
+	assert.Equal(t, domain.CodeUnsupportedMedia, results[1].ErrorCode)
+	assert.Nil(t, results[1].Image)
+	assert.Equal(t, domain.PriorityBulk, results[2].Image.Priority)
+
+	broker.AssertNumberOfCalls(t, "CreateMessage", 2)
+}
+
+func TestUploadBatch_SaveBatchError(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, new(MockBroker), newMockEvents(), nil, &config.AppConfig{})
+
+	storage.On("SaveBatch", mock.Anything).Return(errors.New("db down"))
+
+	batch, results, err := service.UploadBatch("", []domain.BatchFile{{Filename: "a.png", File: strings.NewReader("a")}})
+	assert.Error(t, err)
+	assert.Nil(t, batch)
+	assert.Nil(t, results)
+	storage.AssertNotCalled(t, "SaveImage", mock.Anything, mock.Anything)
+}
+
+func TestGetBatch(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 	id := uuid.New().String()
+	progress := &domain.BatchProgress{Accepted: 2}
+
+	storage.On("GetBatchProgress", "", id).Return(progress, nil)
 
-	storage.On("SetProcessed", id).Return(nil)
-	err := service.SetProcessed(id)
+	result, err := service.GetBatch("", id)
 	assert.NoError(t, err)
+	assert.Equal(t, progress, result)
+
+	_, err = service.GetBatch("", "bad-id")
+	assert.Error(t, err)
+}
+
+func TestEventPublishers(t *testing.T) {
+	first := new(MockEvents)
+	second := new(MockEvents)
+	event := domain.NewStatusEvent(uuid.New(), domain.Processed)
+
+	first.On("PublishEvent", event).Return(errors.New("kafka down"))
+	second.On("PublishEvent", event).Return(nil)
+
+	err := EventPublishers{first, second}.PublishEvent(event)
+	assert.Error(t, err)
+	second.AssertCalled(t, "PublishEvent", event)
 }
 
 func TestUploadInProducer(t *testing.T) {
 	storage := new(MockStorage)
 	broker := new(MockBroker)
-	service := NewImageService(storage, broker, &config.AppConfig{})
+	service := NewImageService(storage, broker, newMockEvents(), nil, &config.AppConfig{})
 
 	img1 := domain.Image{ID: uuid.New()}
 	img2 := domain.Image{ID: uuid.New()}
@@ -205,19 +661,22 @@ func TestUploadImage_ErrorProducer(t *testing.T) {
 			SupportedFormats: map[string]bool{"png": true},
 		},
 		StoragePathConfig: config.StoragePathConfig{
-			InputDir: "./tmp/input/",
+			InputDir:  t.TempDir() + "/",
+			OutputDir: t.TempDir() + "/",
 		},
 	}
 
-	service := NewImageService(storage, broker, cfg)
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "data")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 
-	storage.On("SaveImage", mock.Anything).Return(nil)
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
 	broker.On("CreateMessage", mock.Anything).Return(errors.New("producer error"))
 
-	result, err := service.UploadImage("test.png", "WM", "100x100", false, file)
+	result, err := service.UploadImage("", "test.png", domain.ImageParams{Watermark: "WM", Resize: "100x100"}, file)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -225,13 +684,13 @@ func TestUploadImage_ErrorProducer(t *testing.T) {
 
 func TestGetImage_RepoError(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 
 	id := uuid.New().String()
 
-	storage.On("GetImage", id).Return((*domain.Image)(nil), errors.New("get error"))
+	storage.On("GetImage", "", id).Return((*domain.Image)(nil), errors.New("get error"))
 
-	result, err := service.GetImage(id)
+	result, err := service.GetImage("", id)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -239,39 +698,39 @@ func TestGetImage_RepoError(t *testing.T) {
 
 func TestDeleteImage_Error(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 
 	id := uuid.New().String()
 
-	storage.On("DeleteImage", id).Return(errors.New("delete error"))
+	storage.On("DeleteImage", "", id).Return(errors.New("delete error"))
 
-	err := service.DeleteImage(id)
+	err := service.DeleteImage("", id)
 
 	assert.Error(t, err)
 }
 
 func TestSetProcessing_Error(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 
 	id := uuid.New().String()
 
-	storage.On("SetProcessing", id).Return(errors.New("set processing error"))
+	storage.On("SetProcessing", "", id).Return(errors.New("set processing error"))
 
-	err := service.SetProcessing(id)
+	err := service.SetProcessing("", id)
 
 	assert.Error(t, err)
 }
 
 func TestSetProcessed_Error(t *testing.T) {
 	storage := new(MockStorage)
-	service := NewImageService(storage, nil, &config.AppConfig{})
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
 
 	id := uuid.New().String()
 
-	storage.On("SetProcessed", id).Return(errors.New("set processed error"))
+	storage.On("SetProcessed", "", id, (*domain.FileInfo)(nil)).Return(errors.New("set processed error"))
 
-	err := service.SetProcessed(id)
+	err := service.SetProcessed("", id, nil)
 
 	assert.Error(t, err)
 }
@@ -279,7 +738,7 @@ func TestSetProcessed_Error(t *testing.T) {
 func TestUploadInProducer_RepoError(t *testing.T) {
 	storage := new(MockStorage)
 	broker := new(MockBroker)
-	service := NewImageService(storage, broker, &config.AppConfig{})
+	service := NewImageService(storage, broker, newMockEvents(), nil, &config.AppConfig{})
 
 	storage.On("UploadInProducer").Return([]domain.Image(nil), errors.New("repo error"))
 	service.UploadInProducer()
@@ -291,7 +750,7 @@ func TestUploadInProducer_RepoError(t *testing.T) {
 func TestUploadInProducer_BrokerError(t *testing.T) {
 	storage := new(MockStorage)
 	broker := new(MockBroker)
-	service := NewImageService(storage, broker, &config.AppConfig{})
+	service := NewImageService(storage, broker, newMockEvents(), nil, &config.AppConfig{})
 
 	img := domain.Image{ID: uuid.New()}
 
@@ -313,29 +772,29 @@ func TestUploadImage_NewImageError(t *testing.T) {
 				"png": true,
 			},
 		},
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  t.TempDir() + "/",
+			OutputDir: t.TempDir() + "/",
+		},
 	}
 
-	service := NewImageService(storage, broker, cfg)
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "data")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 
-	result, err := service.UploadImage("file.jpg", "", "", false, file)
+	result, err := service.UploadImage("", "file.jpg", domain.ImageParams{}, file)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
 
-	storage.AssertNotCalled(t, "SaveImage")
+	storage.AssertNotCalled(t, "SaveImage", mock.Anything, mock.Anything)
 	broker.AssertNotCalled(t, "CreateMessage")
 }
 
 func TestUploadImage_ErrorMkdirAll(t *testing.T) {
-	_ = os.RemoveAll("./tmp")
-	defer func() {
-		_ = os.RemoveAll("./tmp")
-	}()
-
-	err := os.WriteFile("./tmp", []byte("block dir"), 0644)
+	blocked := filepath.Join(t.TempDir(), "tmp")
+	err := os.WriteFile(blocked, []byte("block dir"), 0644)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -345,22 +804,24 @@ func TestUploadImage_ErrorMkdirAll(t *testing.T) {
 
 	cfg := &config.AppConfig{
 		StoragePathConfig: config.StoragePathConfig{
-			InputDir:  "./tmp/input/",
-			OutputDir: "./tmp/out/",
+			InputDir:  blocked + "/input/",
+			OutputDir: blocked + "/out/",
 		},
 		ImageFormats: config.ImageFormats{
 			SupportedFormats: map[string]bool{"png": true},
 		},
 	}
 
-	service := NewImageService(storage, broker, cfg)
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "data")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 
-	storage.On("SaveImage", mock.Anything).Return(nil)
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
 
-	result, err := service.UploadImage("file.png", "", "", false, file)
+	result, err := service.UploadImage("", "file.png", domain.ImageParams{}, file)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -379,24 +840,280 @@ func TestUploadImage_SaveImageError(t *testing.T) {
 			},
 		},
 		StoragePathConfig: config.StoragePathConfig{
-			InputDir:  "./tmp/input/",
-			OutputDir: "./tmp/output/",
+			InputDir:  t.TempDir() + "/",
+			OutputDir: t.TempDir() + "/",
 		},
 	}
 
-	service := NewImageService(storage, broker, cfg)
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
 
-	file := makeTempFile(t, "test-content")
+	file := makeTempFile(t, pngData(t))
 	defer func() { _ = file.Close() }()
 
-	storage.On("SaveImage", mock.Anything).Return(errors.New("save failed"))
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(errors.New("save failed"))
 
-	result, err := service.UploadImage("file.png", "wm", "100x100", false, file)
+	result, err := service.UploadImage("", "file.png", domain.ImageParams{Watermark: "wm", Resize: "100x100"}, file)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
 
-	storage.AssertCalled(t, "SaveImage", mock.Anything)
+	storage.AssertCalled(t, "SaveImage", mock.Anything, mock.Anything)
+	// файл без записи в БД не остаётся во входном каталоге
+	var keys []string
+	_ = blob.NewLocal(cfg.StoragePathConfig.InputDir).List(context.Background(), "", func(info blob.Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	assert.Empty(t, keys)
 
 	broker.AssertNotCalled(t, "CreateMessage")
 }
+
+func TestListImages(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+	images := []domain.Image{{ID: uuid.New()}, {ID: uuid.New()}}
+
+	storage.On("ListImages", mock.MatchedBy(func(f domain.ImageFilter) bool {
+		return f.Limit == domain.MaxListLimit && assert.ObjectsAreEqual([]string{"jpg", "jpeg", "png"}, f.Formats)
+	})).Return(images, nil).Once()
+	page, err := service.ListImages(domain.ImageFilter{Formats: []string{"JPG", "png"}, Limit: 1000})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Empty(t, page.NextCursor)
+
+	storage.On("ListImages", mock.MatchedBy(func(f domain.ImageFilter) bool {
+		return f.Limit == 1
+	})).Return(images, nil).Once()
+	page, err = service.ListImages(domain.ImageFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	storage.On("ListImages", mock.MatchedBy(func(f domain.ImageFilter) bool {
+		return f.Limit == domain.DefaultListLimit
+	})).Return([]domain.Image(nil), errors.New("db down")).Once()
+	_, err = service.ListImages(domain.ImageFilter{})
+	assert.Error(t, err)
+}
+
+func TestUploadImage_ReusesProcessedOutput(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	outputDir := t.TempDir()
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: outputDir + "/"},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	output := &domain.FileInfo{Width: 2, Height: 2, Size: 10, SHA256: "ef01", Key: "ef/01/ef01.png"}
+	assert.NoError(t, os.MkdirAll(filepath.Join(outputDir, "ef", "01"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(outputDir, "ef", "01", "ef01.png"), []byte("processed"), 0644))
+	done := &domain.Image{ID: uuid.New(), Status: domain.Processed, Output: output}
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		// исходник не может быть удалён, пока новая запись на него не сослалась
+		key := args.Get(0).(*domain.Image).Source.Key
+		assert.True(t, storage.isHeld(key), "original must stay locked until the image is saved")
+	})
+	storage.On("FindProcessedDuplicate", mock.Anything).Return(done, nil)
+	storage.On("SetProcessing", "", mock.Anything).Return(nil)
+	storage.On("SetProcessed", "", mock.Anything, output).Return(nil).Run(func(mock.Arguments) {
+		assert.True(t, storage.isHeld(output.Key), "processed output must stay locked until it is referenced")
+	})
+	storage.On("GetImage", "", mock.Anything).Return(&domain.Image{}, nil)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	img, err := service.UploadImage("", "a.png", domain.ImageParams{Resize: "10x10"}, file)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Processed, img.Status)
+	assert.Equal(t, output, img.Output)
+	storage.AssertCalled(t, "SetProcessed", "", img.ID.String(), output)
+	broker.AssertNotCalled(t, "CreateMessage", mock.Anything)
+}
+
+func TestUploadImage_ProcessedDuplicateMissingFile(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	// запись о результате есть, а файл уже удалён — изображение обрабатывается заново
+	output := &domain.FileInfo{Width: 2, Height: 2, Size: 10, SHA256: "ef01", Key: "ef/01/ef01.png"}
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return(&domain.Image{ID: uuid.New(), Status: domain.Processed, Output: output}, nil)
+	broker.On("CreateMessage", mock.Anything).Return(nil)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	img, err := service.UploadImage("", "a.png", domain.ImageParams{Resize: "10x10"}, file)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Created, img.Status)
+	storage.AssertNotCalled(t, "SetProcessed", mock.Anything, mock.Anything, mock.Anything)
+	broker.AssertCalled(t, "CreateMessage", mock.Anything)
+	assert.Empty(t, storage.held)
+}
+
+func TestUploadImage_SaveErrorKeepsSharedOriginal(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	inputDir := t.TempDir() + "/"
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: inputDir},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	storage.On("GetUsage", mock.Anything).Return(&domain.Usage{}, nil)
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(nil).Once()
+	storage.On("SaveImage", mock.Anything, mock.Anything).Return(errors.New("save failed")).Once()
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	broker.On("CreateMessage", mock.Anything).Return(nil)
+
+	fileA, fileB := makeTempFile(t, pngData(t)), makeTempFile(t, pngData(t))
+	defer func() { _ = fileA.Close(); _ = fileB.Close() }()
+
+	first, err := service.UploadImage("", "a.png", domain.ImageParams{}, fileA)
+	assert.NoError(t, err)
+	_, err = service.UploadImage("", "b.png", domain.ImageParams{}, fileB)
+	assert.Error(t, err)
+
+	// исходник первой загрузки с тем же содержимым не удаляется
+	assert.FileExists(t, filepath.Join(inputDir, filepath.FromSlash(first.OriginalKey())))
+}
+
+func TestUploadImage_QuotaExceeded(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	inputDir := t.TempDir() + "/"
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: inputDir},
+		QuotaConfig:       config.QuotaConfig{Tenants: []config.TenantQuota{{Tenant: "team-a", MaxImages: 10, MaxBytes: 100}}},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	storage.On("GetUsage", "team-a").Return(&domain.Usage{Tenant: "team-a", Images: 1, OriginalBytes: 90}, nil)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	_, err := service.UploadImage("team-a", "a.png", domain.ImageParams{}, file)
+	var uploadErr *domain.UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, domain.CodeQuotaExceeded, uploadErr.Code)
+	storage.AssertNotCalled(t, "SaveImage", mock.Anything, mock.Anything)
+
+	// исходник отклонённой загрузки не остаётся в хранилище
+	keys := make([]string, 0)
+	assert.NoError(t, blob.NewLocal(inputDir).List(context.Background(), "", func(info blob.Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	}))
+	assert.Empty(t, keys)
+}
+
+func TestUploadImage_ResizeTooLarge(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		UploadConfig: config.UploadConfig{MaxWidth: 10000, MaxHeight: 10000, MaxMegapixels: 50},
+	}
+	service := NewImageService(storage, nil, newMockEvents(), nil, cfg)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	// крошечный исходник не должен превращаться в гигантский результат
+	_, err := service.UploadImage("", "a.png", domain.ImageParams{Resize: "100000x100000"}, file)
+	var uploadErr *domain.UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, domain.CodeImageTooLarge, uploadErr.Code)
+	storage.AssertNotCalled(t, "GetUsage", mock.Anything)
+}
+
+func TestUploadImage_ImageQuotaExceeded(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		QuotaConfig:  config.QuotaConfig{MaxImages: 5},
+	}
+	service := NewImageService(storage, nil, newMockEvents(), nil, cfg)
+
+	storage.On("GetUsage", "").Return(&domain.Usage{Images: 5}, nil)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	// нет места под ещё одно изображение: отказ до записи файла
+	_, err := service.UploadImage("", "a.png", domain.ImageParams{}, file)
+	var uploadErr *domain.UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, domain.CodeQuotaExceeded, uploadErr.Code)
+}
+
+func TestUploadImage_QuotaExceededOnSave(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	inputDir := t.TempDir() + "/"
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: inputDir},
+		QuotaConfig:       config.QuotaConfig{MaxImages: 5},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	// предварительная проверка прошла, но параллельная загрузка заняла последнее место до сохранения записи
+	storage.On("GetUsage", "").Return(&domain.Usage{Images: 4}, nil)
+	quotaErr := &domain.UploadError{Code: domain.CodeQuotaExceeded, Message: "image quota exceeded: 5 of 5 images used"}
+	storage.On("SaveImage", mock.Anything, domain.QuotaLimits{MaxImages: 5}).Return(quotaErr)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	_, err := service.UploadImage("", "a.png", domain.ImageParams{}, file)
+	assert.ErrorIs(t, err, quotaErr)
+	broker.AssertNotCalled(t, "CreateMessage", mock.Anything)
+	keys := make([]string, 0)
+	assert.NoError(t, blob.NewLocal(inputDir).List(context.Background(), "", func(info blob.Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	}))
+	assert.Empty(t, keys)
+}
+
+func TestUploadImage_TenantNamespace(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	inputDir := t.TempDir() + "/"
+	cfg := &config.AppConfig{
+		ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		StoragePathConfig: config.StoragePathConfig{InputDir: inputDir},
+	}
+	service := NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg)
+
+	storage.On("GetUsage", "acme").Return(&domain.Usage{Tenant: "acme"}, nil)
+	storage.On("SaveImage", mock.MatchedBy(func(img *domain.Image) bool {
+		return img.TenantID == "acme"
+	}), domain.QuotaLimits{}).Return(nil)
+	storage.On("FindProcessedDuplicate", mock.Anything).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	broker.On("CreateMessage", mock.Anything).Return(nil)
+
+	file := makeTempFile(t, pngData(t))
+	defer func() { _ = file.Close() }()
+
+	img, err := service.UploadImage("acme", "a.png", domain.ImageParams{}, file)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", img.TenantID)
+	assert.True(t, strings.HasPrefix(img.Source.Key, "tenants/acme/"))
+	assert.FileExists(t, filepath.Join(inputDir, filepath.FromSlash(img.Source.Key)))
+}
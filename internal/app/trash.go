@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+// RestoreImage возвращает изображение из корзины, пока не истёк trash.period; задача, удалённая
+// до завершения обработки, заново ставится в очередь
+func (s *ImageService) RestoreImage(tenant, id string) (*domain.Image, error) {
+	uid, err := idParse(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to parse image ID")
+		return nil, err
+	}
+	img, err := s.repo.RestoreImage(tenant, id, time.Now().Add(-s.config.TrashConfig.Period))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to restore image from trash")
+		return nil, err
+	}
+	s.publishStatus(*uid, img.Status)
+
+	if img.Status == domain.Created {
+		if err := s.producer.CreateMessage(img); err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to send restored image into kafka producer")
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// HardDeleteImage сразу удаляет файлы и запись изображения в любом статусе, в том числе из корзины,
+// — для запросов на удаление персональных данных
+func (s *ImageService) HardDeleteImage(tenant, id string) error {
+	uid, err := idParse(id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to parse image ID")
+		return err
+	}
+	img, err := s.repo.GetImageWithDeleted(tenant, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get image for hard delete")
+		return err
+	}
+	s.cancelJob(id)
+	if err := s.PurgeImage(context.Background(), img); err != nil {
+		return err
+	}
+	if img.Status != domain.Deleted {
+		s.publishStatus(*uid, domain.Deleted)
+	}
+	return nil
+}
+
+// PurgeImage удаляет исходник и результат, если на них не ссылаются другие изображения, и затем запись.
+// При ошибке запись остаётся, повторная очистка удалит оставшиеся файлы
+func (s *ImageService) PurgeImage(ctx context.Context, img *domain.Image) error {
+	id := img.ID.String()
+	if img.OriginalDeletedAt == nil {
+		shared := img.Source != nil && img.Source.Key != ""
+		if err := s.removeBlob(ctx, s.blobs.Originals, img.OriginalKey(), shared, id, s.repo.OriginalInUse); err != nil {
+			wbzlog.Logger.Error().Err(err).Str("image_id", id).Msg("Failed to remove original")
+			return err
+		}
+	}
+	shared := img.Output != nil && img.Output.Key != ""
+	if err := s.removeBlob(ctx, s.blobs.Processed, img.OutputKey(), shared, id, s.repo.OutputInUse); err != nil {
+		wbzlog.Logger.Error().Err(err).Str("image_id", id).Msg("Failed to remove processed image")
+		return err
+	}
+	if err := s.repo.PurgeImage(img.TenantID, id); err != nil && !errors.Is(err, domain.ErrImageNotFound) {
+		wbzlog.Logger.Error().Err(err).Str("image_id", id).Msg("Failed to purge image from storage")
+		return err
+	}
+	return nil
+}
+
+// removeBlob удаляет файл key; файл, адресованный по содержимому (shared), удаляется, только если
+// inUse не нашёл других изображений, которые на него ссылаются. Проверка и удаление идут под блокировкой
+// ключа, которую держат и загрузки, ссылающиеся на тот же файл
+func (s *ImageService) removeBlob(ctx context.Context, store blob.Store, key string, shared bool, id string, inUse func(key, exceptID string) (bool, error)) error {
+	if shared {
+		unlock, err := s.repo.LockBlob(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		referenced, err := inUse(key, id)
+		if err != nil {
+			return err
+		}
+		if referenced {
+			return nil
+		}
+	}
+	return store.Delete(ctx, key)
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// trashService — сервис с локальными хранилищами во временных каталогах
+func trashService(t *testing.T, storage *MockStorage, broker *MockBroker) (*ImageService, *config.AppConfig) {
+	t.Helper()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+		TrashConfig:       config.TrashConfig{Period: 24 * time.Hour},
+	}
+	return NewImageService(storage, broker, newMockEvents(), blob.NewLocalStores(cfg), cfg), cfg
+}
+
+func writeFile(t *testing.T, dir, key string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRestoreImage_Requeues(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	service, _ := trashService(t, storage, broker)
+	id := uuid.New()
+
+	img := &domain.Image{ID: id, Status: domain.Created}
+	withinPeriod := mock.MatchedBy(func(after time.Time) bool {
+		return time.Since(after) > 23*time.Hour && time.Since(after) < 25*time.Hour
+	})
+	storage.On("RestoreImage", "", id.String(), withinPeriod).Return(img, nil)
+	broker.On("CreateMessage", img).Return(nil)
+
+	result, err := service.RestoreImage("", id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, img, result)
+	broker.AssertCalled(t, "CreateMessage", img)
+}
+
+func TestRestoreImage_Processed(t *testing.T) {
+	storage := new(MockStorage)
+	broker := new(MockBroker)
+	service, _ := trashService(t, storage, broker)
+	id := uuid.New()
+
+	storage.On("RestoreImage", "", id.String(), mock.Anything).Return(&domain.Image{ID: id, Status: domain.Processed}, nil)
+
+	_, err := service.RestoreImage("", id.String())
+	assert.NoError(t, err)
+	broker.AssertNotCalled(t, "CreateMessage", mock.Anything)
+}
+
+func TestRestoreImage_Expired(t *testing.T) {
+	storage := new(MockStorage)
+	service, _ := trashService(t, storage, new(MockBroker))
+	id := uuid.New().String()
+
+	storage.On("RestoreImage", "", id, mock.Anything).Return((*domain.Image)(nil), domain.ErrTrashExpired)
+
+	_, err := service.RestoreImage("", id)
+	assert.ErrorIs(t, err, domain.ErrTrashExpired)
+}
+
+func TestHardDeleteImage(t *testing.T) {
+	storage := new(MockStorage)
+	service, cfg := trashService(t, storage, nil)
+	id := uuid.New()
+
+	img := &domain.Image{ID: id, Name: "a.png", Status: domain.Processing}
+	original := writeFile(t, cfg.StoragePathConfig.InputDir, img.OriginalKey())
+	output := writeFile(t, cfg.StoragePathConfig.OutputDir, img.OutputKey())
+	storage.On("GetImageWithDeleted", "", id.String()).Return(img, nil)
+	storage.On("PurgeImage", "", id.String()).Return(nil)
+
+	jobCtx, done := service.JobContext(context.Background(), id.String())
+	defer done()
+
+	assert.NoError(t, service.HardDeleteImage("", id.String()))
+	assert.NoFileExists(t, original)
+	assert.NoFileExists(t, output)
+	assert.ErrorIs(t, jobCtx.Err(), context.Canceled)
+	storage.AssertCalled(t, "PurgeImage", "", id.String())
+}
+
+func TestHardDeleteImage_NotFound(t *testing.T) {
+	storage := new(MockStorage)
+	service, _ := trashService(t, storage, nil)
+	id := uuid.New().String()
+
+	storage.On("GetImageWithDeleted", "", id).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	assert.ErrorIs(t, service.HardDeleteImage("", id), domain.ErrImageNotFound)
+	storage.AssertNotCalled(t, "PurgeImage", mock.Anything, mock.Anything)
+}
+
+func TestHardDeleteImage_OtherTenant(t *testing.T) {
+	storage := new(MockStorage)
+	service, cfg := trashService(t, storage, nil)
+	id := uuid.New()
+
+	img := &domain.Image{ID: id, TenantID: "acme", Name: "a.png", Status: domain.Processed}
+	original := writeFile(t, cfg.StoragePathConfig.InputDir, img.OriginalKey())
+	storage.On("GetImageWithDeleted", "globex", id.String()).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	assert.ErrorIs(t, service.HardDeleteImage("globex", id.String()), domain.ErrImageNotFound)
+	assert.FileExists(t, original)
+	storage.AssertNotCalled(t, "PurgeImage", mock.Anything, mock.Anything)
+}
+
+func TestPurgeImage_KeepsSharedFiles(t *testing.T) {
+	storage := new(MockStorage)
+	service, cfg := trashService(t, storage, nil)
+	id := uuid.New()
+
+	img := &domain.Image{
+		ID:     id,
+		Name:   "a.png",
+		Status: domain.Deleted,
+		Source: &domain.FileInfo{Key: "ab/cd/abcd.png"},
+		Output: &domain.FileInfo{Key: "ef/01/ef01.png"},
+	}
+	original := writeFile(t, cfg.StoragePathConfig.InputDir, img.OriginalKey())
+	output := writeFile(t, cfg.StoragePathConfig.OutputDir, img.OutputKey())
+	// проверка ссылок идёт под блокировкой ключа, иначе загрузка того же содержимого могла бы сослаться на удаляемый файл
+	storage.On("OriginalInUse", "ab/cd/abcd.png", id.String()).Return(true, nil).Run(func(mock.Arguments) {
+		assert.True(t, storage.isHeld("ab/cd/abcd.png"))
+	})
+	storage.On("OutputInUse", "ef/01/ef01.png", id.String()).Return(false, nil).Run(func(mock.Arguments) {
+		assert.True(t, storage.isHeld("ef/01/ef01.png"))
+	})
+	storage.On("PurgeImage", "", id.String()).Return(nil)
+
+	assert.NoError(t, service.PurgeImage(context.Background(), img))
+	assert.FileExists(t, original)
+	assert.NoFileExists(t, output)
+	assert.Empty(t, storage.held)
+}
+
+func TestPurgeImage_KeepsRowOnFileError(t *testing.T) {
+	storage := new(MockStorage)
+	service, _ := trashService(t, storage, nil)
+	id := uuid.New()
+
+	img := &domain.Image{ID: id, Name: "a.png", Source: &domain.FileInfo{Key: "ab/cd/abcd.png"}}
+	storage.On("OriginalInUse", mock.Anything, id.String()).Return(false, errors.New("db down"))
+
+	assert.Error(t, service.PurgeImage(context.Background(), img))
+	storage.AssertNotCalled(t, "PurgeImage", mock.Anything, mock.Anything)
+}
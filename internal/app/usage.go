@@ -0,0 +1,31 @@
+package app
+
+import (
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+)
+
+// GetUsage возвращает использование арендатора и его квоты
+func (s *ImageService) GetUsage(tenant string) (*domain.TenantUsage, error) {
+	usage, err := s.repo.GetUsage(tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get tenant usage from storage")
+		return nil, err
+	}
+	return domain.NewTenantUsage(*usage, domain.QuotaFor(&s.config.QuotaConfig, tenant)), nil
+}
+
+// CheckQuota проверяет, что в квоты арендатора укладывается ещё одно изображение с исходником size байт, —
+// для отказа до приёма файла; окончательно квота проверяется при сохранении записи
+func (s *ImageService) CheckQuota(tenant string, size int64) error {
+	usage, err := s.repo.GetUsage(tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get tenant usage from storage")
+		return err
+	}
+	if err := domain.QuotaFor(&s.config.QuotaConfig, tenant).Check(*usage, size); err != nil {
+		wbzlog.Logger.Warn().Err(err).Str("tenant", tenant).Msg("Rejected upload over tenant quota")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"testing"
+)
+
+func TestGetUsage(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{QuotaConfig: config.QuotaConfig{MaxBytes: 1000, MaxImages: 10}}
+	service := NewImageService(storage, nil, newMockEvents(), nil, cfg)
+
+	storage.On("GetUsage", "team-a").Return(&domain.Usage{Tenant: "team-a", Images: 2, OriginalBytes: 300, ProcessedBytes: 100}, nil)
+
+	usage, err := service.GetUsage("team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), usage.Bytes)
+	assert.Equal(t, int64(2), usage.Images)
+	assert.Equal(t, domain.QuotaLimits{MaxBytes: 1000, MaxImages: 10}, usage.Limits)
+}
+
+func TestGetUsage_RepoError(t *testing.T) {
+	storage := new(MockStorage)
+	service := NewImageService(storage, nil, newMockEvents(), nil, &config.AppConfig{})
+
+	storage.On("GetUsage", "").Return((*domain.Usage)(nil), errors.New("db down"))
+
+	_, err := service.GetUsage("")
+	assert.Error(t, err)
+}
+
+func TestCheckQuota(t *testing.T) {
+	storage := new(MockStorage)
+	cfg := &config.AppConfig{QuotaConfig: config.QuotaConfig{MaxBytes: 1000}}
+	service := NewImageService(storage, nil, newMockEvents(), nil, cfg)
+
+	storage.On("GetUsage", "team-a").Return(&domain.Usage{Tenant: "team-a", OriginalBytes: 900}, nil)
+
+	assert.NoError(t, service.CheckQuota("team-a", 100))
+	err := service.CheckQuota("team-a", 101)
+	var uploadErr *domain.UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, domain.CodeQuotaExceeded, uploadErr.Code)
+}
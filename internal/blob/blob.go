@@ -0,0 +1,79 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"io"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Info — сведения об объекте; Key — ключ относительно хранилища
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Object — открытый объект: чтение с произвольной позиции нужно для ответов на Range
+type Object struct {
+	io.ReadSeekCloser
+	Info Info
+}
+
+// Store — хранилище файлов, общее для API и воркеров
+type Store interface {
+	// Put записывает объект целиком; size < 0 — размер заранее неизвестен
+	Put(ctx context.Context, key string, r io.Reader, size int64) (*Info, error)
+	Get(ctx context.Context, key string) (*Object, error)
+	Stat(ctx context.Context, key string) (*Info, error)
+	// Delete удаляет объект, отсутствие объекта ошибкой не считается
+	Delete(ctx context.Context, key string) error
+	// List вызывает fn для каждого объекта с префиксом prefix; ошибка fn прерывает обход
+	List(ctx context.Context, prefix string, fn func(Info) error) error
+}
+
+// Stores — хранилища исходников и результатов обработки, ключ объекта — имя изображения
+type Stores struct {
+	Originals Store
+	Processed Store
+}
+
+// NewStores выбирает backend по blob.backend: local — каталоги storage_path, s3 — S3-совместимое хранилище
+func NewStores(cfg *config.AppConfig) (*Stores, error) {
+	switch cfg.BlobConfig.Backend {
+	case "", BackendLocal:
+		return NewLocalStores(cfg), nil
+	case BackendS3:
+		s3cfg := cfg.BlobConfig.S3
+		originals, err := NewS3(s3cfg, s3cfg.OriginalsPrefix)
+		if err != nil {
+			return nil, err
+		}
+		processed, err := NewS3(s3cfg, s3cfg.ProcessedPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return &Stores{Originals: originals, Processed: processed}, nil
+	default:
+		return nil, fmt.Errorf("unknown blob backend: %s", cfg.BlobConfig.Backend)
+	}
+}
+
+func NewLocalStores(cfg *config.AppConfig) *Stores {
+	return &Stores{
+		Originals: NewLocal(cfg.StoragePathConfig.InputDir),
+		Processed: NewLocal(cfg.StoragePathConfig.OutputDir),
+	}
+}
@@ -0,0 +1,107 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// Content — результат PutContent: объект, hex SHA-256 содержимого и признак того, что объект записан этим вызовом
+type Content struct {
+	Info    Info
+	SHA256  string
+	Created bool
+
+	release func()
+}
+
+// Locker блокирует ключ объекта, адресованного по содержимому, в том числе между репликами. Загрузка держит
+// блокировку от поиска объекта до сохранения ссылки на него, удаление — от проверки ссылок до удаления файла,
+// поэтому объект не пропадает из-под загрузки, которая нашла его по хэшу
+type Locker interface {
+	LockBlob(ctx context.Context, key string) (func(), error)
+}
+
+// Release снимает блокировку ключа, взятую PutContent, — после того как ссылка на объект сохранена или объект
+// удалён. Повторный вызов ничего не делает
+func (c *Content) Release() {
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
+}
+
+// ContentKey — адрес объекта по содержимому, разложенный по двум уровням каталогов: ab/cd/abcd….ext
+func ContentKey(sum, ext string) string {
+	key := sum[:2] + "/" + sum[2:4] + "/" + sum
+	if ext = strings.TrimPrefix(ext, "."); ext != "" {
+		key += "." + ext
+	}
+	return key
+}
+
+// PutContent хэширует поток по мере копирования во временный файл и кладёт его под namespace+ContentKey;
+// если объект с таким содержимым уже есть, повторно он не записывается. namespace разделяет файлы
+// арендаторов, одинаковое содержимое разных арендаторов хранится отдельно. С locks ключ остаётся
+// заблокированным до Content.Release
+func PutContent(ctx context.Context, store Store, locks Locker, namespace string, r io.Reader, ext string) (*Content, error) {
+	tmp, err := os.CreateTemp("", "blob-content-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	return putContent(ctx, store, locks, namespace+ContentKey(sum, ext), sum, tmp, size)
+}
+
+// PutContentBytes — PutContent для уже собранного в памяти содержимого
+func PutContentBytes(ctx context.Context, store Store, locks Locker, namespace string, data []byte, ext string) (*Content, error) {
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	return putContent(ctx, store, locks, namespace+ContentKey(sum, ext), sum, bytes.NewReader(data), int64(len(data)))
+}
+
+func putContent(ctx context.Context, store Store, locks Locker, key, sum string, r io.ReadSeeker, size int64) (*Content, error) {
+	content := &Content{SHA256: sum}
+	if locks != nil {
+		unlock, err := locks.LockBlob(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		content.release = unlock
+	}
+	info, err := store.Stat(ctx, key)
+	if err == nil {
+		content.Info = *info
+		return content, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		content.Release()
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		content.Release()
+		return nil, err
+	}
+	info, err = store.Put(ctx, key, r, size)
+	if err != nil {
+		content.Release()
+		return nil, err
+	}
+	content.Info = *info
+	content.Created = true
+	return content, nil
+}
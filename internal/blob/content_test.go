@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContentKey(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	assert.Equal(t, "ab/ab/"+sum+".png", ContentKey(sum, "png"))
+	assert.Equal(t, "ab/ab/"+sum+".png", ContentKey(sum, ".png"))
+	assert.Equal(t, "ab/ab/"+sum, ContentKey(sum, ""))
+}
+
+func TestPutContent_Deduplicates(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+	hash := sha256.Sum256([]byte("image"))
+	sum := hex.EncodeToString(hash[:])
+
+	first, err := PutContent(ctx, store, nil, "", strings.NewReader("image"), "png")
+	assert.NoError(t, err)
+	assert.True(t, first.Created)
+	assert.Equal(t, sum, first.SHA256)
+	assert.Equal(t, ContentKey(sum, "png"), first.Info.Key)
+	assert.Equal(t, int64(5), first.Info.Size)
+
+	second, err := PutContentBytes(ctx, store, nil, "", []byte("image"), "png")
+	assert.NoError(t, err)
+	assert.False(t, second.Created)
+	assert.Equal(t, first.Info.Key, second.Info.Key)
+
+	obj, err := store.Get(ctx, first.Info.Key)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(obj)
+	_ = obj.Close()
+	assert.Equal(t, "image", string(data))
+}
+
+func TestPutContent_Namespace(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+	hash := sha256.Sum256([]byte("image"))
+	sum := hex.EncodeToString(hash[:])
+
+	shared, err := PutContentBytes(ctx, store, nil, "", []byte("image"), "png")
+	assert.NoError(t, err)
+
+	// то же содержимое в другом пространстве записывается отдельно
+	own, err := PutContentBytes(ctx, store, nil, "tenants/acme/", []byte("image"), "png")
+	assert.NoError(t, err)
+	assert.True(t, own.Created)
+	assert.Equal(t, "tenants/acme/"+ContentKey(sum, "png"), own.Info.Key)
+	assert.NotEqual(t, shared.Info.Key, own.Info.Key)
+}
+
+// fakeLocker запоминает заблокированные ключи, пока их не отпустят
+type fakeLocker struct {
+	held map[string]bool
+	err  error
+}
+
+func (l *fakeLocker) LockBlob(_ context.Context, key string) (func(), error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.held[key] = true
+	return func() { delete(l.held, key) }, nil
+}
+
+func TestPutContent_HoldsLockUntilRelease(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+	locks := &fakeLocker{held: make(map[string]bool)}
+
+	stored, err := PutContentBytes(ctx, store, locks, "", []byte("image"), "png")
+	assert.NoError(t, err)
+	// объект нельзя удалить, пока ссылка на него не сохранена
+	assert.True(t, locks.held[stored.Info.Key])
+	stored.Release()
+	stored.Release()
+	assert.Empty(t, locks.held)
+
+	locks.err = errors.New("db down")
+	_, err = PutContentBytes(ctx, store, locks, "", []byte("other"), "png")
+	assert.ErrorIs(t, err, locks.err)
+}
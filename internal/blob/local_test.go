@@ -0,0 +1,113 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal_PutGetStatDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocal(dir)
+
+	info, err := store.Put(ctx, "a.png", strings.NewReader("hello"), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "a.png", info.Key)
+	assert.Equal(t, int64(5), info.Size)
+
+	obj, err := store.Get(ctx, "a.png")
+	assert.NoError(t, err)
+	_, err = obj.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "ello", string(data))
+	assert.NoError(t, obj.Close())
+
+	stat, err := store.Stat(ctx, "a.png")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stat.Size)
+
+	assert.NoError(t, store.Delete(ctx, "a.png"))
+	assert.NoError(t, store.Delete(ctx, "a.png"))
+	_, err = store.Get(ctx, "a.png")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = store.Stat(ctx, "a.png")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestLocal_PutSizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocal(dir)
+
+	_, err := store.Put(context.Background(), "a.png", strings.NewReader("abc"), 10)
+	assert.Error(t, err)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestLocal_InvalidKey(t *testing.T) {
+	store := NewLocal(t.TempDir())
+	for _, key := range []string{"", ".", "..", "../etc/passwd", "/abs", "a//b", "a/", `a\b`, ".hidden", "a/.hidden"} {
+		_, err := store.Put(context.Background(), key, strings.NewReader("x"), 1)
+		assert.True(t, errors.Is(err, ErrInvalidKey), key)
+	}
+}
+
+func TestLocal_List(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocal(dir)
+	for _, key := range []string{"a1.png", "a2.png", "b.png", "ab/cd/abcd.png", "bc/de/bcde.png"} {
+		_, err := store.Put(ctx, key, strings.NewReader(key), -1)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".tmp"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp", "a3.png"), []byte("x"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".put-123"), []byte("x"), 0644))
+
+	var keys []string
+	err := store.List(ctx, "a", func(info Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a1.png", "a2.png", "ab/cd/abcd.png"}, keys)
+
+	keys = nil
+	err = store.List(ctx, "ab/", func(info Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ab/cd/abcd.png"}, keys)
+
+	stop := errors.New("stop")
+	err = store.List(ctx, "", func(info Info) error { return stop })
+	assert.Equal(t, stop, err)
+
+	err = NewLocal(filepath.Join(dir, "missing")).List(ctx, "", func(info Info) error { return nil })
+	assert.NoError(t, err)
+}
+
+func TestLocal_NestedKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocal(dir)
+
+	_, err := store.Put(ctx, "ab/cd/abcd.png", strings.NewReader("x"), 1)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "ab", "cd", "abcd.png"))
+
+	obj, err := store.Get(ctx, "ab/cd/abcd.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "ab/cd/abcd.png", obj.Info.Key)
+	assert.NoError(t, obj.Close())
+}
@@ -0,0 +1,283 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 хранит объекты в S3-совместимом хранилище под префиксом prefix.
+// Запросы подписываются AWS Signature V4, тело не хэшируется (UNSIGNED-PAYLOAD), чтобы загружать потоком
+type S3 struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	virtual  bool
+	signer   signer
+}
+
+func NewS3(cfg config.S3Config, prefix string) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("blob.s3.bucket is required")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid blob.s3.endpoint: %q", cfg.Endpoint)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		client:   &http.Client{Timeout: cfg.Timeout},
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		prefix:   prefix,
+		virtual:  cfg.VirtualHosted,
+		signer:   signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: region},
+	}, nil
+}
+
+// Put загружает объект одним запросом; S3 требует Content-Length, поэтому поток неизвестной длины
+// сначала записывается во временный файл
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) (*Info, error) {
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "blob-put-*")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		if size, err = io.Copy(tmp, r); err != nil {
+			return nil, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r = tmp
+	}
+	req, err := s.request(ctx, http.MethodPut, key, nil, io.NopCloser(r))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return &Info{Key: key, Size: size, ModTime: time.Now()}, nil
+}
+
+// Get возвращает объект, читаемый Range-запросами: Seek не скачивает пропущенные байты
+func (s *S3) Get(ctx context.Context, key string) (*Object, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{ReadSeekCloser: &rangeReader{ctx: ctx, s3: s, key: key, size: info.Size}, Info: *info}, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Info, error) {
+	req, err := s.request(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &Info{Key: key, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List постранично обходит ListObjectsV2; ключи возвращаются без префикса хранилища
+func (s *S3) List(ctx context.Context, prefix string, fn func(Info) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3 list: %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := Info{Key: strings.TrimPrefix(obj.Key, s.prefix), Size: obj.Size, ModTime: obj.LastModified}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// request строит подписанный запрос к объекту key (пустой key — запрос к бакету)
+func (s *S3) request(ctx context.Context, method, key string, query url.Values, body io.ReadCloser) (*http.Request, error) {
+	if key != "" && (strings.HasPrefix(key, "/") || strings.Contains(key, "..")) {
+		return nil, ErrInvalidKey
+	}
+	u := *s.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if s.virtual {
+		u.Host = s.bucket + "." + u.Host
+	} else {
+		path += "/" + s.bucket
+	}
+	if key != "" {
+		path += "/" + s.prefix + key
+	} else {
+		path += "/"
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.signer.sign(req, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var s3err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3err)
+	return nil, fmt.Errorf("s3 %s %s: %d %s %s", req.Method, req.URL.Path, resp.StatusCode, s3err.Code, s3err.Message)
+}
+
+// rangeReader читает объект с текущей позиции запросом с Range; Seek закрывает текущий ответ
+type rangeReader struct {
+	ctx    context.Context
+	s3     *S3
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		req, err := r.s3.request(r.ctx, http.MethodGet, r.key, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(r.offset, 10)+"-")
+		resp, err := r.s3.do(req)
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.offset + offset
+	case io.SeekEnd:
+		next = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+	if next != r.offset {
+		_ = r.Close()
+		r.offset = next
+	}
+	return next, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -3,12 +3,14 @@ package kafkaconsumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/segmentio/kafka-go"
 	wbkafka "github.com/wb-go/wbf/kafka"
 	wbretry "github.com/wb-go/wbf/retry"
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"imageProcessor/internal/app"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
 	"imageProcessor/internal/imgprocessor"
@@ -19,9 +21,9 @@ type KafkaConsumerService struct {
 	consumer *wbkafka.Consumer
 }
 
-func NewConsumer(cfg *config.AppConfig) *KafkaConsumerService {
+func NewConsumer(cfg *config.AppConfig, topic string) *KafkaConsumerService {
 	return &KafkaConsumerService{
-		consumer: wbkafka.NewConsumer(cfg.KafkaConfig.Brokers, cfg.KafkaConfig.Topic, cfg.KafkaConfig.Group_id),
+		consumer: wbkafka.NewConsumer(cfg.KafkaConfig.Brokers, topic, cfg.KafkaConfig.Group_id),
 	}
 }
 
@@ -29,18 +31,68 @@ func (c *KafkaConsumerService) Close() error {
 	return c.consumer.Close()
 }
 
-func Consuming(ctx context.Context, cfg *config.AppConfig, imageService *app.ImageService) {
+// lane — очередь одного приоритета: свой топик, консьюмер и канал сообщений
+type lane struct {
+	consumer *KafkaConsumerService
+	out      chan kafka.Message
+}
+
+func startLane(ctx context.Context, cfg *config.AppConfig, topic string) *lane {
+	l := &lane{
+		consumer: NewConsumer(cfg, topic),
+		out:      make(chan kafka.Message),
+	}
+	go l.consumer.consumer.StartConsuming(ctx, l.out, wbretry.Strategy{Attempts: cfg.RetrysConfig.Attempts, Delay: cfg.RetrysConfig.Delay, Backoff: cfg.RetrysConfig.Backoffs})
+	return l
+}
+
+func (l *lane) close() {
+	err := l.consumer.Close()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to close kafka consumer")
+	}
+}
+
+// workerSplit делит воркеров между интерактивной и bulk-очередями пропорционально весам,
+// оставляя каждой очереди хотя бы одного воркера. Единственный воркер — bulk: он берёт задачи обеих
+// очередей, отдавая приоритет интерактивной, поэтому ни одна очередь не простаивает
+func workerSplit(total, interactiveWeight, bulkWeight int) (int, int) {
+	if total < 2 {
+		return 0, 1
+	}
+	if interactiveWeight <= 0 {
+		interactiveWeight = 1
+	}
+	if bulkWeight <= 0 {
+		bulkWeight = 1
+	}
+	interactive := total * interactiveWeight / (interactiveWeight + bulkWeight)
+	if interactive < 1 {
+		interactive = 1
+	}
+	if interactive > total-1 {
+		interactive = total - 1
+	}
+	return interactive, total - interactive
+}
+
+func Consuming(ctx context.Context, cfg *config.AppConfig, blobs *blob.Stores, imageService *app.ImageService) {
 	var wg sync.WaitGroup
-	out := make(chan kafka.Message)
-	consumer := NewConsumer(cfg)
-	defer func() {
-		err := consumer.Close()
-		if err != nil {
-			wbzlog.Logger.Error().Err(err).Msg("failed to close kafka consumer")
-		}
-	}()
-	go consumer.consumer.StartConsuming(ctx, out, wbretry.Strategy{Attempts: cfg.RetrysConfig.Attempts, Delay: cfg.RetrysConfig.Delay, Backoff: cfg.RetrysConfig.Backoffs})
-	for i := 0; i < cfg.KafkaConfig.Consumer_worker_count; i++ {
+	interactive := startLane(ctx, cfg, cfg.KafkaConfig.Topic)
+	defer interactive.close()
+	bulk := startLane(ctx, cfg, cfg.KafkaConfig.BulkTopic)
+	defer bulk.close()
+
+	if cfg.KafkaConfig.Consumer_worker_count < 1 {
+		wbzlog.Logger.Warn().Int("consumer_worker_count", cfg.KafkaConfig.Consumer_worker_count).Msg("Kafka worker count must be positive, starting one worker")
+	}
+	interactiveWorkers, bulkWorkers := workerSplit(cfg.KafkaConfig.Consumer_worker_count, cfg.KafkaConfig.InteractiveWeight, cfg.KafkaConfig.BulkWeight)
+	wbzlog.Logger.Info().Msgf("Kafka workers: interactive %d, bulk %d", interactiveWorkers, bulkWorkers)
+	// бюджет памяти общий для всех воркеров: крупные изображения обрабатываются по очереди, а не одновременно
+	budget := imgprocessor.NewMemoryBudget(cfg.UploadConfig.WorkerMemoryBudget)
+
+	// интерактивные воркеры берут только интерактивные задачи, поэтому bulk-импорт не может их занять
+	for i := 0; i < interactiveWorkers; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
@@ -49,38 +101,110 @@ func Consuming(ctx context.Context, cfg *config.AppConfig, imageService *app.Ima
 				case <-ctx.Done():
 					wbzlog.Logger.Info().Msg(fmt.Sprintf("Worker %d stopping...", workerID))
 					return
-				case msg, ok := <-out:
+				case msg, ok := <-interactive.out:
 					if !ok {
 						wbzlog.Logger.Info().Msg("Consumer channel closed, worker stopping")
 						return
 					}
-					err := imageService.SetProcessing(string(msg.Key))
-					if err != nil {
-						wbzlog.Logger.Error().Err(err).Msg("failed to update image status to processing")
-						continue
-					}
-					var task domain.Image
-					if err := json.Unmarshal(msg.Value, &task); err != nil {
-						wbzlog.Logger.Error().Err(err).Msg("invalid task in kafka consumer")
-						continue
-					}
-					err = imgprocessor.Process(cfg, &task)
-					if err != nil {
-						wbzlog.Logger.Error().Err(err).Msg("image processing error")
+					handleMessage(ctx, cfg, blobs, budget, imageService, interactive.consumer, msg)
+				}
+			}
+		}(i + 1)
+	}
+
+	// bulk-воркеры в первую очередь помогают интерактивной очереди, если в ней есть сообщения
+	for i := 0; i < bulkWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for {
+				select {
+				case msg, ok := <-interactive.out:
+					if ok {
+						handleMessage(ctx, cfg, blobs, budget, imageService, interactive.consumer, msg)
 						continue
 					}
-					err = imageService.SetProcessed(string(msg.Key))
-					if err != nil {
-						wbzlog.Logger.Error().Err(err).Msg("failed to update image status to processed")
-						continue
+				default:
+				}
+				select {
+				case <-ctx.Done():
+					wbzlog.Logger.Info().Msg(fmt.Sprintf("Worker %d stopping...", workerID))
+					return
+				case msg, ok := <-interactive.out:
+					if !ok {
+						wbzlog.Logger.Info().Msg("Consumer channel closed, worker stopping")
+						return
 					}
-					err = consumer.consumer.Commit(ctx, msg)
-					if err != nil {
-						wbzlog.Logger.Error().Err(err).Msg("failed to commit message")
+					handleMessage(ctx, cfg, blobs, budget, imageService, interactive.consumer, msg)
+				case msg, ok := <-bulk.out:
+					if !ok {
+						wbzlog.Logger.Info().Msg("Consumer channel closed, worker stopping")
+						return
 					}
+					handleMessage(ctx, cfg, blobs, budget, imageService, bulk.consumer, msg)
 				}
 			}
-		}(i + 1)
+		}(interactiveWorkers + i + 1)
 	}
 	wg.Wait()
 }
+
+func handleMessage(ctx context.Context, cfg *config.AppConfig, blobs *blob.Stores, budget *imgprocessor.MemoryBudget, imageService *app.ImageService, consumer *KafkaConsumerService, msg kafka.Message) {
+	// арендатор задачи нужен уже для смены статуса, поэтому задача разбирается первой
+	var task domain.Image
+	if err := json.Unmarshal(msg.Value, &task); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("invalid task in kafka consumer")
+		return
+	}
+	err := imageService.SetProcessing(task.TenantID, string(msg.Key))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to update image status to processing")
+		if isStaleTask(err) {
+			commit(ctx, consumer, msg)
+		}
+		return
+	}
+	jobCtx, done := imageService.JobContext(ctx, string(msg.Key))
+	output, release, err := imgprocessor.Process(jobCtx, cfg, blobs, imageService, budget, &task)
+	done()
+	if err != nil && errors.Is(err, context.Canceled) {
+		if ctx.Err() != nil {
+			// остановка сервиса: не подтверждаем, задачу доделает следующий запуск
+			return
+		}
+		wbzlog.Logger.Info().Str("image_id", string(msg.Key)).Msg("image processing cancelled")
+		commit(ctx, consumer, msg)
+		return
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("image processing error")
+		if err := imageService.SetFailed(task.TenantID, string(msg.Key), err.Error()); err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("failed to update image status to failed")
+		}
+		commit(ctx, consumer, msg)
+		return
+	}
+	err = imageService.SetProcessed(task.TenantID, string(msg.Key), output)
+	release()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to update image status to processed")
+		if !isStaleTask(err) {
+			return
+		}
+	}
+	commit(ctx, consumer, msg)
+}
+
+func commit(ctx context.Context, consumer *KafkaConsumerService, msg kafka.Message) {
+	err := consumer.consumer.Commit(ctx, msg)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to commit message")
+	}
+}
+
+// isStaleTask сообщает, что задача больше не актуальна (изображение удалено, отменено
+// или уже в конечном статусе) и сообщение можно подтвердить без обработки
+func isStaleTask(err error) bool {
+	var trErr *domain.TransitionError
+	return errors.As(err, &trErr) || errors.Is(err, domain.ErrImageNotFound)
+}
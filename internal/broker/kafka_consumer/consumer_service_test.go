@@ -0,0 +1,32 @@
+package kafkaconsumer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWorkerSplit(t *testing.T) {
+	tests := []struct {
+		name              string
+		total             int
+		interactiveWeight int
+		bulkWeight        int
+		interactive       int
+		bulk              int
+	}{
+		{"default weights", 4, 3, 1, 3, 1},
+		{"even weights", 6, 1, 1, 3, 3},
+		{"bulk heavy keeps one interactive", 4, 1, 10, 1, 3},
+		{"interactive heavy keeps one bulk", 4, 10, 1, 3, 1},
+		{"single worker serves both lanes", 1, 3, 1, 0, 1},
+		{"no workers configured", 0, 3, 1, 0, 1},
+		{"zero weights treated as equal", 4, 0, 0, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactive, bulk := workerSplit(tt.total, tt.interactiveWeight, tt.bulkWeight)
+			assert.Equal(t, tt.interactive, interactive)
+			assert.Equal(t, tt.bulk, bulk)
+		})
+	}
+}
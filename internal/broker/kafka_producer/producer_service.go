@@ -13,11 +13,13 @@ import (
 
 type KafkaProducerService struct {
 	producer *wbkafka.Producer
+	bulk     *wbkafka.Producer
+	events   *wbkafka.Producer
 	cfg      *config.AppConfig
 }
 
 func NewKafkaProducer(cfg *config.AppConfig) *KafkaProducerService {
-	wbzlog.Logger.Info().Msgf("Kafka brokers: %v, topic: %s", cfg.KafkaConfig.Brokers, cfg.KafkaConfig.Topic)
+	wbzlog.Logger.Info().Msgf("Kafka brokers: %v, topic: %s, events topic: %s", cfg.KafkaConfig.Brokers, cfg.KafkaConfig.Topic, cfg.KafkaConfig.EventsTopic)
 	conn, _ := kafka.Dial("tcp", "localhost:9092")
 	defer func() {
 		err := conn.Close()
@@ -26,21 +28,41 @@ func NewKafkaProducer(cfg *config.AppConfig) *KafkaProducerService {
 		}
 	}()
 
-	err := conn.CreateTopics(kafka.TopicConfig{
-		Topic:             cfg.KafkaConfig.Topic,
-		NumPartitions:     1,
-		ReplicationFactor: 1,
-	})
+	err := conn.CreateTopics(
+		kafka.TopicConfig{
+			Topic:             cfg.KafkaConfig.Topic,
+			NumPartitions:     1,
+			ReplicationFactor: 1,
+		},
+		kafka.TopicConfig{
+			Topic:             cfg.KafkaConfig.BulkTopic,
+			NumPartitions:     1,
+			ReplicationFactor: 1,
+		},
+		kafka.TopicConfig{
+			Topic:             cfg.KafkaConfig.EventsTopic,
+			NumPartitions:     1,
+			ReplicationFactor: 1,
+		},
+	)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("failed to create kafka topic")
 	}
 	return &KafkaProducerService{
 		producer: wbkafka.NewProducer(cfg.KafkaConfig.Brokers, cfg.KafkaConfig.Topic),
+		bulk:     wbkafka.NewProducer(cfg.KafkaConfig.Brokers, cfg.KafkaConfig.BulkTopic),
+		events:   wbkafka.NewProducer(cfg.KafkaConfig.Brokers, cfg.KafkaConfig.EventsTopic),
 		cfg:      cfg,
 	}
 }
 
 func (k *KafkaProducerService) Close() error {
+	if err := k.events.Close(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to close kafka events producer")
+	}
+	if err := k.bulk.Close(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to close kafka bulk producer")
+	}
 	return k.producer.Close()
 }
 
@@ -50,8 +72,12 @@ func (k *KafkaProducerService) CreateMessage(img *domain.Image) error {
 		wbzlog.Logger.Error().Err(err).Msg("invalid message for kafka producer")
 		return err
 	}
+	producer := k.producer
+	if img.Priority == domain.PriorityBulk {
+		producer = k.bulk
+	}
 	ctx := context.Background()
-	err = k.producer.SendWithRetry(ctx,
+	err = producer.SendWithRetry(ctx,
 		wbretry.Strategy{Attempts: k.cfg.RetrysConfig.Attempts, Delay: k.cfg.RetrysConfig.Delay, Backoff: k.cfg.RetrysConfig.Backoffs},
 		[]byte(img.ID.String()),
 		msg)
@@ -61,3 +87,23 @@ func (k *KafkaProducerService) CreateMessage(img *domain.Image) error {
 	}
 	return nil
 }
+
+// PublishEvent отправляет событие смены статуса в топик событий, ключ — ID изображения,
+// чтобы события одного изображения попадали в одну партицию и сохраняли порядок
+func (k *KafkaProducerService) PublishEvent(event *domain.StatusEvent) error {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("invalid status event for kafka producer")
+		return err
+	}
+	ctx := context.Background()
+	err = k.events.SendWithRetry(ctx,
+		wbretry.Strategy{Attempts: k.cfg.RetrysConfig.Attempts, Delay: k.cfg.RetrysConfig.Delay, Backoff: k.cfg.RetrysConfig.Backoffs},
+		[]byte(event.ImageID.String()),
+		msg)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("bad send status event kafka producer")
+		return err
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	wbfconfig "github.com/wb-go/wbf/config"
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -17,6 +18,21 @@ type AppConfig struct {
 	GinConfig         ginConfig         `mapstructure:"gin"`
 	KafkaConfig       kafkaConfig       `mapstructure:"kafka"`
 	StoragePathConfig StoragePathConfig `mapstructure:"storage_path"`
+	WebhookConfig     WebhookConfig     `mapstructure:"webhook"`
+	UploadConfig      UploadConfig      `mapstructure:"upload"`
+	BatchConfig       BatchConfig       `mapstructure:"batch"`
+	TusConfig         TusConfig         `mapstructure:"tus"`
+	FetchConfig       FetchConfig       `mapstructure:"fetch"`
+	OriginalsConfig   OriginalsConfig   `mapstructure:"originals"`
+	CacheConfig       CacheConfig       `mapstructure:"cache"`
+	TrashConfig       TrashConfig       `mapstructure:"trash"`
+	GCConfig          GCConfig          `mapstructure:"gc"`
+	QuotaConfig       QuotaConfig       `mapstructure:"quota"`
+	AuthConfig        AuthConfig        `mapstructure:"auth"`
+	RateLimitConfig   RateLimitConfig   `mapstructure:"rate_limit"`
+	CORSConfig        CORSConfig        `mapstructure:"cors"`
+	SignedURLConfig   SignedURLConfig   `mapstructure:"signed_urls"`
+	BlobConfig        BlobConfig        `mapstructure:"blob"`
 	ImageFormats      ImageFormats      `mapstructure:",squash"`
 }
 
@@ -30,11 +46,349 @@ type StoragePathConfig struct {
 	OutputDir string `mapstructure:"output_dir" default:"./data/img/processed/"`
 }
 
+// UploadConfig — ограничения на загружаемые изображения, нулевое значение отключает ограничение.
+// WorkerMemoryBudget — память на декодирование изображений, общая для всех воркеров процесса.
+type UploadConfig struct {
+	MaxBytes           int64   `mapstructure:"max_bytes" default:"52428800"`
+	MaxWidth           int     `mapstructure:"max_width" default:"10000"`
+	MaxHeight          int     `mapstructure:"max_height" default:"10000"`
+	MaxMegapixels      float64 `mapstructure:"max_megapixels" default:"50"`
+	WorkerMemoryBudget int64   `mapstructure:"worker_memory_budget" default:"1073741824"`
+}
+
+type BatchConfig struct {
+	MaxFiles int `mapstructure:"max_files" default:"100"`
+}
+
+// TusConfig — возобновляемые загрузки; загрузка, к которой не обращались дольше Expiration, удаляется
+// фоновой очисткой раз в SweepInterval
+type TusConfig struct {
+	MaxSize       int64         `mapstructure:"max_size" default:"1073741824"`
+	Expiration    time.Duration `mapstructure:"expiration" default:"24h"`
+	SweepInterval time.Duration `mapstructure:"sweep_interval" default:"1h"`
+}
+
+// FetchConfig — загрузка исходников по URL; Allowlist — подсети (CIDR или IP), доступ к которым
+// разрешён несмотря на запрет приватных и служебных адресов
+type FetchConfig struct {
+	Timeout      time.Duration `mapstructure:"timeout" default:"10s"`
+	MaxSize      int64         `mapstructure:"max_size" default:"52428800"`
+	MaxRedirects int           `mapstructure:"max_redirects" default:"3"`
+	Allowlist    []string      `mapstructure:"allowlist"`
+}
+
+// OriginalsConfig — доступ к исходникам и срок их хранения. Retention: forever — хранить всегда,
+// after_processing — удалять после успешной обработки, days — удалять через RetentionDays дней.
+// Token (ORIGINALS_TOKEN) требуется для скачивания исходника, без него скачивание выключено
+type OriginalsConfig struct {
+	Token           string        `mapstructure:"-"`
+	Retention       string        `mapstructure:"retention" default:"forever"`
+	RetentionDays   int           `mapstructure:"retention_days" default:"30"`
+	JanitorInterval time.Duration `mapstructure:"janitor_interval" default:"10m"`
+	BatchSize       int           `mapstructure:"batch_size" default:"100"`
+}
+
+// TrashConfig — корзина удалённых изображений: в течение Period их можно восстановить, затем фоновая
+// задача раз в PurgeInterval удаляет файлы и запись. Нулевой PurgeInterval выключает очистку
+type TrashConfig struct {
+	Period        time.Duration `mapstructure:"period" default:"720h"`
+	PurgeInterval time.Duration `mapstructure:"purge_interval" default:"10m"`
+	BatchSize     int           `mapstructure:"batch_size" default:"100"`
+}
+
+// GCConfig — сверка хранилища с таблицей images: файлы без записи удаляются, записи без файлов попадают в отчёт.
+// Файлы моложе GracePeriod не трогаются — запись о загрузке появляется после файла. Нулевой Interval
+// выключает фоновую сверку. По умолчанию DryRun — только отчёт; удаление включается явным dry_run: false
+type GCConfig struct {
+	Interval    time.Duration `mapstructure:"interval" default:"24h"`
+	GracePeriod time.Duration `mapstructure:"grace_period" default:"1h"`
+	BatchSize   int           `mapstructure:"batch_size" default:"500"`
+	DryRun      bool          `mapstructure:"dry_run" default:"true"`
+}
+
+// QuotaConfig — квоты арендатора на объём исходников и результатов и на число изображений;
+// нулевое значение снимает ограничение. Tenants переопределяет квоты отдельных арендаторов
+type QuotaConfig struct {
+	MaxBytes  int64         `mapstructure:"max_bytes" default:"0"`
+	MaxImages int64         `mapstructure:"max_images" default:"0"`
+	Tenants   []TenantQuota `mapstructure:"tenants"`
+}
+
+// TenantQuota — квота одного арендатора; задаётся списком, т.к. ключи словарей в конфиге приводятся к нижнему регистру
+type TenantQuota struct {
+	Tenant    string `mapstructure:"tenant"`
+	MaxBytes  int64  `mapstructure:"max_bytes"`
+	MaxImages int64  `mapstructure:"max_images"`
+}
+
+// AuthConfig — аутентификация арендаторов: Tokens сопоставляет bearer-токен идентификатору арендатора
+// и задаётся в TENANT_TOKENS как "tenant:token,tenant:token", OIDC — проверка JWT провайдера единого входа.
+// Required (по умолчанию включён) не даёт запустить сервис без токенов и OIDC; только с явным required: false
+// сервис работает с одним арендатором по умолчанию ("") и не требует аутентификации
+type AuthConfig struct {
+	Required bool              `mapstructure:"required" default:"true"`
+	Tokens   map[string]string `mapstructure:"-"`
+	OIDC     OIDCConfig        `mapstructure:"oidc"`
+}
+
+// Enabled сообщает, что запросы к API должны предъявлять токен арендатора
+func (c AuthConfig) Enabled() bool {
+	return len(c.Tokens) > 0 || c.OIDC.Enabled()
+}
+
+// validate не даёт случайно открыть загрузку и удаление без аутентификации: без TENANT_TOKENS и OIDC
+// первые ключи API выпустить некому
+func (c AuthConfig) validate() error {
+	if c.Required && !c.Enabled() {
+		return fmt.Errorf("auth.required needs TENANT_TOKENS or auth.oidc.issuer, set auth.required: false to run without authentication")
+	}
+	return c.OIDC.validate()
+}
+
+// CORSConfig — источники (scheme://host[:port]), которым браузер разрешает читать ответы API;
+// "*" разрешает любой источник, пустой список выключает CORS
+type CORSConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+}
+
+// OIDCConfig — JWT провайдера единого входа: подпись проверяется ключами JWKS из JWKSURL или JWKSFile,
+// которые перечитываются раз в JWKSRefresh и при встрече неизвестного kid, но не чаще JWKSMinRefresh.
+// TenantClaim и ScopesClaim — claims с арендатором и правами (строка через пробел или массив).
+// Пустой Issuer выключает проверку JWT
+type OIDCConfig struct {
+	Issuer         string        `mapstructure:"issuer"`
+	Audience       string        `mapstructure:"audience"`
+	JWKSURL        string        `mapstructure:"jwks_url"`
+	JWKSFile       string        `mapstructure:"jwks_file"`
+	JWKSRefresh    time.Duration `mapstructure:"jwks_refresh" default:"1h"`
+	JWKSMinRefresh time.Duration `mapstructure:"jwks_min_refresh" default:"30s"`
+	Timeout        time.Duration `mapstructure:"timeout" default:"10s"`
+	Leeway         time.Duration `mapstructure:"leeway" default:"1m"`
+	TenantClaim    string        `mapstructure:"tenant_claim" default:"tenant"`
+	ScopesClaim    string        `mapstructure:"scopes_claim" default:"scope"`
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// validate проверяет, что у включённой проверки JWT есть аудитория и ровно один источник ключей
+func (c OIDCConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Audience == "" {
+		return fmt.Errorf("auth.oidc.audience is required")
+	}
+	if (c.JWKSURL == "") == (c.JWKSFile == "") {
+		return fmt.Errorf("exactly one of auth.oidc.jwks_url and auth.oidc.jwks_file is required")
+	}
+	if c.TenantClaim == "" {
+		return fmt.Errorf("auth.oidc.tenant_claim is required")
+	}
+	return nil
+}
+
+// tenantIDPattern — идентификатор арендатора входит в пути хранилища, поэтому допускаются только безопасные символы
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidTenantID сообщает, что идентификатор арендатора допустим
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+// placeholderPrefix — начало значений-заглушек из .env.example: скопированный без правки файл
+// не должен запускать сервис с общеизвестными токенами
+const placeholderPrefix = "change-me"
+
+func isPlaceholder(value string) bool {
+	return strings.HasPrefix(strings.ToLower(value), placeholderPrefix)
+}
+
+// parseTenantTokens разбирает TENANT_TOKENS; один токен не может принадлежать двум арендаторам
+func parseTenantTokens(raw string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tenant, token, ok := strings.Cut(pair, ":")
+		if !ok || token == "" {
+			// сама запись может оказаться токеном, поэтому в ошибку не попадает
+			return nil, fmt.Errorf("invalid tenant token entry: expected tenant:token")
+		}
+		if !ValidTenantID(tenant) {
+			return nil, fmt.Errorf("invalid tenant id %q", tenant)
+		}
+		if isPlaceholder(token) {
+			return nil, fmt.Errorf("token of tenant %q is a placeholder from .env.example, generate a random one", tenant)
+		}
+		if other, exists := tokens[token]; exists && other != tenant {
+			return nil, fmt.Errorf("token of tenant %q is already used by tenant %q", tenant, other)
+		}
+		tokens[token] = tenant
+	}
+	return tokens, nil
+}
+
+// SignedURLConfig — подписанные ссылки на GET /api/image/{id} с ограниченным сроком действия. Ключи подписи
+// задаются в URL_SIGNING_KEYS как "kid:secret,kid:secret": подписывает первый, проверяются все, поэтому при ротации
+// новый ключ ставится первым, а старый удаляется не раньше чем через MaxTTL. Required требует подпись
+// у всех чтений файла изображения без токена арендатора
+type SignedURLConfig struct {
+	Keys       []SigningKey  `mapstructure:"-"`
+	DefaultTTL time.Duration `mapstructure:"default_ttl" default:"15m"`
+	MaxTTL     time.Duration `mapstructure:"max_ttl" default:"24h"`
+	Required   bool          `mapstructure:"required" default:"false"`
+}
+
+// SigningKey — ключ подписи ссылок; ID попадает в ссылку, чтобы при проверке выбрать нужный ключ
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Enabled сообщает, что ссылки можно подписывать
+func (c SignedURLConfig) Enabled() bool {
+	return len(c.Keys) > 0
+}
+
+func (c SignedURLConfig) validate() error {
+	if c.Required && !c.Enabled() {
+		return fmt.Errorf("signed_urls.required needs URL_SIGNING_KEYS")
+	}
+	if c.Enabled() && (c.DefaultTTL <= 0 || c.MaxTTL < c.DefaultTTL) {
+		return fmt.Errorf("signed_urls.default_ttl must be positive and not exceed signed_urls.max_ttl")
+	}
+	return nil
+}
+
+var signingKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// minSecretLen — секрет HMAC-SHA256 (ключи подписи ссылок, WEBHOOK_SECRET) короче 32 байт слабее самого HMAC
+const minSecretLen = 32
+
+// parseSigningKeys разбирает URL_SIGNING_KEYS, сохраняя порядок ключей
+func parseSigningKeys(raw string) ([]SigningKey, error) {
+	var keys []SigningKey
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || !signingKeyIDPattern.MatchString(id) {
+			// запись может содержать секрет, поэтому в ошибку не попадает
+			return nil, fmt.Errorf("invalid signing key entry: expected kid:secret with kid of [A-Za-z0-9_-]")
+		}
+		if len(secret) < minSecretLen {
+			return nil, fmt.Errorf("signing key %q is shorter than %d bytes", id, minSecretLen)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate signing key id %q", id)
+		}
+		seen[id] = true
+		keys = append(keys, SigningKey{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// RateLimitConfig — ограничение частоты запросов клиента (ключа API, пользователя единого входа, арендатора
+// или IP) token bucket'ами с отдельными бюджетами на загрузку, чтение и удаление. Store: memory — счётчики
+// в памяти реплики, postgres — общие для всех реплик. UploadConcurrency — сколько загрузок клиента
+// одновременно принимает одна реплика, 0 — без ограничения
+type RateLimitConfig struct {
+	Store             string        `mapstructure:"store" default:"memory"`
+	Upload            RateBudget    `mapstructure:"upload"`
+	Read              RateBudget    `mapstructure:"read"`
+	Delete            RateBudget    `mapstructure:"delete"`
+	Write             RateBudget    `mapstructure:"write"`
+	UploadConcurrency int           `mapstructure:"upload_concurrency" default:"4"`
+	SweepInterval     time.Duration `mapstructure:"sweep_interval" default:"10m"`
+}
+
+// RateBudget — Requests запросов за Period с запасом Burst на всплеск (по умолчанию равен Requests);
+// нулевое Requests снимает ограничение
+type RateBudget struct {
+	Requests int           `mapstructure:"requests"`
+	Period   time.Duration `mapstructure:"period" default:"1m"`
+	Burst    int           `mapstructure:"burst"`
+}
+
+// CacheConfig — HTTP-кэширование отдаваемых файлов; пустое значение не выставляет Cache-Control.
+// Результат обработки не меняется после завершения, поэтому его можно кэшировать как immutable.
+// Ответам на запросы с токеном или по подписанной ссылке public заменяется на private
+type CacheConfig struct {
+	ProcessedCacheControl string `mapstructure:"processed_cache_control" default:"public, max-age=31536000, immutable"`
+	OriginalCacheControl  string `mapstructure:"original_cache_control" default:"private, no-cache"`
+}
+
+// BlobConfig — где хранятся исходники и результаты: local — каталоги storage_path (API и воркеры
+// на одном диске), s3 — S3-совместимое хранилище (AWS S3, MinIO), общее для всех узлов
+type BlobConfig struct {
+	Backend string   `mapstructure:"backend" default:"local"`
+	S3      S3Config `mapstructure:"s3"`
+}
+
+// S3Config — ключи доступа берутся из S3_ACCESS_KEY и S3_SECRET_KEY. По умолчанию адресация
+// path-style (endpoint/bucket/key), как у MinIO; VirtualHosted включает bucket.endpoint/key
+type S3Config struct {
+	Endpoint        string        `mapstructure:"endpoint" default:"https://s3.amazonaws.com"`
+	Region          string        `mapstructure:"region" default:"us-east-1"`
+	Bucket          string        `mapstructure:"bucket"`
+	AccessKey       string        `mapstructure:"-"`
+	SecretKey       string        `mapstructure:"-"`
+	VirtualHosted   bool          `mapstructure:"virtual_hosted" default:"false"`
+	OriginalsPrefix string        `mapstructure:"originals_prefix" default:"original/"`
+	ProcessedPrefix string        `mapstructure:"processed_prefix" default:"processed/"`
+	Timeout         time.Duration `mapstructure:"timeout" default:"30s"`
+}
+
+// WebhookConfig — доставка webhook на callback_url, подписанных WEBHOOK_SECRET; без секрета webhook выключены
+// и callback_url не принимается. Приватные, служебные и локальные адреса запрещены,
+// Allowlist — подсети (CIDR или IP), куда доставка всё же разрешена
+type WebhookConfig struct {
+	Secret         string        `mapstructure:"-"`
+	Allowlist      []string      `mapstructure:"allowlist"`
+	PublicBaseURL  string        `mapstructure:"public_base_url" default:"http://localhost:8080"`
+	Timeout        time.Duration `mapstructure:"timeout" default:"5s"`
+	MaxAttempts    int           `mapstructure:"max_attempts" default:"5"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff" default:"5s"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff" default:"10m"`
+	PollInterval   time.Duration `mapstructure:"poll_interval" default:"2s"`
+	BatchSize      int           `mapstructure:"batch_size" default:"10"`
+}
+
+// Enabled сообщает, что задан секрет подписи и webhook можно отправлять
+func (c WebhookConfig) Enabled() bool {
+	return c.Secret != ""
+}
+
+// validate не пускает короткий или скопированный из .env.example секрет: подпись с ним может подделать кто угодно
+func (c WebhookConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if len(c.Secret) < minSecretLen {
+		return fmt.Errorf("WEBHOOK_SECRET must be at least %d characters", minSecretLen)
+	}
+	if isPlaceholder(c.Secret) {
+		return fmt.Errorf("WEBHOOK_SECRET is a placeholder from .env.example, generate a random one")
+	}
+	return nil
+}
+
 type kafkaConfig struct {
 	Brokers               []string `mapstructure:"brokers"`
 	Group_id              string   `mapstructure:"group_id"`
 	Topic                 string   `mapstructure:"topic"`
+	BulkTopic             string   `mapstructure:"bulk_topic" default:"image_events_bulk"`
+	EventsTopic           string   `mapstructure:"events_topic" default:"image_status_events"`
 	Consumer_worker_count int      `mapstructure:"consumer_worker_count" default:"4"`
+	InteractiveWeight     int      `mapstructure:"interactive_weight" default:"3"`
+	BulkWeight            int      `mapstructure:"bulk_weight" default:"1"`
 }
 
 type RetrysConfig struct {
@@ -47,9 +401,12 @@ type ginConfig struct {
 	Mode string `mapstructure:"mode" default:"debug"`
 }
 
+// ServerConfig — адрес HTTP-сервера. TrustedProxies — адреса и подсети обратных прокси, чьим X-Forwarded-For
+// и X-Real-IP можно верить; по умолчанию адрес клиента берётся из соединения
 type ServerConfig struct {
-	Host string `mapstructure:"host" default:"localhost"`
-	Port int    `mapstructure:"port" default:"8080"`
+	Host           string   `mapstructure:"host" default:"localhost"`
+	Port           int      `mapstructure:"port" default:"8080"`
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type loggerConfig struct {
@@ -93,6 +450,11 @@ func NewAppConfig() (*AppConfig, error) {
 		return nil, fmt.Errorf("failed to load config files: %w", err)
 	}
 
+	// без явного gc.dry_run: false фоновая сверка ничего не удаляет
+	cfg.SetDefault("gc.dry_run", true)
+	// без явного auth.required: false сервис не запускается без аутентификации
+	cfg.SetDefault("auth.required", true)
+
 	var appCfg AppConfig
 	if err := cfg.Unmarshal(&appCfg); err != nil {
 		wbzlog.Logger.Fatal().Err(err).Msg("Failed to unmarshal config")
@@ -102,7 +464,35 @@ func NewAppConfig() (*AppConfig, error) {
 	appCfg.DBConfig.Master.DBName = os.Getenv("POSTGRES_DB")
 	appCfg.DBConfig.Master.User = os.Getenv("POSTGRES_USER")
 	appCfg.DBConfig.Master.Password = os.Getenv("POSTGRES_PASSWORD")
+	appCfg.WebhookConfig.Secret = os.Getenv("WEBHOOK_SECRET")
+	if err := appCfg.WebhookConfig.validate(); err != nil {
+		wbzlog.Logger.Fatal().Err(err).Msg("Invalid webhook config")
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+	appCfg.OriginalsConfig.Token = os.Getenv("ORIGINALS_TOKEN")
+	appCfg.BlobConfig.S3.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	appCfg.BlobConfig.S3.SecretKey = os.Getenv("S3_SECRET_KEY")
 	appCfg.ImageFormats.SupportedFormats = configFormats(appCfg.ImageFormats.Formats)
+	tokens, err := parseTenantTokens(os.Getenv("TENANT_TOKENS"))
+	if err != nil {
+		wbzlog.Logger.Fatal().Err(err).Msg("Failed to parse TENANT_TOKENS")
+		return nil, fmt.Errorf("failed to parse TENANT_TOKENS: %w", err)
+	}
+	appCfg.AuthConfig.Tokens = tokens
+	if err := appCfg.AuthConfig.validate(); err != nil {
+		wbzlog.Logger.Fatal().Err(err).Msg("Invalid auth config")
+		return nil, fmt.Errorf("invalid auth config: %w", err)
+	}
+	signingKeys, err := parseSigningKeys(os.Getenv("URL_SIGNING_KEYS"))
+	if err != nil {
+		wbzlog.Logger.Fatal().Err(err).Msg("Failed to parse URL_SIGNING_KEYS")
+		return nil, fmt.Errorf("failed to parse URL_SIGNING_KEYS: %w", err)
+	}
+	appCfg.SignedURLConfig.Keys = signingKeys
+	if err := appCfg.SignedURLConfig.validate(); err != nil {
+		wbzlog.Logger.Fatal().Err(err).Msg("Invalid signed URL config")
+		return nil, fmt.Errorf("invalid signed url config: %w", err)
+	}
 	return &appCfg, nil
 }
 
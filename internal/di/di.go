@@ -6,31 +6,35 @@ import (
 	wbgin "github.com/wb-go/wbf/ginext"
 	"go.uber.org/fx"
 	"imageProcessor/internal/app"
+	"imageProcessor/internal/blob"
 	kafkaconsumer "imageProcessor/internal/broker/kafka_consumer"
 	kafkaproducer "imageProcessor/internal/broker/kafka_producer"
 	"imageProcessor/internal/config"
+	"imageProcessor/internal/janitor"
+	"imageProcessor/internal/oidc"
+	"imageProcessor/internal/ratelimit"
 	"imageProcessor/internal/storage/db"
+	"imageProcessor/internal/tus"
 	"imageProcessor/internal/web"
+	"imageProcessor/internal/webhook"
 	"log"
 	"net/http"
 )
 
-func StartHTTPServer(lc fx.Lifecycle, imageHandler *web.ImageHandler, config *config.AppConfig) {
+func StartHTTPServer(lc fx.Lifecycle, imageHandler *web.ImageHandler, tusHandler *web.TusHandler, keyHandler *web.KeyHandler, signedURLHandler *web.SignedURLHandler, limiter *ratelimit.Limiter, verifier *oidc.Verifier, config *config.AppConfig) error {
 	router := wbgin.New(config.GinConfig.Mode)
+	// адрес клиента ограничивает лимиты и IP-привязанные ссылки, поэтому X-Forwarded-For принимается только от своих прокси
+	if err := router.SetTrustedProxies(config.ServerConfig.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
 
 	router.Use(wbgin.Logger(), wbgin.Recovery())
-	router.Use(func(c *wbgin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	router.Use(web.CORS(config.CORSConfig))
+	if config.AuthConfig.OIDC.Enabled() {
+		router.Use(web.BearerJWT(verifier))
+	}
 
-	web.RegisterRoutes(router, imageHandler)
+	web.RegisterRoutes(router, imageHandler, tusHandler, keyHandler, signedURLHandler, limiter)
 
 	addres := fmt.Sprintf("%s:%d", config.ServerConfig.Host, config.ServerConfig.Port)
 	server := &http.Server{
@@ -53,6 +57,7 @@ func StartHTTPServer(lc fx.Lifecycle, imageHandler *web.ImageHandler, config *co
 			return server.Close()
 		},
 	})
+	return nil
 }
 
 func StartKafkaProducer(lc fx.Lifecycle, k *kafkaproducer.KafkaProducerService, s *app.ImageService) {
@@ -76,13 +81,13 @@ func StartKafkaProducer(lc fx.Lifecycle, k *kafkaproducer.KafkaProducerService,
 	})
 }
 
-func StartKafkaConsumer(lc fx.Lifecycle, cfg *config.AppConfig, s *app.ImageService) {
+func StartKafkaConsumer(lc fx.Lifecycle, cfg *config.AppConfig, blobs *blob.Stores, s *app.ImageService) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			log.Println("Start Kafka Consumer...")
 
 			consumerCtx, cancel := context.WithCancel(context.Background())
-			go kafkaconsumer.Consuming(consumerCtx, cfg, s)
+			go kafkaconsumer.Consuming(consumerCtx, cfg, blobs, s)
 
 			lc.Append(fx.Hook{
 				OnStop: func(ctx context.Context) error {
@@ -99,6 +104,139 @@ func StartKafkaConsumer(lc fx.Lifecycle, cfg *config.AppConfig, s *app.ImageServ
 	})
 }
 
+func StartWebhookDispatcher(lc fx.Lifecycle, d *webhook.Dispatcher) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Webhook Dispatcher...")
+
+			dispatcherCtx, cancel := context.WithCancel(context.Background())
+			go d.Run(dispatcherCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Webhook Dispatcher...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			log.Println("Webhook Dispatcher started successfully")
+			return nil
+		},
+	})
+}
+
+func StartRetentionJanitor(lc fx.Lifecycle, j *janitor.Janitor) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Originals Janitor...")
+
+			janitorCtx, cancel := context.WithCancel(context.Background())
+			go j.Run(janitorCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Originals Janitor...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			return nil
+		},
+	})
+}
+
+func StartTrashPurger(lc fx.Lifecycle, t *janitor.Trash) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Trash Purger...")
+
+			trashCtx, cancel := context.WithCancel(context.Background())
+			go t.Run(trashCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Trash Purger...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			return nil
+		},
+	})
+}
+
+func StartOrphanCollector(lc fx.Lifecycle, c *janitor.Collector) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Orphan File Collector...")
+
+			gcCtx, cancel := context.WithCancel(context.Background())
+			go c.Run(gcCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Orphan File Collector...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			return nil
+		},
+	})
+}
+
+func StartRateLimitSweeper(lc fx.Lifecycle, l *ratelimit.Limiter) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Rate Limit Sweeper...")
+
+			sweeperCtx, cancel := context.WithCancel(context.Background())
+			go l.Run(sweeperCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Rate Limit Sweeper...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			return nil
+		},
+	})
+}
+
+func StartTusSweeper(lc fx.Lifecycle, store *tus.Store) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Start Tus Sweeper...")
+
+			sweeperCtx, cancel := context.WithCancel(context.Background())
+			go store.Run(sweeperCtx)
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					log.Println("Stopping Tus Sweeper...")
+					cancel()
+
+					return nil
+				},
+			})
+
+			return nil
+		},
+	})
+}
+
 func ClosePostgresOnStop(lc fx.Lifecycle, postgres *db.Postgres) {
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Scope — право доступа API-ключа
+type Scope string
+
+const (
+	ScopeImagesRead   Scope = "images:read"
+	ScopeImagesWrite  Scope = "images:write"
+	ScopeImagesDelete Scope = "images:delete"
+	// ScopeAdmin включает все остальные права и управление ключами арендатора
+	ScopeAdmin Scope = "admin"
+)
+
+var AllScopes = []Scope{ScopeImagesRead, ScopeImagesWrite, ScopeImagesDelete, ScopeAdmin}
+
+const (
+	// apiKeyPrefix отличает ключи сервиса от других секретов, например при поиске утечек в репозиториях
+	apiKeyPrefix = "ipk_"
+	// apiKeyDisplayLen — сколько первых символов ключа хранится открыто, чтобы ключ можно было узнать в списке
+	apiKeyDisplayLen = 12
+	// APIKeyTouchInterval — точность last_used_at: чаще запись не обновляется, чтобы не писать в БД на каждый запрос
+	APIKeyTouchInterval = time.Minute
+	maxAPIKeyNameLen    = 100
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKey — некорректное имя или набор прав нового ключа
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// Scopes — набор прав; admin подразумевает любое право
+type Scopes []Scope
+
+func (s Scopes) Allows(required Scope) bool {
+	for _, scope := range s {
+		if scope == required || scope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes проверяет, что все права известны, и убирает повторы
+func ParseScopes(raw []string) (Scopes, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	scopes := make(Scopes, 0, len(raw))
+	seen := make(map[Scope]bool, len(raw))
+	for _, r := range raw {
+		scope := Scope(strings.TrimSpace(r))
+		if !knownScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, r)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// KnownScopes оставляет только известные права, например из claims токена провайдера с посторонними правами
+func KnownScopes(raw []string) Scopes {
+	scopes := make(Scopes, 0, len(raw))
+	for _, r := range raw {
+		if scope := Scope(r); knownScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func knownScope(scope Scope) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey — ключ доступа арендатора. Сам ключ не хранится: по нему ищут через SHA-256, ключи случайные
+// и достаточно длинные, поэтому медленный хэш паролей не нужен
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   string     `json:"tenant_id,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" example:"ipk_3q2-7w1Z"`
+	Hash       string     `json:"-"`
+	Scopes     Scopes     `json:"scopes" swaggertype:"array,string" example:"images:read,images:write"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey создаёт ключ и возвращает его вместе с секретом, который показывается клиенту один раз
+func NewAPIKey(tenant, name string, scopes Scopes) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return nil, "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKey, maxAPIKeyNameLen)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return &APIKey{
+		ID:        uuid.New(),
+		TenantID:  tenant,
+		Name:      name,
+		Prefix:    secret[:apiKeyDisplayLen],
+		Hash:      HashAPIKey(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}, secret, nil
+}
+
+// HashAPIKey — хэш, по которому ключ хранится и ищется
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey сообщает, что токен имеет формат ключа сервиса; другие токены не нужно искать в БД
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix) && len(token) > apiKeyDisplayLen
+}
+
+// NeedsTouch сообщает, что last_used_at устарел больше чем на APIKeyTouchInterval
+func (k *APIKey) NeedsTouch(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= APIKeyTouchInterval
+}
+
+// Principal — кто выполняет запрос: арендатор и его права. KeyID задан для ключей API, Subject — для JWT
+// провайдера единого входа, у статических токенов TENANT_TOKENS оба пусты. SignedURL — запрос
+// по подписанной ссылке, его предъявитель неизвестен
+type Principal struct {
+	Tenant    string
+	Scopes    Scopes
+	KeyID     string
+	Subject   string
+	SignedURL bool
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, secret, err := NewAPIKey("acme", " ci ", Scopes{ScopeImagesRead})
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(secret))
+	assert.Equal(t, "ci", key.Name)
+	assert.Equal(t, "acme", key.TenantID)
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.Equal(t, HashAPIKey(secret), key.Hash)
+	assert.NotContains(t, key.Hash, secret)
+
+	_, other, err := NewAPIKey("acme", "ci", Scopes{ScopeImagesRead})
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	_, _, err = NewAPIKey("acme", " ", Scopes{ScopeImagesRead})
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, _, err = NewAPIKey("acme", "ci", nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"images:read", "images:write", "images:read"})
+	assert.NoError(t, err)
+	assert.Equal(t, Scopes{ScopeImagesRead, ScopeImagesWrite}, scopes)
+
+	_, err = ParseScopes([]string{"images:read", "root"})
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, err = ParseScopes(nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestKnownScopes(t *testing.T) {
+	assert.Equal(t, Scopes{ScopeImagesRead, ScopeAdmin}, KnownScopes([]string{"openid", "images:read", "profile", "admin"}))
+	assert.Empty(t, KnownScopes(nil))
+}
+
+func TestScopesAllows(t *testing.T) {
+	reader := Scopes{ScopeImagesRead}
+	assert.True(t, reader.Allows(ScopeImagesRead))
+	assert.False(t, reader.Allows(ScopeImagesDelete))
+	assert.False(t, reader.Allows(ScopeAdmin))
+
+	admin := Scopes{ScopeAdmin}
+	for _, scope := range AllScopes {
+		assert.True(t, admin.Allows(scope))
+	}
+}
+
+func TestAPIKeyNeedsTouch(t *testing.T) {
+	now := time.Now()
+	key := &APIKey{}
+	assert.True(t, key.NeedsTouch(now))
+	recent := now.Add(-10 * time.Second)
+	key.LastUsedAt = &recent
+	assert.False(t, key.NeedsTouch(now))
+	stale := now.Add(-APIKeyTouchInterval)
+	key.LastUsedAt = &stale
+	assert.True(t, key.NeedsTouch(now))
+}
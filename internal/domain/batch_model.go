@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+// Batch группирует изображения одной пакетной загрузки
+type Batch struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Total     int       `json:"total"`
+}
+
+func NewBatch(tenant string, total int) *Batch {
+	return &Batch{
+		ID:        uuid.New(),
+		TenantID:  tenant,
+		CreatedAt: time.Now(),
+		Total:     total,
+	}
+}
+
+// BatchFile — один файл пакетной загрузки со своими параметрами обработки
+type BatchFile struct {
+	Filename string
+	Params   ImageParams
+	File     io.Reader
+}
+
+// BatchItemResult — результат загрузки одного файла пакета: либо изображение, либо ошибка
+type BatchItemResult struct {
+	Index     int             `json:"index"`
+	Filename  string          `json:"filename"`
+	Image     *Image          `json:"image,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ErrorCode UploadErrorCode `json:"error_code,omitempty"`
+}
+
+// BatchProgress — агрегированный прогресс обработки изображений пакета
+type BatchProgress struct {
+	Batch
+	Accepted int                `json:"accepted"`
+	Counts   map[StatusType]int `json:"counts"`
+	Finished int                `json:"finished"`
+	Progress float64            `json:"progress"`
+	Done     bool               `json:"done"`
+}
+
+// NewBatchProgress считает завершёнными изображения в конечных статусах;
+// пакет готов, когда ни одно принятое изображение не ждёт и не обрабатывается
+func NewBatchProgress(batch Batch, counts map[StatusType]int) *BatchProgress {
+	p := &BatchProgress{Batch: batch, Counts: counts}
+	for status, n := range counts {
+		p.Accepted += n
+		if status != Created && status != Processing {
+			p.Finished += n
+		}
+	}
+	if p.Accepted > 0 {
+		p.Progress = float64(p.Finished) / float64(p.Accepted)
+	}
+	p.Done = p.Finished == p.Accepted
+	return p
+}
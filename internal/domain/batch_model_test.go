@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewBatchProgress(t *testing.T) {
+	batch := *NewBatch("", 5)
+
+	p := NewBatchProgress(batch, map[StatusType]int{Processed: 2, Failed: 1, Processing: 1})
+	assert.Equal(t, 4, p.Accepted)
+	assert.Equal(t, 3, p.Finished)
+	assert.InDelta(t, 0.75, p.Progress, 0.0001)
+	assert.False(t, p.Done)
+
+	p = NewBatchProgress(batch, map[StatusType]int{Processed: 3, Cancelled: 1})
+	assert.True(t, p.Done)
+	assert.Equal(t, 1.0, p.Progress)
+
+	p = NewBatchProgress(batch, map[StatusType]int{})
+	assert.Equal(t, 0, p.Accepted)
+	assert.True(t, p.Done)
+}
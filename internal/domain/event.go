@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// EventSchemaVersion увеличивается при несовместимых изменениях StatusEvent
+const EventSchemaVersion = 1
+
+type EventType string
+
+const (
+	EventImageCreated    EventType = "image.created"
+	EventImageProcessing EventType = "image.processing"
+	EventImageProcessed  EventType = "image.processed"
+	EventImageFailed     EventType = "image.failed"
+	EventImageCancelled  EventType = "image.cancelled"
+	EventImageDeleted    EventType = "image.deleted"
+)
+
+// StatusEvent — событие смены статуса изображения, публикуемое для внешних подписчиков
+type StatusEvent struct {
+	SchemaVersion int        `json:"schema_version"`
+	EventID       uuid.UUID  `json:"event_id"`
+	Type          EventType  `json:"type"`
+	ImageID       uuid.UUID  `json:"image_id"`
+	Status        StatusType `json:"status"`
+	OccurredAt    time.Time  `json:"occurred_at"`
+}
+
+func NewStatusEvent(imageID uuid.UUID, status StatusType) *StatusEvent {
+	return &StatusEvent{
+		SchemaVersion: EventSchemaVersion,
+		EventID:       uuid.New(),
+		Type:          EventType("image." + string(status)),
+		ImageID:       imageID,
+		Status:        status,
+		OccurredAt:    time.Now().UTC(),
+	}
+}
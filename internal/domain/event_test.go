@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewStatusEvent(t *testing.T) {
+	id := uuid.New()
+	event := NewStatusEvent(id, Failed)
+
+	assert.Equal(t, EventImageFailed, event.Type)
+	assert.Equal(t, EventSchemaVersion, event.SchemaVersion)
+	assert.Equal(t, id, event.ImageID)
+	assert.NotEqual(t, uuid.Nil, event.EventID)
+
+	data, err := json.Marshal(event)
+	assert.NoError(t, err)
+
+	var raw map[string]any
+	assert.NoError(t, json.Unmarshal(data, &raw))
+	for _, key := range []string{"schema_version", "event_id", "type", "image_id", "status", "occurred_at"} {
+		assert.Contains(t, raw, key)
+	}
+}
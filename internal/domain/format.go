@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	_ "golang.org/x/image/tiff"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"imageProcessor/internal/config"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// decodedCopies — сколько полноразмерных RGBA-копий держит воркер при обработке (исходник, водяной знак, ресайз)
+const decodedCopies = 3
+
+// sniffLimit ограничивает объём, читаемый для разбора заголовка (у JPEG перед SOF бывают большие EXIF-сегменты)
+const sniffLimit = 1 << 20
+
+type UploadErrorCode string
+
+const (
+	CodeInvalidParams    UploadErrorCode = "invalid_params"
+	CodeUnsupportedMedia UploadErrorCode = "unsupported_media_type"
+	CodeFormatMismatch   UploadErrorCode = "format_mismatch"
+	CodeInvalidImage     UploadErrorCode = "invalid_image"
+	CodeFileTooLarge     UploadErrorCode = "file_too_large"
+	CodeImageTooLarge    UploadErrorCode = "image_too_large"
+	CodeQuotaExceeded    UploadErrorCode = "quota_exceeded"
+)
+
+// UploadError — загрузка отклонена до записи файла; Code — машинно-читаемая причина
+type UploadError struct {
+	Code           UploadErrorCode
+	Message        string
+	DetectedFormat string
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+// SourceInfo — формат и размеры загружаемого файла, прочитанные из заголовка
+type SourceInfo struct {
+	Format string
+	Width  int
+	Height int
+}
+
+// DetectFormat определяет формат по содержимому (сигнатура и разбор заголовка через image.DecodeConfig)
+// и сверяет его с расширением имени файла; без расширения используется обнаруженный формат.
+// Возвращённый reader заново отдаёт прочитанный заголовок, за ним — остаток r.
+func DetectFormat(filename string, r io.Reader, cfg *config.AppConfig) (*SourceInfo, io.Reader, error) {
+	var head bytes.Buffer
+	imgCfg, detected, err := image.DecodeConfig(io.TeeReader(io.LimitReader(r, sniffLimit), &head))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, nil, &UploadError{Code: CodeUnsupportedMedia, Message: "file content is not a supported image"}
+		}
+		return nil, nil, &UploadError{Code: CodeInvalidImage, Message: "failed to decode image header: " + err.Error()}
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	switch {
+	case format == "":
+		format = detected
+	case canonicalFormat(format) != detected:
+		return nil, nil, &UploadError{
+			Code:           CodeFormatMismatch,
+			Message:        fmt.Sprintf("file extension .%s does not match content (%s)", format, detected),
+			DetectedFormat: detected,
+		}
+	}
+	if !cfg.ImageFormats.SupportedFormats[format] {
+		return nil, nil, &UploadError{Code: CodeUnsupportedMedia, Message: "unsupported format:" + format, DetectedFormat: detected}
+	}
+	if err := CheckDimensions(imgCfg.Width, imgCfg.Height, cfg); err != nil {
+		return nil, nil, err
+	}
+	return &SourceInfo{Format: format, Width: imgCfg.Width, Height: imgCfg.Height}, io.MultiReader(&head, r), nil
+}
+
+// CheckDimensions сверяет заявленные в заголовке размеры с лимитами до полного декодирования,
+// чтобы маленький файл с огромным холстом не исчерпал память воркера
+func CheckDimensions(width, height int, cfg *config.AppConfig) error {
+	limits := cfg.UploadConfig
+	if (limits.MaxWidth > 0 && width > limits.MaxWidth) || (limits.MaxHeight > 0 && height > limits.MaxHeight) {
+		return &UploadError{
+			Code:    CodeImageTooLarge,
+			Message: fmt.Sprintf("image dimensions %dx%d exceed maximum %dx%d", width, height, limits.MaxWidth, limits.MaxHeight),
+		}
+	}
+	pixels := int64(width) * int64(height)
+	if limits.MaxMegapixels > 0 && float64(pixels) > limits.MaxMegapixels*1e6 {
+		return &UploadError{
+			Code:    CodeImageTooLarge,
+			Message: fmt.Sprintf("image has %.1f megapixels, maximum is %g", float64(pixels)/1e6, limits.MaxMegapixels),
+		}
+	}
+	if need := DecodeMemory(width, height); limits.WorkerMemoryBudget > 0 && need > limits.WorkerMemoryBudget {
+		return &UploadError{
+			Code:    CodeImageTooLarge,
+			Message: fmt.Sprintf("processing needs about %d MiB, worker budget is %d MiB", need>>20, limits.WorkerMemoryBudget>>20),
+		}
+	}
+	return nil
+}
+
+// DecodeMemory — оценка памяти, которую воркер держит при обработке изображения width×height
+func DecodeMemory(width, height int) int64 {
+	return int64(width) * int64(height) * 4 * decodedCopies
+}
+
+// canonicalFormat приводит расширение к имени формата из пакета image
+func canonicalFormat(ext string) string {
+	switch ext {
+	case "jpg":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	default:
+		return ext
+	}
+}
@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"imageProcessor/internal/config"
+	"io"
+	"testing"
+)
+
+func encodeImage(t *testing.T, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "tiff":
+		return grayTIFF(4, 3)
+	}
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+// grayTIFF собирает несжатый 8-битный TIFF вручную: кодировщик из x/image/tiff регистрировал бы
+// декодер сам и скрыл бы его отсутствие в пакете
+func grayTIFF(width, height uint32) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("II")
+	_ = binary.Write(&buf, le, uint16(42))
+	_ = binary.Write(&buf, le, uint32(8))
+	entries := [][3]uint32{ // тег, тип (3 — SHORT, 4 — LONG), значение
+		{256, 3, width}, {257, 3, height}, {258, 3, 8}, {259, 3, 1}, {262, 3, 1},
+		{273, 4, 8 + 2 + 8*12 + 4}, {278, 3, height}, {279, 4, width * height},
+	}
+	_ = binary.Write(&buf, le, uint16(len(entries)))
+	for _, e := range entries {
+		_ = binary.Write(&buf, le, uint16(e[0]))
+		_ = binary.Write(&buf, le, uint16(e[1]))
+		_ = binary.Write(&buf, le, uint32(1))
+		if e[1] == 3 {
+			_ = binary.Write(&buf, le, uint16(e[2]))
+			_ = binary.Write(&buf, le, uint16(0))
+		} else {
+			_ = binary.Write(&buf, le, e[2])
+		}
+	}
+	_ = binary.Write(&buf, le, uint32(0))
+	buf.Write(make([]byte, width*height))
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{SupportedFormats: map[string]bool{"png": true, "jpg": true, "jpeg": true, "tif": true, "tiff": true}},
+	}
+	pngData := encodeImage(t, "png")
+	jpegData := encodeImage(t, "jpeg")
+	tiffData := encodeImage(t, "tiff")
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		format   string
+		code     UploadErrorCode
+	}{
+		{"png", "a.png", pngData, "png", ""},
+		{"jpg extension", "photo.JPG", jpegData, "jpg", ""},
+		{"jpeg extension", "photo.jpeg", jpegData, "jpeg", ""},
+		{"no extension", "scan", jpegData, "jpeg", ""},
+		{"tif extension", "scan.tif", tiffData, "tif", ""},
+		{"tiff extension", "scan.TIFF", tiffData, "tiff", ""},
+		{"mismatch", "a.png", jpegData, "", CodeFormatMismatch},
+		{"not an image", "a.png", []byte("#!/bin/sh\nrm -rf /"), "", CodeUnsupportedMedia},
+		{"empty", "a.png", nil, "", CodeUnsupportedMedia},
+		{"corrupted header", "a.png", pngData[:20], "", CodeInvalidImage},
+		{"detected but not enabled", "a.gif", encodeImage(t, "gif"), "", CodeUnsupportedMedia},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, r, err := DetectFormat(tt.filename, bytes.NewReader(tt.data), cfg)
+			if tt.code != "" {
+				var uploadErr *UploadError
+				assert.True(t, errors.As(err, &uploadErr), "got %v", err)
+				assert.Equal(t, tt.code, uploadErr.Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, info.Format)
+			assert.Equal(t, 4, info.Width)
+			assert.Equal(t, 3, info.Height)
+			// прочитанный при разборе заголовок не теряется
+			all, _ := io.ReadAll(r)
+			assert.Equal(t, tt.data, all)
+		})
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	cfg := &config.AppConfig{UploadConfig: config.UploadConfig{MaxWidth: 1000, MaxHeight: 1000, MaxMegapixels: 0.5, WorkerMemoryBudget: 1 << 30}}
+	assert.NoError(t, ValidateParams(ImageParams{Resize: "10x10", Priority: "bulk"}, cfg))
+
+	err := ValidateParams(ImageParams{Priority: "urgent"}, cfg)
+	var uploadErr *UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, CodeInvalidParams, uploadErr.Code)
+
+	// результат ресайза ограничен теми же лимитами, что и исходник
+	for _, resize := range []string{"100000x100000", "1001x10", "800x800"} {
+		err = ValidateParams(ImageParams{Resize: resize}, cfg)
+		assert.True(t, errors.As(err, &uploadErr), resize)
+		assert.Equal(t, CodeImageTooLarge, uploadErr.Code, resize)
+	}
+}
+
+// pngHeader — PNG только с IHDR: заголовок заявляет размеры, а данных нет (как у decompression bomb)
+func pngHeader(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 бит, RGBA
+	chunk := append([]byte("IHDR"), ihdr...)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestDetectFormat_DecompressionBomb(t *testing.T) {
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+		UploadConfig: config.UploadConfig{MaxWidth: 10000, MaxHeight: 10000},
+	}
+	_, _, err := DetectFormat("bomb.png", bytes.NewReader(pngHeader(50000, 50000)), cfg)
+	var uploadErr *UploadError
+	assert.True(t, errors.As(err, &uploadErr), "got %v", err)
+	assert.Equal(t, CodeImageTooLarge, uploadErr.Code)
+}
+
+func TestCheckDimensions(t *testing.T) {
+	cfg := &config.AppConfig{
+		UploadConfig: config.UploadConfig{
+			MaxWidth:           8000,
+			MaxHeight:          6000,
+			MaxMegapixels:      24,
+			WorkerMemoryBudget: 200 << 20,
+		},
+	}
+	tests := []struct {
+		name          string
+		width, height int
+		ok            bool
+	}{
+		{"small", 1920, 1080, true},
+		{"too wide", 8001, 10, false},
+		{"too tall", 10, 6001, false},
+		{"too many megapixels", 7000, 5000, false},
+		{"over memory budget", 5000, 4000, false},
+		{"within budget", 4000, 4000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDimensions(tt.width, tt.height, cfg)
+			if tt.ok {
+				assert.NoError(t, err)
+				return
+			}
+			var uploadErr *UploadError
+			assert.True(t, errors.As(err, &uploadErr))
+			assert.Equal(t, CodeImageTooLarge, uploadErr.Code)
+		})
+	}
+	assert.NoError(t, CheckDimensions(100000, 100000, &config.AppConfig{}), "zero limits are disabled")
+}
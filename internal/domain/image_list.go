@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor — позиция keyset-пагинации: последняя выданная пара (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ImageFilter — условия выборки списка изображений; пустые поля не фильтруют.
+// Без явного статуса удалённые изображения не возвращаются.
+type ImageFilter struct {
+	TenantID    string
+	Statuses    []StatusType
+	Formats     []string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Tag         string
+	Owner       string
+	Asc         bool
+	After       *Cursor
+	Limit       int
+}
+
+// ImagePage — страница списка; NextCursor пуст на последней странице
+type ImagePage struct {
+	Items      []Image `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// NewImagePage обрезает выборку из limit+1 строк до limit и строит курсор следующей страницы
+func NewImagePage(images []Image, limit int) *ImagePage {
+	page := &ImagePage{Items: images}
+	if page.Items == nil {
+		page.Items = []Image{}
+	}
+	if len(images) > limit {
+		page.Items = images[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC), ID: uuid.New()}
+
+	decoded, err := DecodeCursor(c.Encode())
+	assert.NoError(t, err)
+	assert.True(t, c.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, c.ID, decoded.ID)
+
+	_, err = DecodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = DecodeCursor(Cursor{CreatedAt: time.Now()}.Encode())
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestNewImagePage(t *testing.T) {
+	images := []Image{
+		{ID: uuid.New(), CreatedAt: time.Now()},
+		{ID: uuid.New(), CreatedAt: time.Now().Add(-time.Minute)},
+		{ID: uuid.New(), CreatedAt: time.Now().Add(-2 * time.Minute)},
+	}
+
+	page := NewImagePage(images, 2)
+	assert.Len(t, page.Items, 2)
+	cursor, err := DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, images[1].ID, cursor.ID)
+
+	page = NewImagePage(images, 3)
+	assert.Len(t, page.Items, 3)
+	assert.Empty(t, page.NextCursor)
+
+	page = NewImagePage(nil, 10)
+	assert.NotNil(t, page.Items)
+}
@@ -2,11 +2,15 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"imageProcessor/internal/config"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type StatusType string
@@ -15,18 +19,69 @@ const (
 	Created    StatusType = "created"
 	Processing StatusType = "processing"
 	Processed  StatusType = "processed"
+	Failed     StatusType = "failed"
+	Cancelled  StatusType = "cancelled"
 	Deleted    StatusType = "deleted"
 )
 
+type Priority string
+
+const (
+	maxTags        = 20
+	maxTagLength   = 50
+	maxOwnerLength = 100
+)
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityBulk        Priority = "bulk"
+)
+
 type Image struct {
-	ID        uuid.UUID  `json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	Status    StatusType `json:"status"`
-	Format    string     `json:"format"`
-	Name      string     `json:"name"`
-	Watermark string     `json:"watermark"`
-	Resize    *Resize    `json:"resize"`
-	Mini      bool       `json:"mini"`
+	ID          uuid.UUID  `json:"id"`
+	TenantID    string     `json:"tenant_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      StatusType `json:"status"`
+	Format      string     `json:"format"`
+	Name        string     `json:"name"`
+	Watermark   string     `json:"watermark"`
+	Resize      *Resize    `json:"resize"`
+	Mini        bool       `json:"mini"`
+	CallbackURL string     `json:"callback_url,omitempty"`
+	Priority    Priority   `json:"priority"`
+	BatchID     *uuid.UUID `json:"batch_id,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Source      *FileInfo  `json:"source,omitempty"`
+	Output      *FileInfo  `json:"output,omitempty"`
+	ErrorReason string     `json:"error_reason,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	// OriginalDeletedAt — когда исходник удалён по политике хранения
+	OriginalDeletedAt *time.Time `json:"original_deleted_at,omitempty"`
+	// DeletedAt — когда изображение помещено в корзину
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// FileInfo — размеры и объём файла исходника или результата обработки;
+// SHA256 — hex-хэш содержимого, для результата он же служит ETag; Key — ключ файла в хранилище
+type FileInfo struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size_bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// ImageParams — параметры обработки, переданные клиентом при загрузке
+type ImageParams struct {
+	Watermark   string
+	Resize      string
+	Mini        bool
+	CallbackURL string
+	Priority    string
+	Tags        []string
+	Owner       string
 }
 
 type Resize struct {
@@ -34,19 +89,20 @@ type Resize struct {
 	Height int `json:"height"`
 }
 
-func NewImage(frmt, watermark, resize string, mini bool, cfg *config.AppConfig) (*Image, error) {
+func NewImage(frmt string, params ImageParams, cfg *config.AppConfig) (*Image, error) {
 
-	if err := paramsValidation(watermark, resize); err != nil {
+	if err := ValidateParams(params, cfg); err != nil {
 		return nil, err
 	}
+	priority, _ := parsePriority(params.Priority)
 	if !cfg.ImageFormats.SupportedFormats[frmt] {
-		return nil, errors.New("unsupported format:" + frmt)
+		return nil, &UploadError{Code: CodeUnsupportedMedia, Message: "unsupported format:" + frmt}
 	}
 
 	var resizeStruct *Resize
 
-	if resize != "" {
-		w, h, err := parseResize(resize)
+	if params.Resize != "" {
+		w, h, err := parseResize(params.Resize)
 		if err != nil {
 			return nil, err
 		}
@@ -56,19 +112,90 @@ func NewImage(frmt, watermark, resize string, mini bool, cfg *config.AppConfig)
 	}
 
 	img := &Image{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		Status:    Created,
-		Format:    frmt,
-		Name:      uuid.New().String() + "." + frmt,
-		Watermark: watermark,
-		Resize:    resizeStruct,
-		Mini:      mini,
+		ID:          uuid.New(),
+		CreatedAt:   time.Now(),
+		Status:      Created,
+		Format:      frmt,
+		Name:        uuid.New().String() + "." + frmt,
+		Watermark:   params.Watermark,
+		Resize:      resizeStruct,
+		Mini:        params.Mini,
+		CallbackURL: params.CallbackURL,
+		Priority:    priority,
+		Tags:        params.Tags,
+		Owner:       params.Owner,
 	}
 
 	return img, nil
 }
 
+// OriginalKey — ключ исходника в хранилище; файлы, загруженные до адресации по содержимому, лежат под Name
+func (img *Image) OriginalKey() string {
+	if img.Source != nil && img.Source.Key != "" {
+		return img.Source.Key
+	}
+	return img.Name
+}
+
+// OutputKey — ключ результата обработки в хранилище, для старых записей — Name
+func (img *Image) OutputKey() string {
+	if img.Output != nil && img.Output.Key != "" {
+		return img.Output.Key
+	}
+	return img.Name
+}
+
+// Operations перечисляет операции обработки в порядке применения
+func (img *Image) Operations() []string {
+	ops := make([]string, 0, 3)
+	if img.Watermark != "" {
+		ops = append(ops, "watermark")
+	}
+	if img.Resize != nil && (img.Resize.Width > 0 || img.Resize.Height > 0) {
+		ops = append(ops, "resize")
+	}
+	if img.Mini {
+		ops = append(ops, "thumbnail")
+	}
+	return ops
+}
+
+// ValidateParams проверяет параметры обработки, ошибка — *UploadError с кодом invalid_params
+// ValidateParams проверяет параметры обработки; размер после ресайза ограничен теми же лимитами upload,
+// что и исходник, иначе крошечный файл с огромным resize обошёл бы их
+func ValidateParams(params ImageParams, cfg *config.AppConfig) error {
+	err := paramsValidation(params.Watermark, params.Resize)
+	if err == nil {
+		err = callbackValidation(params.CallbackURL, cfg)
+	}
+	if err == nil {
+		_, err = parsePriority(params.Priority)
+	}
+	if err == nil {
+		err = labelsValidation(params.Tags, params.Owner)
+	}
+	if err != nil {
+		return &UploadError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	if params.Resize != "" {
+		width, height, _ := parseResize(params.Resize)
+		return CheckResize(width, height, cfg)
+	}
+	return nil
+}
+
+// CheckResize сверяет размер результата ресайза с лимитами upload
+func CheckResize(width, height int, cfg *config.AppConfig) error {
+	if err := CheckDimensions(width, height, cfg); err != nil {
+		var uploadErr *UploadError
+		if errors.As(err, &uploadErr) {
+			return &UploadError{Code: uploadErr.Code, Message: "resize target is too large: " + uploadErr.Message}
+		}
+		return err
+	}
+	return nil
+}
+
 func paramsValidation(watermark, resize string) error {
 
 	if watermark != "" && len(watermark) > 20 {
@@ -85,6 +212,74 @@ func paramsValidation(watermark, resize string) error {
 	return nil
 }
 
+func callbackValidation(callbackURL string, cfg *config.AppConfig) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if !cfg.WebhookConfig.Enabled() {
+		return errors.New("callback_url requires WEBHOOK_SECRET to be configured")
+	}
+	if len(callbackURL) > 2048 {
+		return errors.New("callback_url must be less than or equal to 2048 characters")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback_url must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+func labelsValidation(tags []string, owner string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("no more than %d tags are allowed", maxTags)
+	}
+	for _, tag := range tags {
+		if !validTag(tag) {
+			return fmt.Errorf("tag %q must be 1-%d letters, digits or -_.: characters", tag, maxTagLength)
+		}
+	}
+	if utf8.RuneCountInString(owner) > maxOwnerLength {
+		return fmt.Errorf("owner must be less than or equal to %d characters", maxOwnerLength)
+	}
+	return nil
+}
+
+func validTag(tag string) bool {
+	n := utf8.RuneCountInString(tag)
+	if n == 0 || n > maxTagLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTags разбирает список тегов через запятую, пустые элементы отбрасываются
+func ParseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parsePriority по умолчанию относит загрузку к интерактивной очереди
+func parsePriority(p string) (Priority, error) {
+	switch Priority(strings.ToLower(p)) {
+	case "", PriorityInteractive:
+		return PriorityInteractive, nil
+	case PriorityBulk:
+		return PriorityBulk, nil
+	default:
+		return "", errors.New("priority must be one of: interactive, bulk")
+	}
+}
+
 func parseResize(s string) (int, int, error) {
 	parts := strings.Split(s, "x")
 	if len(parts) != 2 {
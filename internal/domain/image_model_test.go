@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"imageProcessor/internal/config"
 	"testing"
@@ -61,7 +62,7 @@ func TestNewImage_Valid(t *testing.T) {
 			SupportedFormats: map[string]bool{"png": true, "jpg": true},
 		},
 	}
-	img, err := NewImage("png", "WM", "500x500", true, cfg)
+	img, err := NewImage("png", ImageParams{Watermark: "WM", Resize: "500x500", Mini: true}, cfg)
 	assert.NoError(t, err)
 	assert.NotNil(t, img)
 	assert.Equal(t, "WM", img.Watermark)
@@ -76,7 +77,7 @@ func TestNewImage_UnsupportedFormat(t *testing.T) {
 			SupportedFormats: map[string]bool{"png": true, "jpg": true},
 		},
 	}
-	img, err := NewImage("gif", "WM", "500x500", false, cfg)
+	img, err := NewImage("gif", ImageParams{Watermark: "WM", Resize: "500x500"}, cfg)
 	assert.Error(t, err)
 	assert.Nil(t, img)
 	assert.Contains(t, err.Error(), "unsupported format")
@@ -88,7 +89,7 @@ func TestNewImage_InvalidWatermark(t *testing.T) {
 			SupportedFormats: map[string]bool{"png": true},
 		},
 	}
-	img, err := NewImage("png", "thisisaverylongwatermarktext", "500x500", false, cfg)
+	img, err := NewImage("png", ImageParams{Watermark: "thisisaverylongwatermarktext", Resize: "500x500"}, cfg)
 	assert.Error(t, err)
 	assert.Nil(t, img)
 }
@@ -99,7 +100,92 @@ func TestNewImage_InvalidResize(t *testing.T) {
 			SupportedFormats: map[string]bool{"png": true},
 		},
 	}
-	img, err := NewImage("png", "WM", "500-500", false, cfg)
+	img, err := NewImage("png", ImageParams{Watermark: "WM", Resize: "500-500"}, cfg)
 	assert.Error(t, err)
 	assert.Nil(t, img)
 }
+
+func TestNewImage_CallbackURL(t *testing.T) {
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{
+			SupportedFormats: map[string]bool{"png": true},
+		},
+		WebhookConfig: config.WebhookConfig{Secret: "0123456789abcdef0123456789abcdef"},
+	}
+	img, err := NewImage("png", ImageParams{CallbackURL: "https://example.com/hook"}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/hook", img.CallbackURL)
+
+	for _, bad := range []string{"ftp://example.com/hook", "/relative/path", "https://"} {
+		img, err = NewImage("png", ImageParams{CallbackURL: bad}, cfg)
+		assert.Error(t, err, bad)
+		assert.Nil(t, img)
+	}
+
+	// без WEBHOOK_SECRET webhook выключены
+	cfg.WebhookConfig.Secret = ""
+	img, err = NewImage("png", ImageParams{CallbackURL: "https://example.com/hook"}, cfg)
+	var uploadErr *UploadError
+	assert.True(t, errors.As(err, &uploadErr))
+	assert.Equal(t, CodeInvalidParams, uploadErr.Code)
+	assert.Nil(t, img)
+}
+
+func TestNewImage_Priority(t *testing.T) {
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{
+			SupportedFormats: map[string]bool{"png": true},
+		},
+	}
+	img, err := NewImage("png", ImageParams{}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, PriorityInteractive, img.Priority)
+
+	img, err = NewImage("png", ImageParams{Priority: "Bulk"}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, PriorityBulk, img.Priority)
+
+	img, err = NewImage("png", ImageParams{Priority: "urgent"}, cfg)
+	assert.Error(t, err)
+	assert.Nil(t, img)
+}
+
+func TestNewImage_Labels(t *testing.T) {
+	cfg := &config.AppConfig{
+		ImageFormats: config.ImageFormats{
+			SupportedFormats: map[string]bool{"png": true},
+		},
+	}
+	img, err := NewImage("png", ImageParams{Tags: ParseTags(" catalog, summer:2024 ,,"), Owner: "partner-42"}, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"catalog", "summer:2024"}, img.Tags)
+	assert.Equal(t, "partner-42", img.Owner)
+
+	_, err = NewImage("png", ImageParams{Tags: []string{"with space"}}, cfg)
+	assert.Error(t, err)
+
+	tooMany := make([]string, maxTags+1)
+	for i := range tooMany {
+		tooMany[i] = "t"
+	}
+	_, err = NewImage("png", ImageParams{Tags: tooMany}, cfg)
+	assert.Error(t, err)
+}
+
+func TestImage_Operations(t *testing.T) {
+	img := &Image{Watermark: "WM", Resize: &Resize{Width: 100}, Mini: true}
+	assert.Equal(t, []string{"watermark", "resize", "thumbnail"}, img.Operations())
+
+	img = &Image{Resize: &Resize{}}
+	assert.Empty(t, img.Operations())
+}
+
+func TestImage_StorageKeys(t *testing.T) {
+	legacy := &Image{Name: "a.png", Source: &FileInfo{Width: 1, Height: 1}, Output: &FileInfo{Width: 1, Height: 1}}
+	assert.Equal(t, "a.png", legacy.OriginalKey())
+	assert.Equal(t, "a.png", legacy.OutputKey())
+
+	img := &Image{Name: "a.png", Source: &FileInfo{Key: "ab/cd/abcd.png"}, Output: &FileInfo{Key: "ef/01/ef01.png"}}
+	assert.Equal(t, "ab/cd/abcd.png", img.OriginalKey())
+	assert.Equal(t, "ef/01/ef01.png", img.OutputKey())
+}
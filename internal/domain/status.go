@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	ErrBatchNotFound = errors.New("batch not found")
+	ErrNotInTrash    = errors.New("image is not deleted")
+	ErrTrashExpired  = errors.New("image trash period has expired")
+)
+
+// TransitionError возвращается при попытке недопустимой смены статуса
+type TransitionError struct {
+	From StatusType
+	To   StatusType
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid status transition: %s -> %s", e.From, e.To)
+}
+
+// Допустимые переходы статусов: ключ — текущий статус, значение — куда можно перейти.
+// processing -> processing разрешён для повторной доставки сообщения после падения воркера.
+var transitions = map[StatusType][]StatusType{
+	Created:    {Processing, Cancelled, Deleted},
+	Processing: {Processing, Processed, Failed, Cancelled, Deleted},
+	Failed:     {Processing, Deleted},
+	Processed:  {Deleted},
+	Cancelled:  {Deleted},
+	Deleted:    {},
+}
+
+func (s StatusType) CanTransitionTo(next StatusType) bool {
+	for _, st := range transitions[s] {
+		if st == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedFrom возвращает статусы, из которых можно перейти в next
+func AllowedFrom(next StatusType) []StatusType {
+	var from []StatusType
+	for _, st := range []StatusType{Created, Processing, Processed, Failed, Cancelled, Deleted} {
+		if st.CanTransitionTo(next) {
+			from = append(from, st)
+		}
+	}
+	return from
+}
+
+// RestoredStatus — статус изображения после восстановления из корзины. Задача, удалённая до завершения
+// обработки, возвращается в created и заново ставится в очередь: её результат после удаления не сохранялся
+func RestoredStatus(deletedFrom StatusType) StatusType {
+	switch deletedFrom {
+	case Processed, Failed, Cancelled:
+		return deletedFrom
+	default:
+		return Created
+	}
+}
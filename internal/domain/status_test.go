@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from StatusType
+		to   StatusType
+		ok   bool
+	}{
+		{Created, Processing, true},
+		{Created, Deleted, true},
+		{Created, Processed, false},
+		{Processing, Processed, true},
+		{Processing, Failed, true},
+		{Processing, Processing, true},
+		{Failed, Processing, true},
+		{Processed, Deleted, true},
+		{Processed, Processing, false},
+		{Created, Cancelled, true},
+		{Processing, Cancelled, true},
+		{Processed, Cancelled, false},
+		{Cancelled, Processing, false},
+		{Cancelled, Deleted, true},
+		{Deleted, Processed, false},
+		{Deleted, Deleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.ok, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestAllowedFrom(t *testing.T) {
+	assert.ElementsMatch(t, []StatusType{Processing}, AllowedFrom(Processed))
+	assert.ElementsMatch(t, []StatusType{Created, Processing, Processed, Failed, Cancelled}, AllowedFrom(Deleted))
+	assert.ElementsMatch(t, []StatusType{Created, Processing}, AllowedFrom(Cancelled))
+	assert.Empty(t, AllowedFrom(Created))
+}
+
+func TestTransitionError(t *testing.T) {
+	err := &TransitionError{From: Deleted, To: Processed}
+	assert.Equal(t, "invalid status transition: deleted -> processed", err.Error())
+}
+
+func TestRestoredStatus(t *testing.T) {
+	assert.Equal(t, Processed, RestoredStatus(Processed))
+	assert.Equal(t, Failed, RestoredStatus(Failed))
+	assert.Equal(t, Cancelled, RestoredStatus(Cancelled))
+	assert.Equal(t, Created, RestoredStatus(Created))
+	assert.Equal(t, Created, RestoredStatus(Processing))
+	assert.Equal(t, Created, RestoredStatus(""))
+}
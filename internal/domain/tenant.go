@@ -0,0 +1,13 @@
+package domain
+
+// DefaultTenant — арендатор запросов, когда аутентификация арендаторов выключена
+const DefaultTenant = ""
+
+// TenantNamespace — префикс ключей хранилища арендатора; файлы арендатора по умолчанию лежат в корне,
+// как до появления арендаторов
+func TenantNamespace(tenant string) string {
+	if tenant == DefaultTenant {
+		return ""
+	}
+	return "tenants/" + tenant + "/"
+}
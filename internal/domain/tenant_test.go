@@ -0,0 +1,11 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTenantNamespace(t *testing.T) {
+	assert.Equal(t, "", TenantNamespace(DefaultTenant))
+	assert.Equal(t, "tenants/acme/", TenantNamespace("acme"))
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"imageProcessor/internal/config"
+)
+
+// Usage — занятое арендатором место: изображения до окончательного удаления, их неудалённые исходники и результаты.
+// Файл, общий для нескольких изображений, учитывается у каждого из них
+type Usage struct {
+	Tenant         string `json:"tenant"`
+	Images         int64  `json:"images"`
+	OriginalBytes  int64  `json:"original_bytes"`
+	ProcessedBytes int64  `json:"processed_bytes"`
+}
+
+func (u Usage) Bytes() int64 {
+	return u.OriginalBytes + u.ProcessedBytes
+}
+
+// QuotaLimits — квоты арендатора, 0 — без ограничения
+type QuotaLimits struct {
+	MaxBytes  int64 `json:"max_bytes"`
+	MaxImages int64 `json:"max_images"`
+}
+
+// TenantUsage — использование вместе с действующими квотами
+type TenantUsage struct {
+	Usage
+	Bytes  int64       `json:"bytes"`
+	Limits QuotaLimits `json:"limits"`
+}
+
+func NewTenantUsage(usage Usage, limits QuotaLimits) *TenantUsage {
+	return &TenantUsage{Usage: usage, Bytes: usage.Bytes(), Limits: limits}
+}
+
+// QuotaFor возвращает квоты арендатора: собственные из quota.tenants или общие
+func QuotaFor(cfg *config.QuotaConfig, tenant string) QuotaLimits {
+	for _, q := range cfg.Tenants {
+		if q.Tenant == tenant {
+			return QuotaLimits{MaxBytes: q.MaxBytes, MaxImages: q.MaxImages}
+		}
+	}
+	return QuotaLimits{MaxBytes: cfg.MaxBytes, MaxImages: cfg.MaxImages}
+}
+
+// Check проверяет, что ещё одно изображение с исходником size байт укладывается в квоты
+func (l QuotaLimits) Check(u Usage, size int64) error {
+	if l.MaxImages > 0 && u.Images+1 > l.MaxImages {
+		return &UploadError{Code: CodeQuotaExceeded, Message: fmt.Sprintf("image quota exceeded: %d of %d images used", u.Images, l.MaxImages)}
+	}
+	if l.MaxBytes > 0 && u.Bytes()+size > l.MaxBytes {
+		return &UploadError{Code: CodeQuotaExceeded, Message: fmt.Sprintf("storage quota exceeded: %d of %d bytes used", u.Bytes(), l.MaxBytes)}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"testing"
+)
+
+func TestQuotaFor(t *testing.T) {
+	cfg := &config.QuotaConfig{
+		MaxBytes:  100,
+		MaxImages: 10,
+		Tenants:   []config.TenantQuota{{Tenant: "Team-A", MaxBytes: 1000}},
+	}
+
+	assert.Equal(t, QuotaLimits{MaxBytes: 1000}, QuotaFor(cfg, "Team-A"))
+	assert.Equal(t, QuotaLimits{MaxBytes: 100, MaxImages: 10}, QuotaFor(cfg, "team-a"))
+	assert.Equal(t, QuotaLimits{MaxBytes: 100, MaxImages: 10}, QuotaFor(cfg, ""))
+}
+
+func TestQuotaLimits_Check(t *testing.T) {
+	usage := Usage{Images: 9, OriginalBytes: 60, ProcessedBytes: 30}
+	tests := []struct {
+		name   string
+		limits QuotaLimits
+		size   int64
+		ok     bool
+	}{
+		{"unlimited", QuotaLimits{}, 1 << 40, true},
+		{"fits", QuotaLimits{MaxBytes: 100, MaxImages: 10}, 10, true},
+		{"too many images", QuotaLimits{MaxImages: 9}, 1, false},
+		{"too many bytes", QuotaLimits{MaxBytes: 100}, 11, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(usage, tt.size)
+			if tt.ok {
+				assert.NoError(t, err)
+				return
+			}
+			var uploadErr *UploadError
+			assert.True(t, errors.As(err, &uploadErr))
+			assert.Equal(t, CodeQuotaExceeded, uploadErr.Code)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookPayload — тело запроса, отправляемого на callback_url клиента
+type WebhookPayload struct {
+	Event      EventType  `json:"event"`
+	ImageID    uuid.UUID  `json:"image_id"`
+	Status     StatusType `json:"status"`
+	ResultURL  string     `json:"result_url,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+// WebhookDelivery — запись о доставке webhook, по ней же ведутся повторные попытки
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	ImageID        uuid.UUID       `json:"image_id"`
+	URL            string          `json:"url"`
+	Event          EventType       `json:"event"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func NewWebhookDelivery(img *Image, status StatusType, resultURL string) (*WebhookDelivery, error) {
+	now := time.Now().UTC()
+	event := EventType("image." + string(status))
+	payload, err := json.Marshal(WebhookPayload{
+		Event:      event,
+		ImageID:    img.ID,
+		Status:     status,
+		ResultURL:  resultURL,
+		OccurredAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDelivery{
+		ID:            uuid.New(),
+		ImageID:       img.ID,
+		URL:           img.CallbackURL,
+		Event:         event,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
@@ -0,0 +1,223 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL             = errors.New("source url must be an absolute http(s) URL")
+	ErrForbiddenAddress       = errors.New("source address is not allowed")
+	ErrTooLarge               = errors.New("source file is too large")
+	ErrUnsupportedContentType = errors.New("source content type is not a supported image")
+	ErrUpstream               = errors.New("failed to fetch source")
+)
+
+// contentFormats сопоставляет Content-Type ответа с форматом изображения
+var contentFormats = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/jpg":  "jpg",
+	"image/gif":  "gif",
+}
+
+// deniedNetworks — адреса, которые не покрываются методами net.IP, но тоже не должны быть доступны извне
+var deniedNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+)
+
+// Fetcher скачивает исходники по URL с защитой от SSRF: адрес проверяется при каждом
+// соединении (в том числе после редиректа и повторного DNS-разрешения)
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// Download — скачанный во временный файл исходник; Close удаляет файл
+type Download struct {
+	Filename string
+	File     *os.File
+}
+
+func (d *Download) Close() error {
+	_ = d.File.Close()
+	return os.Remove(d.File.Name())
+}
+
+// GuardedDialer не соединяется с приватными, служебными и локальными адресами, кроме подсетей allowlist (CIDR или IP).
+// Адрес проверяется после DNS-разрешения, поэтому имя, указывающее на внутренний адрес, тоже отклоняется
+// с ErrForbiddenAddress
+func GuardedDialer(timeout time.Duration, allowlist []string) *net.Dialer {
+	allowed := parseCIDRs(allowlist...)
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !addressAllowed(net.ParseIP(host), allowed) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+}
+
+func NewFetcher(cfg *config.AppConfig) *Fetcher {
+	dialer := GuardedDialer(cfg.FetchConfig.Timeout, cfg.FetchConfig.Allowlist)
+	maxRedirects := cfg.FetchConfig.MaxRedirects
+	return &Fetcher{
+		client: &http.Client{
+			Timeout: cfg.FetchConfig.Timeout,
+			// прокси из окружения обошёл бы проверку адресов
+			Transport: &http.Transport{
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.FetchConfig.Timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrInvalidURL
+				}
+				return nil
+			},
+		},
+		maxSize: cfg.FetchConfig.MaxSize,
+	}
+}
+
+// Download скачивает изображение во временный файл, проверяя размер и Content-Type
+func (f *Fetcher) Download(ctx context.Context, rawURL string) (*Download, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrForbiddenAddress):
+			return nil, ErrForbiddenAddress
+		case errors.Is(err, ErrInvalidURL):
+			return nil, ErrInvalidURL
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: source responded with status %d", ErrUpstream, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	format, ok := contentFormats[strings.ToLower(mediaType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, mediaType)
+	}
+	if f.maxSize > 0 && resp.ContentLength > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	tmp, err := os.CreateTemp("", "fetch-*."+format)
+	if err != nil {
+		return nil, err
+	}
+	d := &Download{Filename: filename(u, format), File: tmp}
+	body := io.Reader(resp.Body)
+	if f.maxSize > 0 {
+		// Content-Length может отсутствовать или не совпадать с телом
+		body = io.LimitReader(resp.Body, f.maxSize+1)
+	}
+	n, err := io.Copy(tmp, body)
+	if err != nil {
+		_ = d.Close()
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if f.maxSize > 0 && n > f.maxSize {
+		_ = d.Close()
+		return nil, ErrTooLarge
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// filename берёт имя из пути URL, расширение — по Content-Type
+func filename(u *url.URL, format string) string {
+	base := strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+	if base == "" || base == "." || base == "/" {
+		base = "remote"
+	}
+	return base + "." + format
+}
+
+func addressAllowed(ip net.IP, allowlist []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range allowlist {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range deniedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCIDRs принимает подсети и одиночные адреса; некорректные записи пропускаются,
+// то есть соответствующие адреса остаются запрещены
+func parseCIDRs(entries ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, e := range entries {
+		if !strings.Contains(e, "/") {
+			if ip := net.ParseIP(e); ip != nil && ip.To4() != nil {
+				e += "/32"
+			} else {
+				e += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(e)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Invalid fetch allowlist entry, skipping")
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
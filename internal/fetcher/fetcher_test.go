@@ -0,0 +1,146 @@
+package fetcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig(allowlist ...string) *config.AppConfig {
+	return &config.AppConfig{
+		FetchConfig: config.FetchConfig{
+			Timeout:      time.Second,
+			MaxSize:      16,
+			MaxRedirects: 2,
+			Allowlist:    allowlist,
+		},
+	}
+}
+
+func newSource(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo.jpeg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("jpeg-bytes"))
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(strings.Repeat("x", 17)))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownload(t *testing.T) {
+	source := newSource(t)
+	f := NewFetcher(testConfig("127.0.0.1"))
+
+	d, err := f.Download(context.Background(), source.URL+"/photo.jpeg")
+	assert.NoError(t, err)
+	assert.Equal(t, "photo.jpg", d.Filename)
+	data, _ := io.ReadAll(d.File)
+	assert.Equal(t, "jpeg-bytes", string(data))
+	assert.NoError(t, d.Close())
+	_, err = os.Stat(d.File.Name())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownload_Errors(t *testing.T) {
+	source := newSource(t)
+	tests := []struct {
+		name      string
+		allowlist []string
+		url       string
+		err       error
+	}{
+		{"loopback denied by default", nil, source.URL + "/photo.jpeg", ErrForbiddenAddress},
+		{"not http", []string{"127.0.0.0/8"}, "file:///etc/passwd", ErrInvalidURL},
+		{"relative", []string{"127.0.0.0/8"}, "/photo.jpeg", ErrInvalidURL},
+		{"too large", []string{"127.0.0.0/8"}, source.URL + "/big.png", ErrTooLarge},
+		{"not an image", []string{"127.0.0.0/8"}, source.URL + "/page", ErrUnsupportedContentType},
+		{"upstream status", []string{"127.0.0.0/8"}, source.URL + "/missing.png", ErrUpstream},
+		{"timeout", []string{"127.0.0.0/8"}, source.URL + "/slow.png", ErrUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFetcher(testConfig(tt.allowlist...)).Download(context.Background(), tt.url)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestDownload_Redirects(t *testing.T) {
+	source := newSource(t)
+	f := NewFetcher(testConfig("127.0.0.1"))
+
+	ok := httptest.NewServer(http.RedirectHandler(source.URL+"/photo.jpeg", http.StatusFound))
+	defer ok.Close()
+	d, err := f.Download(context.Background(), ok.URL)
+	assert.NoError(t, err)
+	_ = d.Close()
+
+	scheme := httptest.NewServer(http.RedirectHandler("file:///etc/passwd", http.StatusFound))
+	defer scheme.Close()
+	_, err = f.Download(context.Background(), scheme.URL)
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	var loop *httptest.Server
+	loop = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, loop.URL, http.StatusFound)
+	}))
+	defer loop.Close()
+	_, err = f.Download(context.Background(), loop.URL)
+	assert.ErrorIs(t, err, ErrUpstream)
+}
+
+func TestAddressAllowed(t *testing.T) {
+	allowlist := parseCIDRs("10.1.0.0/16", "fd00::1", "bad-entry")
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"fd00::1", true},
+		{"fd00::2", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, addressAllowed(net.ParseIP(tt.ip), allowlist))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package imgprocessor
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBudget ограничивает суммарную оценку памяти изображений, которые воркеры процесса обрабатывают
+// одновременно (upload.worker_memory_budget); задача, которой не хватает бюджета, ждёт завершения других
+type MemoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	// freed закрывается и заменяется при каждом освобождении, чтобы разбудить ожидающих
+	freed chan struct{}
+}
+
+// NewMemoryBudget создаёт бюджет на limit байт; при limit <= 0 ограничения нет
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit, freed: make(chan struct{})}
+}
+
+// Acquire резервирует n байт до вызова release или возвращает ошибку ctx. Запрос больше всего бюджета
+// ждёт, пока бюджет не освободится целиком: такие изображения отклоняются ещё при загрузке
+func (b *MemoryBudget) Acquire(ctx context.Context, n int64) (func(), error) {
+	if b == nil || b.limit <= 0 {
+		return func() {}, nil
+	}
+	n = min(n, b.limit)
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { b.release(n) }) }, nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+func (b *MemoryBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
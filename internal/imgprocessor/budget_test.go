@@ -0,0 +1,58 @@
+package imgprocessor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(100)
+
+	first, err := budget.Acquire(context.Background(), 60)
+	assert.NoError(t, err)
+
+	// вторая задача не помещается, пока первая держит память
+	acquired := make(chan func())
+	go func() {
+		release, err := budget.Acquire(context.Background(), 60)
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("budget must not be exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first()
+	first() // повторное освобождение ничего не меняет
+	select {
+	case second := <-acquired:
+		second()
+	case <-time.After(time.Second):
+		t.Fatal("waiting task should acquire freed budget")
+	}
+	assert.Zero(t, budget.used)
+}
+
+func TestMemoryBudget_Cancelled(t *testing.T) {
+	budget := NewMemoryBudget(100)
+	release, err := budget.Acquire(context.Background(), 100)
+	assert.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = budget.Acquire(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemoryBudget_Unlimited(t *testing.T) {
+	for _, budget := range []*MemoryBudget{nil, NewMemoryBudget(0)} {
+		release, err := budget.Acquire(context.Background(), 1<<40)
+		assert.NoError(t, err)
+		release()
+	}
+}
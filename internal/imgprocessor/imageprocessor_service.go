@@ -1,6 +1,8 @@
 package imgprocessor
 
 import (
+	"bytes"
+	"context"
 	"github.com/disintegration/imaging"
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"golang.org/x/image/font"
@@ -12,36 +14,42 @@ import (
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
-	"os"
+	"io"
 	"strings"
 )
 
-func Process(cfg *config.AppConfig, img *domain.Image) error {
-
-	inputPath := cfg.StoragePathConfig.InputDir + img.Name
-	outputPath := cfg.StoragePathConfig.OutputDir + img.Name
-	outpudDir := cfg.StoragePathConfig.OutputDir
-
-	if err := os.MkdirAll(outpudDir, 0755); err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to create input directory")
-		return err
+// Process применяет к изображению операции по очереди; отмена ctx проверяется между
+// операциями, так что отменённая задача не доходит до записи результата.
+// Исходник читается из blobs.Originals, результат пишется в blobs.Processed в пространство арендатора под ключом из его SHA-256.
+// Декодированные копии изображения учитываются в budget, пока результат не записан.
+// Возвращает размеры и объём сохранённого результата и release, который снимает блокировку его ключа в locks:
+// его вызывают после того, как ссылка на результат сохранена
+func Process(ctx context.Context, cfg *config.AppConfig, blobs *blob.Stores, locks blob.Locker, budget *MemoryBudget, img *domain.Image) (*domain.FileInfo, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	src, err := imaging.Open(inputPath)
+	src, free, err := openSource(ctx, cfg, budget, blobs.Originals, img)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to open source image")
-		return err
+		return nil, nil, err
 	}
+	defer free()
 
 	result := src
 
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
 	if img.Watermark != "" {
 		result, err = addWatermark(result, img.Watermark)
 		if err != nil {
 			wbzlog.Logger.Error().Err(err).Msg("Failed to add watermark")
-			return err
+			return nil, nil, err
 		}
 	}
 
@@ -49,17 +57,71 @@ func Process(cfg *config.AppConfig, img *domain.Image) error {
 		result = imaging.Resize(result, img.Resize.Width, img.Resize.Height, imaging.Lanczos)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
 	if img.Mini {
 		result = imaging.Thumbnail(result, 300, 300, imaging.Lanczos)
 	}
 
-	err = saveImage(result, outputPath, img.Format)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, result, img.Format); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to encode processed image")
+		return nil, nil, err
+	}
+	// результат адресуется по содержимому, его хэш используется как ETag
+	stored, err := blob.PutContentBytes(ctx, blobs.Processed, locks, domain.TenantNamespace(img.TenantID), buf.Bytes(), img.Format)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to save processed image")
-		return err
+		return nil, nil, err
 	}
 
-	return nil
+	bounds := result.Bounds()
+	return &domain.FileInfo{Width: bounds.Dx(), Height: bounds.Dy(), Size: stored.Info.Size, SHA256: stored.SHA256, Key: stored.Info.Key}, stored.Release, nil
+}
+
+// openSource проверяет размеры по заголовку и только потом декодирует изображение целиком:
+// лимиты проверяются и при загрузке, но файл мог попасть в очередь до их изменения.
+// Перед декодированием резервирует в budget память большего из исходника и результата ресайза; free её освобождает
+func openSource(ctx context.Context, cfg *config.AppConfig, budget *MemoryBudget, store blob.Store, img *domain.Image) (image.Image, func(), error) {
+	obj, err := store.Get(ctx, img.OriginalKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = obj.Close()
+	}()
+	imgCfg, _, err := image.DecodeConfig(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := domain.CheckDimensions(imgCfg.Width, imgCfg.Height, cfg); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Source image rejected before decoding")
+		return nil, nil, err
+	}
+	if err := domain.CheckResize(img.Resize.Width, img.Resize.Height, cfg); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Resize target rejected before decoding")
+		return nil, nil, err
+	}
+	if _, err := obj.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	need := max(domain.DecodeMemory(imgCfg.Width, imgCfg.Height), domain.DecodeMemory(img.Resize.Width, img.Resize.Height))
+	free, err := budget.Acquire(ctx, need)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := imaging.Decode(obj)
+	if err != nil {
+		free()
+		return nil, nil, err
+	}
+	return src, free, nil
 }
 
 func addWatermark(img image.Image, text string) (image.Image, error) {
@@ -96,28 +158,21 @@ func addWatermark(img image.Image, text string) (image.Image, error) {
 	return rgba, nil
 }
 
-func saveImage(img image.Image, path string, format string) error {
-	out, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		err := out.Close()
-		if err != nil {
-			wbzlog.Logger.Error().Err(err).Msg("Failed to close file")
-		}
-	}()
-
+func encodeImage(w io.Writer, img image.Image, format string) error {
 	format = strings.ToLower(strings.TrimPrefix(format, "."))
 
 	switch format {
 	case "jpg", "jpeg":
-		return jpeg.Encode(out, img, &jpeg.Options{Quality: 90})
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
 	case "png":
-		return png.Encode(out, img)
+		return png.Encode(w, img)
 	case "gif":
-		return gif.Encode(out, img, nil)
+		return gif.Encode(w, img, nil)
 	default:
-		return imaging.Save(img, path)
+		f, err := imaging.FormatFromExtension(format)
+		if err != nil {
+			return err
+		}
+		return imaging.Encode(w, img, f)
 	}
 }
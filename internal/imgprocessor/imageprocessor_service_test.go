@@ -1,12 +1,17 @@
 package imgprocessor
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/stretchr/testify/assert"
 	"image"
 	"image/color"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
 	"os"
@@ -61,15 +66,17 @@ func TestAddWatermark(t *testing.T) {
 	assert.NotNil(t, out)
 }
 
-func TestSaveImage(t *testing.T) {
-	tmpDir := t.TempDir()
-	path := filepath.Join(tmpDir, "test.png")
+func TestEncodeImage(t *testing.T) {
 	img := image.NewRGBA(image.Rect(0, 0, 50, 50))
-	err := saveImage(img, path, "png")
-	assert.NoError(t, err)
+	for _, format := range []string{"png", "jpg", "gif", "bmp"} {
+		var buf bytes.Buffer
+		err := encodeImage(&buf, img, format)
+		assert.NoError(t, err, format)
+		assert.NotZero(t, buf.Len(), format)
+	}
 
-	_, err = os.Stat(path)
-	assert.NoError(t, err)
+	err := encodeImage(&bytes.Buffer{}, img, "svg")
+	assert.Error(t, err)
 }
 
 func TestProcess_FullFlow_MultipleFormats(t *testing.T) {
@@ -120,12 +127,109 @@ func TestProcess_FullFlow_MultipleFormats(t *testing.T) {
 				Mini:      true,
 			}
 
-			err := Process(cfg, img)
+			output, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, img)
 			assert.NoError(t, err)
 
-			outputPath := filepath.Join(outputDir, filename)
-			_, err = os.Stat(outputPath)
+			assert.NotNil(t, output)
+			data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(output.Key)))
 			assert.NoError(t, err)
+			hash := sha256.Sum256(data)
+			sum := hex.EncodeToString(hash[:])
+			// миниатюра применяется последней и задаёт итоговый размер
+			assert.Equal(t, &domain.FileInfo{Width: 300, Height: 300, Size: int64(len(data)), SHA256: sum, Key: blob.ContentKey(sum, tt.format)}, output)
 		})
 	}
 }
+
+func TestProcess_Cancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  tmpDir + string(os.PathSeparator),
+			OutputDir: filepath.Join(tmpDir, "output") + string(os.PathSeparator),
+		},
+	}
+	createTempImageByFormat(t, tmpDir, "cancel.png", "png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := Process(ctx, cfg, blob.NewLocalStores(cfg), nil, nil, &domain.Image{Name: "cancel.png", Format: "png", Resize: &domain.Resize{}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoDirExists(t, filepath.Join(tmpDir, "output"))
+}
+
+func TestProcess_RejectsOversizedSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  tmpDir + string(os.PathSeparator),
+			OutputDir: filepath.Join(tmpDir, "output") + string(os.PathSeparator),
+		},
+		UploadConfig: config.UploadConfig{MaxWidth: 100, MaxHeight: 100},
+	}
+	createTempImageByFormat(t, tmpDir, "big.png", "png")
+
+	_, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, &domain.Image{Name: "big.png", Format: "png", Resize: &domain.Resize{}})
+	var uploadErr *domain.UploadError
+	assert.ErrorAs(t, err, &uploadErr)
+	assert.Equal(t, domain.CodeImageTooLarge, uploadErr.Code)
+	assert.NoDirExists(t, filepath.Join(tmpDir, "output"))
+}
+
+func TestProcess_RejectsOversizedResize(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  tmpDir + string(os.PathSeparator),
+			OutputDir: filepath.Join(tmpDir, "output") + string(os.PathSeparator),
+		},
+		UploadConfig: config.UploadConfig{MaxWidth: 1000, MaxHeight: 1000},
+	}
+	createTempImageByFormat(t, tmpDir, "small.png", "png")
+
+	// задача могла попасть в очередь до изменения лимитов: огромный ресайз отклоняется до декодирования
+	_, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, &domain.Image{Name: "small.png", Format: "png", Resize: &domain.Resize{Width: 100000, Height: 100000}})
+	var uploadErr *domain.UploadError
+	assert.ErrorAs(t, err, &uploadErr)
+	assert.Equal(t, domain.CodeImageTooLarge, uploadErr.Code)
+	assert.NoDirExists(t, filepath.Join(tmpDir, "output"))
+}
+
+func TestProcess_ContentAddressedSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  tmpDir + string(os.PathSeparator),
+			OutputDir: filepath.Join(tmpDir, "output") + string(os.PathSeparator),
+		},
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "ab", "cd"), 0755))
+	createTempImageByFormat(t, filepath.Join(tmpDir, "ab", "cd"), "abcd.png", "png")
+
+	img := &domain.Image{Name: "other.png", Format: "png", Resize: &domain.Resize{}, Source: &domain.FileInfo{Key: "ab/cd/abcd.png"}}
+	first, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, img)
+	assert.NoError(t, err)
+	second, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, img)
+	assert.NoError(t, err)
+	// одинаковый результат хранится одним файлом
+	assert.Equal(t, first, second)
+}
+
+func TestProcess_TenantNamespace(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{
+			InputDir:  tmpDir + string(os.PathSeparator),
+			OutputDir: filepath.Join(tmpDir, "output") + string(os.PathSeparator),
+		},
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "tenants", "acme", "ab", "cd"), 0755))
+	createTempImageByFormat(t, filepath.Join(tmpDir, "tenants", "acme", "ab", "cd"), "abcd.png", "png")
+
+	img := &domain.Image{TenantID: "acme", Name: "a.png", Format: "png", Resize: &domain.Resize{}, Source: &domain.FileInfo{Key: "tenants/acme/ab/cd/abcd.png"}}
+	output, _, err := Process(context.Background(), cfg, blob.NewLocalStores(cfg), nil, nil, img)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenants/acme/"+blob.ContentKey(output.SHA256, "png"), output.Key)
+	assert.FileExists(t, filepath.Join(tmpDir, "output", filepath.FromSlash(output.Key)))
+}
@@ -0,0 +1,230 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"strings"
+	"time"
+)
+
+const (
+	KindOriginal  = "original"
+	KindProcessed = "processed"
+)
+
+// tusPrefix — незавершённые tus-загрузки лежат в каталоге оригиналов, но записей в images у них нет;
+// истёкшие загрузки удаляет tus.Store.Sweep
+const tusPrefix = "tus/"
+
+type GCStorage interface {
+	ReferencedOriginals(keys []string) ([]string, error)
+	ReferencedOutputs(keys []string) ([]string, error)
+	ImagesAfter(after uuid.UUID, limit int) ([]domain.Image, error)
+	LockBlob(ctx context.Context, key string) (func(), error)
+}
+
+// MissingFile — запись изображения, файла которой нет в хранилище
+type MissingFile struct {
+	ImageID uuid.UUID         `json:"image_id"`
+	Status  domain.StatusType `json:"status"`
+	Kind    string            `json:"kind"`
+	Key     string            `json:"key"`
+}
+
+// GCReport — результат сверки: найденные файлы без записей (удалённые, если не DryRun) и записи без файлов
+type GCReport struct {
+	DryRun          bool          `json:"dry_run"`
+	OrphanOriginals []string      `json:"orphan_originals"`
+	OrphanOutputs   []string      `json:"orphan_outputs"`
+	Removed         int           `json:"removed"`
+	Missing         []MissingFile `json:"missing"`
+}
+
+// Collector сверяет хранилища файлов с таблицей images
+type Collector struct {
+	repo  GCStorage
+	blobs *blob.Stores
+	cfg   *config.GCConfig
+}
+
+func NewCollector(repo GCStorage, blobs *blob.Stores, cfg *config.AppConfig) *Collector {
+	return &Collector{
+		repo:  repo,
+		blobs: blobs,
+		cfg:   &cfg.GCConfig,
+	}
+}
+
+// Run периодически выполняет сверку в режиме gc.dry_run до отмены ctx; при нулевом interval ничего не делает
+func (c *Collector) Run(ctx context.Context) {
+	if c.cfg.Interval <= 0 {
+		wbzlog.Logger.Info().Msg("GC interval is not set, orphan file collector is disabled")
+		return
+	}
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if report, err := c.Collect(ctx, c.cfg.DryRun); err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Orphan file collection failed")
+		} else {
+			for _, m := range report.Missing {
+				wbzlog.Logger.Warn().Str("image_id", m.ImageID.String()).Str("kind", m.Kind).Str("key", m.Key).Msg("Image file is missing in storage")
+			}
+			wbzlog.Logger.Info().
+				Bool("dry_run", report.DryRun).
+				Int("orphan_originals", len(report.OrphanOriginals)).
+				Int("orphan_outputs", len(report.OrphanOutputs)).
+				Int("removed", report.Removed).
+				Int("missing", len(report.Missing)).
+				Msg("Orphan file collection finished")
+		}
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Orphan file collector stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect находит файлы без записей и записи без файлов; в режиме dryRun ничего не удаляет
+func (c *Collector) Collect(ctx context.Context, dryRun bool) (*GCReport, error) {
+	report := &GCReport{DryRun: dryRun}
+	before := time.Now().Add(-c.cfg.GracePeriod)
+	var removed int
+	var err error
+	report.OrphanOriginals, removed, err = c.orphans(ctx, c.blobs.Originals, c.repo.ReferencedOriginals, before, dryRun)
+	report.Removed += removed
+	if err != nil {
+		return report, err
+	}
+	report.OrphanOutputs, removed, err = c.orphans(ctx, c.blobs.Processed, c.repo.ReferencedOutputs, before, dryRun)
+	report.Removed += removed
+	if err != nil {
+		return report, err
+	}
+	report.Missing, err = c.missing(ctx)
+	return report, err
+}
+
+// orphans обходит хранилище и порциями по batch_size проверяет, на какие файлы ссылаются записи;
+// файлы моложе before пропускаются, т.к. запись о загрузке сохраняется после файла
+func (c *Collector) orphans(ctx context.Context, store blob.Store, referenced func(keys []string) ([]string, error), before time.Time, dryRun bool) ([]string, int, error) {
+	limit := c.batchSize()
+	orphans := make([]string, 0)
+	removed := 0
+	batch := make([]string, 0, limit)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		known, err := referenced(batch)
+		if err != nil {
+			return err
+		}
+		inUse := make(map[string]bool, len(known))
+		for _, key := range known {
+			inUse[key] = true
+		}
+		for _, key := range batch {
+			if inUse[key] {
+				continue
+			}
+			if !dryRun {
+				deleted, err := c.removeOrphan(ctx, store, key, referenced)
+				if err != nil {
+					return err
+				}
+				if !deleted {
+					continue
+				}
+				removed++
+			}
+			orphans = append(orphans, key)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err := store.List(ctx, "", func(info blob.Info) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Key, tusPrefix) || info.ModTime.After(before) {
+			return nil
+		}
+		batch = append(batch, info.Key)
+		if len(batch) < limit {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	return orphans, removed, err
+}
+
+// removeOrphan повторно проверяет ссылки на файл под блокировкой ключа и удаляет его: между проверкой порции
+// и удалением загрузка того же содержимого могла переиспользовать файл
+func (c *Collector) removeOrphan(ctx context.Context, store blob.Store, key string, referenced func(keys []string) ([]string, error)) (bool, error) {
+	unlock, err := c.repo.LockBlob(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	known, err := referenced([]string{key})
+	if err != nil || len(known) > 0 {
+		return false, err
+	}
+	return true, store.Delete(ctx, key)
+}
+
+// missing обходит все записи и проверяет, что в хранилище есть неудалённый исходник и результат обработки
+func (c *Collector) missing(ctx context.Context) ([]MissingFile, error) {
+	limit := c.batchSize()
+	missing := make([]MissingFile, 0)
+	check := func(img *domain.Image, store blob.Store, kind, key string) error {
+		_, err := store.Stat(ctx, key)
+		if errors.Is(err, blob.ErrNotFound) {
+			missing = append(missing, MissingFile{ImageID: img.ID, Status: img.Status, Kind: kind, Key: key})
+			return nil
+		}
+		return err
+	}
+	after := uuid.Nil
+	for ctx.Err() == nil {
+		images, err := c.repo.ImagesAfter(after, limit)
+		if err != nil {
+			return missing, err
+		}
+		for _, img := range images {
+			if img.OriginalDeletedAt == nil {
+				if err := check(&img, c.blobs.Originals, KindOriginal, img.OriginalKey()); err != nil {
+					return missing, err
+				}
+			}
+			if img.Status == domain.Processed || img.Output != nil {
+				if err := check(&img, c.blobs.Processed, KindProcessed, img.OutputKey()); err != nil {
+					return missing, err
+				}
+			}
+		}
+		if len(images) < limit {
+			return missing, nil
+		}
+		after = images[len(images)-1].ID
+	}
+	return missing, ctx.Err()
+}
+
+func (c *Collector) batchSize() int {
+	if c.cfg.BatchSize <= 0 {
+		return 500
+	}
+	return c.cfg.BatchSize
+}
@@ -0,0 +1,215 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type MockGCStorage struct {
+	mock.Mock
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+// LockBlob не идёт через mock: тесты проверяют, удерживается ли блокировка ключа в нужный момент
+func (m *MockGCStorage) LockBlob(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held == nil {
+		m.held = make(map[string]bool)
+	}
+	m.held[key] = true
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.held, key)
+	}, nil
+}
+
+func (m *MockGCStorage) isHeld(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.held[key]
+}
+
+func (m *MockGCStorage) ReferencedOriginals(keys []string) ([]string, error) {
+	args := m.Called(keys)
+	if fn, ok := args.Get(0).(func(keys []string) []string); ok {
+		return fn(keys), args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockGCStorage) ReferencedOutputs(keys []string) ([]string, error) {
+	args := m.Called(keys)
+	if fn, ok := args.Get(0).(func(keys []string) []string); ok {
+		return fn(keys), args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockGCStorage) ImagesAfter(after uuid.UUID, limit int) ([]domain.Image, error) {
+	args := m.Called(after, limit)
+	return args.Get(0).([]domain.Image), args.Error(1)
+}
+
+// referenced возвращает из запрошенных ключей те, что есть в known
+func referenced(known ...string) func(keys []string) []string {
+	return func(keys []string) []string {
+		found := make([]string, 0)
+		for _, key := range keys {
+			for _, k := range known {
+				if key == k {
+					found = append(found, key)
+				}
+			}
+		}
+		return found
+	}
+}
+
+func newCollector(t *testing.T, repo GCStorage, gc config.GCConfig) (*Collector, string, string) {
+	t.Helper()
+	input, output := t.TempDir()+string(os.PathSeparator), t.TempDir()+string(os.PathSeparator)
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{InputDir: input, OutputDir: output},
+		GCConfig:          gc,
+	}
+	return NewCollector(repo, blob.NewLocalStores(cfg), cfg), input, output
+}
+
+func writeBlob(t *testing.T, dir, key string, modTime time.Time) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(key))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	return path
+}
+
+func TestCollect_RemovesOrphans(t *testing.T) {
+	repo := new(MockGCStorage)
+	c, input, output := newCollector(t, repo, config.GCConfig{GracePeriod: time.Hour, BatchSize: 2})
+
+	old := time.Now().Add(-2 * time.Hour)
+	kept := writeBlob(t, input, "ab/cd/abcd.png", old)
+	orphan := writeBlob(t, input, "ef/01/ef01.png", old)
+	legacy := writeBlob(t, input, "legacy.png", old)
+	fresh := writeBlob(t, input, "12/34/1234.png", time.Now())
+	tusPart := writeBlob(t, input, "tus/upload.part", old)
+	orphanOutput := writeBlob(t, output, "56/78/5678.png", old)
+
+	repo.On("ReferencedOriginals", mock.Anything).Return(referenced("ab/cd/abcd.png", "legacy.png"), nil)
+	repo.On("ReferencedOutputs", mock.Anything).Return(referenced(), nil)
+	repo.On("ImagesAfter", uuid.Nil, 2).Return([]domain.Image{}, nil)
+
+	report, err := c.Collect(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ef/01/ef01.png"}, report.OrphanOriginals)
+	assert.Equal(t, []string{"56/78/5678.png"}, report.OrphanOutputs)
+	assert.Equal(t, 2, report.Removed)
+	assert.FileExists(t, kept)
+	assert.FileExists(t, legacy)
+	assert.FileExists(t, fresh)
+	assert.FileExists(t, tusPart)
+	assert.NoFileExists(t, orphan)
+	assert.NoFileExists(t, orphanOutput)
+}
+
+func TestCollect_KeepsOrphanReusedBeforeDelete(t *testing.T) {
+	repo := new(MockGCStorage)
+	c, input, _ := newCollector(t, repo, config.GCConfig{GracePeriod: time.Hour})
+
+	orphan := writeBlob(t, input, "ef/01/ef01.png", time.Now().Add(-2*time.Hour))
+	// пока порция проверялась, загрузка того же содержимого сослалась на файл
+	repo.On("ReferencedOriginals", []string{"ef/01/ef01.png"}).Return([]string{}, nil).Once()
+	repo.On("ReferencedOriginals", []string{"ef/01/ef01.png"}).Return([]string{"ef/01/ef01.png"}, nil).Once().Run(func(mock.Arguments) {
+		assert.True(t, repo.isHeld("ef/01/ef01.png"), "references must be re-checked under the blob lock")
+	})
+	repo.On("ImagesAfter", uuid.Nil, 500).Return([]domain.Image{}, nil)
+
+	report, err := c.Collect(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.OrphanOriginals)
+	assert.Zero(t, report.Removed)
+	assert.FileExists(t, orphan)
+	assert.Empty(t, repo.held)
+}
+
+func TestCollect_DryRun(t *testing.T) {
+	repo := new(MockGCStorage)
+	c, input, _ := newCollector(t, repo, config.GCConfig{GracePeriod: time.Hour})
+
+	orphan := writeBlob(t, input, "ef/01/ef01.png", time.Now().Add(-2*time.Hour))
+	repo.On("ReferencedOriginals", []string{"ef/01/ef01.png"}).Return([]string{}, nil)
+	repo.On("ImagesAfter", uuid.Nil, 500).Return([]domain.Image{}, nil)
+
+	report, err := c.Collect(context.Background(), true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"ef/01/ef01.png"}, report.OrphanOriginals)
+	assert.Zero(t, report.Removed)
+	assert.FileExists(t, orphan)
+	repo.AssertNotCalled(t, "ReferencedOutputs", mock.Anything)
+}
+
+func TestCollect_MissingFiles(t *testing.T) {
+	repo := new(MockGCStorage)
+	c, input, output := newCollector(t, repo, config.GCConfig{BatchSize: 2})
+
+	removedAt := time.Now()
+	images := []domain.Image{
+		{ID: uuid.New(), Name: "a.png", Status: domain.Processed},
+		{ID: uuid.New(), Name: "b.png", Status: domain.Created},
+		{ID: uuid.New(), Name: "c.png", Status: domain.Processed, OriginalDeletedAt: &removedAt},
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].ID.String() < images[j].ID.String() })
+	for _, img := range images {
+		if img.Name != "a.png" {
+			writeBlob(t, input, img.Name, time.Now())
+		}
+		if img.Name != "c.png" {
+			writeBlob(t, output, img.Name, time.Now())
+		}
+	}
+	repo.On("ReferencedOriginals", mock.Anything).Return(referenced("b.png", "c.png"), nil)
+	repo.On("ReferencedOutputs", mock.Anything).Return(referenced("a.png", "b.png"), nil)
+	repo.On("ImagesAfter", uuid.Nil, 2).Return(images[:2], nil).Once()
+	repo.On("ImagesAfter", images[1].ID, 2).Return(images[2:], nil).Once()
+
+	report, err := c.Collect(context.Background(), true)
+	assert.NoError(t, err)
+	byName := make(map[uuid.UUID]string)
+	for _, img := range images {
+		byName[img.ID] = img.Name
+	}
+	missing := make([]string, 0)
+	for _, m := range report.Missing {
+		missing = append(missing, m.Kind+":"+byName[m.ImageID])
+	}
+	assert.ElementsMatch(t, []string{"original:a.png", "processed:c.png"}, missing)
+}
+
+func TestCollect_RepoError(t *testing.T) {
+	repo := new(MockGCStorage)
+	c, input, _ := newCollector(t, repo, config.GCConfig{})
+
+	orphan := writeBlob(t, input, "ef/01/ef01.png", time.Now().Add(-time.Hour))
+	repo.On("ReferencedOriginals", mock.Anything).Return([]string(nil), errors.New("db down"))
+
+	_, err := c.Collect(context.Background(), false)
+	assert.Error(t, err)
+	assert.FileExists(t, orphan)
+}
@@ -0,0 +1,142 @@
+package janitor
+
+import (
+	"context"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+const (
+	RetainForever         = "forever"
+	RetainAfterProcessing = "after_processing"
+	RetainDays            = "days"
+)
+
+type OriginalStorage interface {
+	ExpiredOriginals(statuses []domain.StatusType, createdBefore *time.Time, limit int) ([]domain.Image, error)
+	MarkOriginalDeleted(id string) error
+	OriginalInUse(key string, exceptID string) (bool, error)
+	LockBlob(ctx context.Context, key string) (func(), error)
+}
+
+// Janitor удаляет исходники из хранилища оригиналов по политике хранения originals.retention
+type Janitor struct {
+	repo      OriginalStorage
+	cfg       *config.OriginalsConfig
+	originals blob.Store
+}
+
+func NewJanitor(repo OriginalStorage, blobs *blob.Stores, cfg *config.AppConfig) *Janitor {
+	return &Janitor{
+		repo:      repo,
+		cfg:       &cfg.OriginalsConfig,
+		originals: blobs.Originals,
+	}
+}
+
+// Run периодически удаляет просроченные исходники до отмены ctx; при retention: forever ничего не делает
+func (j *Janitor) Run(ctx context.Context) {
+	switch j.cfg.Retention {
+	case "", RetainForever:
+		wbzlog.Logger.Info().Msg("Originals are kept forever, janitor is disabled")
+		return
+	case RetainAfterProcessing, RetainDays:
+	default:
+		wbzlog.Logger.Error().Str("retention", j.cfg.Retention).Msg("Unknown originals retention policy, janitor is disabled")
+		return
+	}
+	ticker := time.NewTicker(j.cfg.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		j.Sweep(ctx)
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Originals janitor stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep удаляет все исходники, подпадающие под политику, порциями по batch_size
+func (j *Janitor) Sweep(ctx context.Context) int {
+	statuses, createdBefore := j.policy(time.Now())
+	limit := j.cfg.BatchSize
+	if limit <= 0 {
+		limit = 100
+	}
+	removed := 0
+	for ctx.Err() == nil {
+		images, err := j.repo.ExpiredOriginals(statuses, createdBefore, limit)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("failed to get expired originals")
+			return removed
+		}
+		for _, img := range images {
+			if err := j.remove(ctx, &img); err != nil {
+				// без отметки изображение вернётся в следующей порции, поэтому не продолжаем по кругу
+				return removed
+			}
+			removed++
+		}
+		if len(images) < limit {
+			break
+		}
+	}
+	if removed > 0 {
+		wbzlog.Logger.Info().Int("count", removed).Msg("Removed expired originals")
+	}
+	return removed
+}
+
+// policy переводит политику в условия выборки: по дням удаляются только изображения в конечных статусах
+func (j *Janitor) policy(now time.Time) ([]domain.StatusType, *time.Time) {
+	if j.cfg.Retention == RetainAfterProcessing {
+		return []domain.StatusType{domain.Processed}, nil
+	}
+	before := now.AddDate(0, 0, -j.cfg.RetentionDays)
+	return []domain.StatusType{domain.Processed, domain.Failed, domain.Cancelled, domain.Deleted}, &before
+}
+
+// remove удаляет файл исходника, если на него не ссылаются другие загрузки того же содержимого;
+// метка original_deleted_at ставится в обоих случаях
+func (j *Janitor) remove(ctx context.Context, img *domain.Image) error {
+	if err := j.removeFile(ctx, img); err != nil {
+		return err
+	}
+	if err := j.repo.MarkOriginalDeleted(img.ID.String()); err != nil {
+		wbzlog.Logger.Error().Err(err).Str("image_id", img.ID.String()).Msg("failed to mark original deleted")
+		return err
+	}
+	return nil
+}
+
+// removeFile удаляет файл исходника. Файл, адресованный по содержимому, проверяется и удаляется под блокировкой
+// ключа: иначе загрузка того же содержимого могла бы найти его между проверкой и удалением и сослаться на
+// удалённый файл. Файлы под Name не разделяются
+func (j *Janitor) removeFile(ctx context.Context, img *domain.Image) error {
+	if img.Source != nil && img.Source.Key != "" {
+		unlock, err := j.repo.LockBlob(ctx, img.Source.Key)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Str("image_id", img.ID.String()).Msg("failed to lock original")
+			return err
+		}
+		defer unlock()
+		shared, err := j.repo.OriginalInUse(img.Source.Key, img.ID.String())
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Str("image_id", img.ID.String()).Msg("failed to check original references")
+			return err
+		}
+		if shared {
+			return nil
+		}
+	}
+	if err := j.originals.Delete(ctx, img.OriginalKey()); err != nil {
+		wbzlog.Logger.Error().Err(err).Str("image_id", img.ID.String()).Msg("failed to remove original")
+		return err
+	}
+	return nil
+}
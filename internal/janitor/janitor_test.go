@@ -0,0 +1,160 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type MockStorage struct {
+	mock.Mock
+	mu     sync.Mutex
+	held   map[string]bool
+	locked []string
+}
+
+// LockBlob не идёт через mock: тесты проверяют, какие ключи блокировались и удерживалась ли блокировка
+func (m *MockStorage) LockBlob(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held == nil {
+		m.held = make(map[string]bool)
+	}
+	m.held[key] = true
+	m.locked = append(m.locked, key)
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.held, key)
+	}, nil
+}
+
+func (m *MockStorage) isHeld(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.held[key]
+}
+
+func (m *MockStorage) ExpiredOriginals(statuses []domain.StatusType, createdBefore *time.Time, limit int) ([]domain.Image, error) {
+	args := m.Called(statuses, createdBefore, limit)
+	return args.Get(0).([]domain.Image), args.Error(1)
+}
+
+func (m *MockStorage) MarkOriginalDeleted(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockStorage) OriginalInUse(key string, exceptID string) (bool, error) {
+	args := m.Called(key, exceptID)
+	return args.Bool(0), args.Error(1)
+}
+
+func newJanitor(t *testing.T, repo OriginalStorage, originals config.OriginalsConfig) (*Janitor, string) {
+	t.Helper()
+	dir := t.TempDir() + string(os.PathSeparator)
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{InputDir: dir},
+		OriginalsConfig:   originals,
+	}
+	return NewJanitor(repo, blob.NewLocalStores(cfg), cfg), dir
+}
+
+func TestSweep_AfterProcessing(t *testing.T) {
+	repo := new(MockStorage)
+	j, dir := newJanitor(t, repo, config.OriginalsConfig{Retention: RetainAfterProcessing, BatchSize: 2})
+
+	images := []domain.Image{{ID: uuid.New(), Name: "a.png"}, {ID: uuid.New(), Name: "b.png"}, {ID: uuid.New(), Name: "missing.png"}}
+	for _, img := range images[:2] {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, img.Name), []byte("data"), 0644))
+	}
+	processed := []domain.StatusType{domain.Processed}
+	repo.On("ExpiredOriginals", processed, (*time.Time)(nil), 2).Return(images[:2], nil).Once()
+	repo.On("ExpiredOriginals", processed, (*time.Time)(nil), 2).Return(images[2:], nil).Once()
+	repo.On("MarkOriginalDeleted", mock.Anything).Return(nil)
+
+	assert.Equal(t, 3, j.Sweep(context.Background()))
+	assert.NoFileExists(t, filepath.Join(dir, "a.png"))
+	assert.NoFileExists(t, filepath.Join(dir, "b.png"))
+	repo.AssertNumberOfCalls(t, "MarkOriginalDeleted", 3)
+}
+
+func TestSweep_SharedOriginal(t *testing.T) {
+	repo := new(MockStorage)
+	j, dir := newJanitor(t, repo, config.OriginalsConfig{Retention: RetainAfterProcessing, BatchSize: 10})
+
+	shared := domain.Image{ID: uuid.New(), Name: "a.png", Source: &domain.FileInfo{Key: "ab/cd/abcd.png"}}
+	single := domain.Image{ID: uuid.New(), Name: "b.png", Source: &domain.FileInfo{Key: "ef/01/ef01.png"}}
+	for _, key := range []string{"ab/cd/abcd.png", "ef/01/ef01.png"} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+	}
+	repo.On("ExpiredOriginals", mock.Anything, mock.Anything, 10).Return([]domain.Image{shared, single}, nil)
+	repo.On("OriginalInUse", "ab/cd/abcd.png", shared.ID.String()).Return(true, nil).Run(func(mock.Arguments) {
+		assert.True(t, repo.isHeld("ab/cd/abcd.png"), "reference check must run under the blob lock")
+	})
+	repo.On("OriginalInUse", "ef/01/ef01.png", single.ID.String()).Return(false, nil).Run(func(mock.Arguments) {
+		assert.True(t, repo.isHeld("ef/01/ef01.png"), "reference check must run under the blob lock")
+	})
+	repo.On("MarkOriginalDeleted", mock.Anything).Return(nil)
+
+	assert.Equal(t, 2, j.Sweep(context.Background()))
+	assert.FileExists(t, filepath.Join(dir, "ab", "cd", "abcd.png"))
+	assert.NoFileExists(t, filepath.Join(dir, "ef", "01", "ef01.png"))
+	repo.AssertNumberOfCalls(t, "MarkOriginalDeleted", 2)
+	assert.Equal(t, []string{"ab/cd/abcd.png", "ef/01/ef01.png"}, repo.locked)
+	assert.Empty(t, repo.held)
+}
+
+func TestSweep_Days(t *testing.T) {
+	repo := new(MockStorage)
+	j, _ := newJanitor(t, repo, config.OriginalsConfig{Retention: RetainDays, RetentionDays: 7, BatchSize: 10})
+
+	repo.On("ExpiredOriginals", mock.MatchedBy(func(statuses []domain.StatusType) bool {
+		return len(statuses) == 4
+	}), mock.MatchedBy(func(before *time.Time) bool {
+		return before != nil && time.Since(*before) > 7*24*time.Hour-time.Minute && time.Since(*before) < 7*24*time.Hour+time.Minute
+	}), 10).Return([]domain.Image{}, nil)
+
+	assert.Equal(t, 0, j.Sweep(context.Background()))
+	repo.AssertExpectations(t)
+}
+
+func TestSweep_StopsOnMarkError(t *testing.T) {
+	repo := new(MockStorage)
+	j, _ := newJanitor(t, repo, config.OriginalsConfig{Retention: RetainAfterProcessing, BatchSize: 1})
+
+	repo.On("ExpiredOriginals", mock.Anything, mock.Anything, 1).Return([]domain.Image{{ID: uuid.New(), Name: "a.png"}}, nil)
+	repo.On("MarkOriginalDeleted", mock.Anything).Return(errors.New("db down"))
+
+	assert.Equal(t, 0, j.Sweep(context.Background()))
+	repo.AssertNumberOfCalls(t, "ExpiredOriginals", 1)
+}
+
+func TestRun_KeepForever(t *testing.T) {
+	repo := new(MockStorage)
+	j, _ := newJanitor(t, repo, config.OriginalsConfig{Retention: RetainForever})
+
+	done := make(chan struct{})
+	go func() {
+		j.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor should not run with retention: forever")
+	}
+	repo.AssertNotCalled(t, "ExpiredOriginals", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,83 @@
+package janitor
+
+import (
+	"context"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+type TrashStorage interface {
+	ExpiredTrash(deletedBefore time.Time, limit int) ([]domain.Image, error)
+}
+
+// ImagePurger удаляет файлы и запись изображения
+type ImagePurger interface {
+	PurgeImage(ctx context.Context, img *domain.Image) error
+}
+
+// Trash окончательно удаляет изображения, пролежавшие в корзине дольше trash.period
+type Trash struct {
+	repo   TrashStorage
+	purger ImagePurger
+	cfg    *config.TrashConfig
+}
+
+func NewTrash(repo TrashStorage, purger ImagePurger, cfg *config.AppConfig) *Trash {
+	return &Trash{
+		repo:   repo,
+		purger: purger,
+		cfg:    &cfg.TrashConfig,
+	}
+}
+
+// Run периодически очищает корзину до отмены ctx; при нулевом purge_interval ничего не делает
+func (t *Trash) Run(ctx context.Context) {
+	if t.cfg.PurgeInterval <= 0 {
+		wbzlog.Logger.Info().Msg("Trash purge interval is not set, trash purge is disabled")
+		return
+	}
+	ticker := time.NewTicker(t.cfg.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		t.Sweep(ctx)
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Trash purge stopping...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep удаляет все изображения с истёкшим сроком в корзине порциями по batch_size
+func (t *Trash) Sweep(ctx context.Context) int {
+	before := time.Now().Add(-t.cfg.Period)
+	limit := t.cfg.BatchSize
+	if limit <= 0 {
+		limit = 100
+	}
+	purged := 0
+	for ctx.Err() == nil {
+		images, err := t.repo.ExpiredTrash(before, limit)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("failed to get expired trash")
+			return purged
+		}
+		for _, img := range images {
+			if err := t.purger.PurgeImage(ctx, &img); err != nil {
+				// неудалённое изображение вернётся в следующей порции, поэтому не продолжаем по кругу
+				return purged
+			}
+			purged++
+		}
+		if len(images) < limit {
+			break
+		}
+	}
+	if purged > 0 {
+		wbzlog.Logger.Info().Int("count", purged).Msg("Purged expired trash")
+	}
+	return purged
+}
@@ -0,0 +1,78 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"testing"
+	"time"
+)
+
+type MockTrashStorage struct {
+	mock.Mock
+}
+
+func (m *MockTrashStorage) ExpiredTrash(deletedBefore time.Time, limit int) ([]domain.Image, error) {
+	args := m.Called(deletedBefore, limit)
+	return args.Get(0).([]domain.Image), args.Error(1)
+}
+
+type MockPurger struct {
+	mock.Mock
+}
+
+func (m *MockPurger) PurgeImage(ctx context.Context, img *domain.Image) error {
+	args := m.Called(img.ID)
+	return args.Error(0)
+}
+
+func TestTrashSweep(t *testing.T) {
+	repo := new(MockTrashStorage)
+	purger := new(MockPurger)
+	trash := NewTrash(repo, purger, &config.AppConfig{TrashConfig: config.TrashConfig{Period: 24 * time.Hour, BatchSize: 2}})
+
+	images := []domain.Image{{ID: uuid.New()}, {ID: uuid.New()}, {ID: uuid.New()}}
+	inPeriod := mock.MatchedBy(func(before time.Time) bool {
+		age := time.Since(before)
+		return age > 24*time.Hour-time.Minute && age < 24*time.Hour+time.Minute
+	})
+	repo.On("ExpiredTrash", inPeriod, 2).Return(images[:2], nil).Once()
+	repo.On("ExpiredTrash", inPeriod, 2).Return(images[2:], nil).Once()
+	purger.On("PurgeImage", mock.Anything).Return(nil)
+
+	assert.Equal(t, 3, trash.Sweep(context.Background()))
+	purger.AssertNumberOfCalls(t, "PurgeImage", 3)
+}
+
+func TestTrashSweep_StopsOnPurgeError(t *testing.T) {
+	repo := new(MockTrashStorage)
+	purger := new(MockPurger)
+	trash := NewTrash(repo, purger, &config.AppConfig{TrashConfig: config.TrashConfig{BatchSize: 1}})
+
+	repo.On("ExpiredTrash", mock.Anything, 1).Return([]domain.Image{{ID: uuid.New()}}, nil)
+	purger.On("PurgeImage", mock.Anything).Return(errors.New("storage down"))
+
+	assert.Equal(t, 0, trash.Sweep(context.Background()))
+	repo.AssertNumberOfCalls(t, "ExpiredTrash", 1)
+}
+
+func TestTrashRun_Disabled(t *testing.T) {
+	repo := new(MockTrashStorage)
+	trash := NewTrash(repo, new(MockPurger), &config.AppConfig{})
+
+	done := make(chan struct{})
+	go func() {
+		trash.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("trash purge should not run without purge_interval")
+	}
+	repo.AssertNotCalled(t, "ExpiredTrash", mock.Anything, mock.Anything)
+}
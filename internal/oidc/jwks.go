@@ -0,0 +1,244 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxJWKSSize — предел ответа JWKS; набор ключей провайдера занимает единицы килобайт
+const maxJWKSSize = 1 << 20
+
+// jwk — ключ из набора JWKS (RFC 7517), поддерживаются RSA и EC
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey — разобранный ключ подписи; alg пуст, если провайдер не ограничил алгоритм ключа
+type publicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet кэширует ключи провайдера. Ключи перечитываются раз в refresh и при встрече неизвестного kid —
+// так подхватывается ротация, — но не чаще minRefresh, чтобы токены с мусорным kid не нагружали провайдера.
+// Если источник недоступен, продолжают действовать последние загруженные ключи
+type keySet struct {
+	load       func(ctx context.Context) ([]byte, error)
+	refresh    time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	keys      []publicKey
+	loadedAt  time.Time
+	attempted time.Time
+}
+
+func newKeySet(load func(ctx context.Context) ([]byte, error), refresh, minRefresh time.Duration) *keySet {
+	return &keySet{
+		load:       load,
+		refresh:    refresh,
+		minRefresh: minRefresh,
+		now:        time.Now,
+	}
+}
+
+// urlSource загружает JWKS по HTTP
+func urlSource(client *http.Client, url string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks endpoint returned %d", resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	}
+}
+
+// fileSource читает JWKS из файла, например смонтированного секрета, который обновляется при ротации
+func fileSource(path string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// lookup возвращает ключи с заданным kid (все ключи, если kid пуст)
+func (s *keySet) lookup(ctx context.Context, kid string) ([]publicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	stale := s.loadedAt.IsZero() || now.Sub(s.loadedAt) >= s.refresh
+	if stale {
+		s.reload(ctx, now)
+	}
+	keys := matchKeys(s.keys, kid)
+	if len(keys) == 0 && !stale && now.Sub(s.attempted) >= s.minRefresh {
+		// неизвестный kid — вероятно, провайдер уже подписывает новым ключом
+		s.reload(ctx, now)
+		keys = matchKeys(s.keys, kid)
+	}
+	if s.loadedAt.IsZero() {
+		return nil, ErrKeysUnavailable
+	}
+	return keys, nil
+}
+
+func (s *keySet) reload(ctx context.Context, now time.Time) {
+	if !s.attempted.IsZero() && now.Sub(s.attempted) < s.minRefresh {
+		return
+	}
+	s.attempted = now
+	data, err := s.load(ctx)
+	if err == nil {
+		var keys []publicKey
+		keys, err = parseJWKS(data)
+		if err == nil {
+			s.keys = keys
+			s.loadedAt = now
+			return
+		}
+	}
+	wbzlog.Logger.Warn().Err(err).Int("cached_keys", len(s.keys)).Msg("Failed to load JWKS")
+}
+
+func matchKeys(keys []publicKey, kid string) []publicKey {
+	if kid == "" {
+		return keys
+	}
+	var matched []publicKey
+	for _, k := range keys {
+		if k.kid == kid {
+			matched = append(matched, k)
+		}
+	}
+	return matched
+}
+
+// parseJWKS разбирает набор ключей; ключи шифрования и неподдерживаемых типов пропускаются
+func parseJWKS(data []byte) ([]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			wbzlog.Logger.Warn().Err(err).Str("kid", k.Kid).Msg("Skipping unsupported JWKS key")
+			continue
+		}
+		keys = append(keys, publicKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("rsa key is shorter than 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, err := decodeCoordinate(k.X, size)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeCoordinate(k.Y, size)
+		if err != nil {
+			return nil, err
+		}
+		// разбор несжатой точки заодно проверяет, что она лежит на кривой
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeCoordinate декодирует координату EC-точки фиксированной для кривой длины
+func decodeCoordinate(s string, size int) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key parameter: %w", err)
+	}
+	if len(b) != size {
+		return nil, errors.New("invalid ec coordinate length")
+	}
+	return b, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
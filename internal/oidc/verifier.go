@@ -0,0 +1,243 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // хэши алгоритмов регистрируются импортом пакетов
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrKeysUnavailable = errors.New("identity provider keys are unavailable")
+)
+
+// algorithm — поддерживаемый алгоритм подписи JWS (RFC 7518); none и HMAC не принимаются,
+// иначе токен можно было бы подписать открытым ключом провайдера
+type algorithm struct {
+	hash  crypto.Hash
+	kty   string
+	pss   bool
+	curve int // размер кривой ES-алгоритма в битах
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256, kty: "RSA"},
+	"RS384": {hash: crypto.SHA384, kty: "RSA"},
+	"RS512": {hash: crypto.SHA512, kty: "RSA"},
+	"PS256": {hash: crypto.SHA256, kty: "RSA", pss: true},
+	"PS384": {hash: crypto.SHA384, kty: "RSA", pss: true},
+	"PS512": {hash: crypto.SHA512, kty: "RSA", pss: true},
+	"ES256": {hash: crypto.SHA256, kty: "EC", curve: 256},
+	"ES384": {hash: crypto.SHA384, kty: "EC", curve: 384},
+	"ES512": {hash: crypto.SHA512, kty: "EC", curve: 521},
+}
+
+// Verifier проверяет JWT провайдера единого входа: подпись ключом из JWKS, iss, aud, exp и nbf,
+// и сопоставляет claims арендатору и правам
+type Verifier struct {
+	cfg  config.OIDCConfig
+	keys *keySet
+	now  func() time.Time
+}
+
+func NewVerifier(cfg *config.AppConfig) *Verifier {
+	oidc := cfg.AuthConfig.OIDC
+	load := fileSource(oidc.JWKSFile)
+	if oidc.JWKSURL != "" {
+		load = urlSource(&http.Client{Timeout: oidc.Timeout}, oidc.JWKSURL)
+	}
+	return &Verifier{
+		cfg:  oidc,
+		keys: newKeySet(load, oidc.JWKSRefresh, oidc.JWKSMinRefresh),
+		now:  time.Now,
+	}
+}
+
+// LooksLikeJWT отличает JWT (три сегмента base64url, заголовок — JSON-объект) от ключей API и статических токенов
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience — claim aud: строка или массив строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// Verify проверяет токен и возвращает, кто выполняет запрос. Ошибки самого токена оборачивают ErrInvalidToken
+func (v *Verifier) Verify(ctx context.Context, token string) (*domain.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	alg, ok := algorithms[hdr.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, hdr.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	keys, err := v.keys.lookup(ctx, hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(keys, hdr.Alg, alg, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed payload", ErrInvalidToken)
+	}
+	var std claims
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &std); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := v.validate(std); err != nil {
+		return nil, err
+	}
+	return v.principal(std, raw)
+}
+
+func (v *Verifier) validate(c claims) error {
+	now := v.now()
+	if c.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if !c.Audience.contains(v.cfg.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: token has no expiry", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(*c.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if c.NotBefore != nil && now.Add(v.cfg.Leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	return nil
+}
+
+// principal сопоставляет claims арендатору и правам; права, неизвестные сервису (openid, profile), отбрасываются
+func (v *Verifier) principal(c claims, raw map[string]json.RawMessage) (*domain.Principal, error) {
+	var tenant string
+	if err := json.Unmarshal(raw[v.cfg.TenantClaim], &tenant); err != nil || !config.ValidTenantID(tenant) {
+		return nil, fmt.Errorf("%w: missing or invalid %s claim", ErrInvalidToken, v.cfg.TenantClaim)
+	}
+	var scopes []string
+	if value, ok := raw[v.cfg.ScopesClaim]; ok {
+		var joined string
+		if err := json.Unmarshal(value, &joined); err == nil {
+			scopes = strings.Fields(joined)
+		} else if err := json.Unmarshal(value, &scopes); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s claim", ErrInvalidToken, v.cfg.ScopesClaim)
+		}
+	}
+	return &domain.Principal{Tenant: tenant, Scopes: domain.KnownScopes(scopes), Subject: c.Subject}, nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, value := range a {
+		if value == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature проверяет подпись любым подходящим ключом: без kid у провайдера может быть несколько ключей
+func verifySignature(keys []publicKey, name string, alg algorithm, signed, signature []byte) bool {
+	h := alg.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	for _, k := range keys {
+		if k.alg != "" && k.alg != name {
+			continue
+		}
+		switch key := k.key.(type) {
+		case *rsa.PublicKey:
+			if alg.kty != "RSA" {
+				continue
+			}
+			var err error
+			if alg.pss {
+				err = rsa.VerifyPSS(key, alg.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+			} else {
+				err = rsa.VerifyPKCS1v15(key, alg.hash, digest, signature)
+			}
+			if err == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if alg.kty != "EC" || key.Curve.Params().BitSize != alg.curve {
+				continue
+			}
+			// подпись JWS — r и s фиксированной длины подряд, а не ASN.1
+			size := (alg.curve + 7) / 8
+			if len(signature) != 2*size {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/"
+	testAudience = "image-processor"
+)
+
+// signer — локально сгенерированный ключ провайдера
+type signer struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func newRSASigner(t *testing.T, kid string) *signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return &signer{kid: kid, alg: "RS256", key: key}
+}
+
+func newECSigner(t *testing.T, kid string) *signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	return &signer{kid: kid, alg: "ES256", key: key}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (s *signer) jwk() map[string]string {
+	switch key := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "use": "sig", "alg": s.alg,
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		point, _ := key.Bytes()
+		size := (len(point) - 1) / 2
+		return map[string]string{"kty": "EC", "kid": s.kid, "use": "sig", "crv": "P-256",
+			"x": b64(point[1 : 1+size]), "y": b64(point[1+size:])}
+	}
+	return nil
+}
+
+func jwks(t *testing.T, signers ...*signer) []byte {
+	t.Helper()
+	keys := make([]map[string]string, 0, len(signers))
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	assert.NoError(t, err)
+	return data
+}
+
+func (s *signer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	hdr, err := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	assert.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	signed := b64(hdr) + "." + b64(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	sum := digest.Sum(nil)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum)
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, sv, err := ecdsa.Sign(rand.Reader, key, sum)
+		assert.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), sv.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":    testIssuer,
+		"aud":    []string{"other", testAudience},
+		"sub":    "user-1",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"tenant": "acme",
+		"scope":  "openid profile images:read images:write",
+	}
+}
+
+func oidcConfig(jwksFile, jwksURL string) *config.AppConfig {
+	return &config.AppConfig{AuthConfig: config.AuthConfig{OIDC: config.OIDCConfig{
+		Issuer:         testIssuer,
+		Audience:       testAudience,
+		JWKSFile:       jwksFile,
+		JWKSURL:        jwksURL,
+		JWKSRefresh:    time.Hour,
+		JWKSMinRefresh: time.Minute,
+		Timeout:        time.Second,
+		Leeway:         time.Minute,
+		TenantClaim:    "tenant",
+		ScopesClaim:    "scope",
+	}}}
+}
+
+func fileVerifier(t *testing.T, signers ...*signer) *Verifier {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks(t, signers...), 0600))
+	return NewVerifier(oidcConfig(path, ""))
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey := newRSASigner(t, "rsa-1")
+	ecKey := newECSigner(t, "ec-1")
+	verifier := fileVerifier(t, rsaKey, ecKey)
+
+	for _, s := range []*signer{rsaKey, ecKey} {
+		t.Run(s.alg, func(t *testing.T) {
+			principal, err := verifier.Verify(context.Background(), s.sign(t, validClaims()))
+			assert.NoError(t, err)
+			assert.Equal(t, &domain.Principal{
+				Tenant:  "acme",
+				Scopes:  domain.Scopes{domain.ScopeImagesRead, domain.ScopeImagesWrite},
+				Subject: "user-1",
+			}, principal)
+		})
+	}
+}
+
+func TestVerify_ScopesArray(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	verifier := fileVerifier(t, key)
+	claims := validClaims()
+	claims["scope"] = []string{"admin"}
+
+	principal, err := verifier.Verify(context.Background(), key.sign(t, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Scopes{domain.ScopeAdmin}, principal.Scopes)
+}
+
+func TestVerify_Rejected(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	verifier := fileVerifier(t, key)
+	stranger := newRSASigner(t, "rsa-1")
+
+	with := func(name string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tampered := key.sign(t, validClaims())
+	parts := strings.Split(tampered, ".")
+	forged, _ := json.Marshal(with("tenant", "globex"))
+	tampered = parts[0] + "." + b64(forged) + "." + parts[2]
+
+	noneHeader, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(validClaims())
+
+	tokens := map[string]string{
+		"wrong issuer":      key.sign(t, with("iss", "https://evil.example.com/")),
+		"wrong audience":    key.sign(t, with("aud", "other")),
+		"expired":           key.sign(t, with("exp", time.Now().Add(-2*time.Minute).Unix())),
+		"no expiry":         key.sign(t, with("exp", nil)),
+		"not yet valid":     key.sign(t, with("nbf", time.Now().Add(2*time.Minute).Unix())),
+		"no tenant":         key.sign(t, with("tenant", nil)),
+		"unsafe tenant":     key.sign(t, with("tenant", "../acme")),
+		"unknown signer":    stranger.sign(t, validClaims()),
+		"tampered payload":  tampered,
+		"alg none":          b64(noneHeader) + "." + b64(payload) + ".",
+		"malformed":         "eyJ.not.a-token",
+		"missing signature": parts[0] + "." + parts[1],
+	}
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestVerify_Leeway(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	verifier := fileVerifier(t, key)
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+
+	_, err := verifier.Verify(context.Background(), key.sign(t, claims))
+	assert.NoError(t, err)
+}
+
+func TestVerify_KeyRotation(t *testing.T) {
+	oldKey := newRSASigner(t, "2025")
+	newKey := newECSigner(t, "2026")
+	var current atomic.Value
+	current.Store(jwks(t, oldKey))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(current.Load().([]byte))
+	}))
+	defer server.Close()
+
+	verifier := NewVerifier(oidcConfig("", server.URL))
+	now := time.Now()
+	verifier.keys.now = func() time.Time { return now }
+
+	_, err := verifier.Verify(context.Background(), oldKey.sign(t, validClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), oldKey.sign(t, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "keys are cached")
+
+	// провайдер перешёл на новый ключ: неизвестный kid перечитывает JWKS, но не чаще jwks_min_refresh
+	current.Store(jwks(t, newKey))
+	_, err = verifier.Verify(context.Background(), newKey.sign(t, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(time.Minute)
+	_, err = verifier.Verify(context.Background(), newKey.sign(t, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// старый ключ выведен из набора
+	_, err = verifier.Verify(context.Background(), oldKey.sign(t, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerify_KeysUnavailable(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	fail := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(jwks(t, key))
+	}))
+	defer server.Close()
+
+	fail.Store(true)
+	verifier := NewVerifier(oidcConfig("", server.URL))
+	now := time.Now()
+	verifier.keys.now = func() time.Time { return now }
+	_, err := verifier.Verify(context.Background(), key.sign(t, validClaims()))
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+
+	fail.Store(false)
+	now = now.Add(time.Minute)
+	_, err = verifier.Verify(context.Background(), key.sign(t, validClaims()))
+	assert.NoError(t, err)
+
+	// после истечения jwks_refresh провайдер недоступен, но загруженные ключи продолжают действовать
+	fail.Store(true)
+	now = now.Add(2 * time.Hour)
+	_, err = verifier.Verify(context.Background(), key.sign(t, validClaims()))
+	assert.NoError(t, err)
+}
+
+func TestParseJWKS_SkipsUnusableKeys(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	data := `{"keys":[
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AAAA"},
+		{"kty":"RSA","kid":"short","n":"AQAB","e":"AQAB"},
+		{"kty":"EC","kid":"off-curve","crv":"P-256","x":"` + b64(make([]byte, 32)) + `","y":"` + b64(make([]byte, 32)) + `"},
+		` + string(mustJSON(t, key.jwk())) + `]}`
+
+	keys, err := parseJWKS([]byte(data))
+	assert.NoError(t, err)
+	if !assert.Len(t, keys, 1) {
+		return
+	}
+	assert.Equal(t, "rsa-1", keys[0].kid)
+
+	_, err = parseJWKS([]byte(`{"keys":[]}`))
+	assert.Error(t, err)
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	key := newRSASigner(t, "rsa-1")
+	assert.True(t, LooksLikeJWT(key.sign(t, validClaims())))
+	assert.False(t, LooksLikeJWT("ipk_0123456789abcdef"))
+	assert.False(t, LooksLikeJWT("tok-acme"))
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return data
+}
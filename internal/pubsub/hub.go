@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"sync"
+)
+
+const subscriptionBuffer = 16
+
+// Hub — внутрипроцессная рассылка событий смены статуса подписчикам (SSE, WebSocket)
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription получает события только по изображениям из своего набора ID,
+// набор можно менять на лету (мультиплексирование в одном WebSocket)
+type Subscription struct {
+	C <-chan *domain.StatusEvent
+
+	ch     chan *domain.StatusEvent
+	hub    *Hub
+	mu     sync.RWMutex
+	ids    map[uuid.UUID]struct{}
+	closed bool
+}
+
+func (h *Hub) Subscribe(ids ...uuid.UUID) *Subscription {
+	ch := make(chan *domain.StatusEvent, subscriptionBuffer)
+	sub := &Subscription{
+		C:   ch,
+		ch:  ch,
+		hub: h,
+		ids: make(map[uuid.UUID]struct{}, len(ids)),
+	}
+	for _, id := range ids {
+		sub.ids[id] = struct{}{}
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// PublishEvent рассылает событие подписчикам; медленный подписчик с заполненным буфером
+// пропускает событие, чтобы не блокировать воркер
+func (h *Hub) PublishEvent(event *domain.StatusEvent) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.Has(event.ImageID) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			wbzlog.Logger.Warn().Str("image_id", event.ImageID.String()).Msg("status subscriber is full, event dropped")
+		}
+	}
+	return nil
+}
+
+func (s *Subscription) Add(id uuid.UUID) {
+	s.mu.Lock()
+	s.ids[id] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *Subscription) Remove(id uuid.UUID) {
+	s.mu.Lock()
+	delete(s.ids, id)
+	s.mu.Unlock()
+}
+
+func (s *Subscription) Has(id uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.ids[id]
+	return ok
+}
+
+// Close отписывает от хаба и закрывает канал C; повторный вызов безопасен
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.hub.subs, s)
+	close(s.ch)
+}
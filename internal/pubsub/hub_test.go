@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/domain"
+	"testing"
+)
+
+func TestHub_DeliversOnlySubscribedIDs(t *testing.T) {
+	hub := NewHub()
+	id := uuid.New()
+	other := uuid.New()
+
+	sub := hub.Subscribe(id)
+	defer sub.Close()
+
+	assert.NoError(t, hub.PublishEvent(domain.NewStatusEvent(other, domain.Processing)))
+	assert.NoError(t, hub.PublishEvent(domain.NewStatusEvent(id, domain.Processed)))
+
+	event := <-sub.C
+	assert.Equal(t, id, event.ImageID)
+	assert.Equal(t, domain.Processed, event.Status)
+	assert.Len(t, sub.C, 0)
+}
+
+func TestSubscription_AddRemove(t *testing.T) {
+	hub := NewHub()
+	id := uuid.New()
+
+	sub := hub.Subscribe()
+	defer sub.Close()
+
+	sub.Add(id)
+	_ = hub.PublishEvent(domain.NewStatusEvent(id, domain.Processing))
+	assert.Len(t, sub.C, 1)
+
+	sub.Remove(id)
+	_ = hub.PublishEvent(domain.NewStatusEvent(id, domain.Processed))
+	assert.Len(t, sub.C, 1)
+}
+
+func TestHub_FullSubscriberDoesNotBlock(t *testing.T) {
+	hub := NewHub()
+	id := uuid.New()
+	sub := hub.Subscribe(id)
+	defer sub.Close()
+
+	for i := 0; i < subscriptionBuffer+5; i++ {
+		assert.NoError(t, hub.PublishEvent(domain.NewStatusEvent(id, domain.Processing)))
+	}
+	assert.Len(t, sub.C, subscriptionBuffer)
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := NewHub()
+	id := uuid.New()
+	sub := hub.Subscribe(id)
+
+	sub.Close()
+	sub.Close()
+
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	assert.NoError(t, hub.PublishEvent(domain.NewStatusEvent(id, domain.Processed)))
+}
@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"math"
+	"sync"
+	"time"
+)
+
+// Class — группа маршрутов со своим бюджетом запросов
+type Class string
+
+const (
+	ClassUpload Class = "upload"
+	ClassRead   Class = "read"
+	ClassDelete Class = "delete"
+	// ClassWrite — изменения без загрузки файла: отмена обработки, отмена tus-загрузки
+	ClassWrite Class = "write"
+)
+
+// Store хранит token bucket'ы клиентов. TakeRateToken пополняет bucket key ёмкостью burst со скоростью rate
+// токенов в секунду и забирает токен, если он есть; возвращает остаток и признак, что токен выдан.
+// SweepRateLimits удаляет bucket'ы, не тронутые дольше idle: за это время они всё равно наполнились бы целиком
+type Store interface {
+	TakeRateToken(key string, burst, rate float64) (float64, bool, error)
+	SweepRateLimits(idle time.Duration) (int, error)
+}
+
+// NewStore выбирает хранилище bucket'ов по rate_limit.store; shared — общее для реплик хранилище в Postgres
+func NewStore(cfg *config.AppConfig, shared Store) (Store, error) {
+	switch cfg.RateLimitConfig.Store {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return shared, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", cfg.RateLimitConfig.Store)
+	}
+}
+
+// Limit — бюджет класса маршрутов
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    float64
+	Rate     float64 // токенов в секунду
+}
+
+// Decision — результат проверки лимита для заголовков RateLimit-*
+type Decision struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	Reset      time.Duration // через сколько bucket наполнится целиком
+	RetryAfter time.Duration // через сколько появится токен, если запрос отклонён
+}
+
+// Limiter ограничивает частоту запросов клиентов по классам маршрутов и число их одновременных загрузок
+type Limiter struct {
+	store         Store
+	limits        map[Class]Limit
+	concurrency   int
+	sweepInterval time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]int
+}
+
+func NewLimiter(store Store, cfg *config.AppConfig) *Limiter {
+	rl := cfg.RateLimitConfig
+	limits := make(map[Class]Limit)
+	for class, budget := range map[Class]config.RateBudget{ClassUpload: rl.Upload, ClassRead: rl.Read, ClassDelete: rl.Delete, ClassWrite: rl.Write} {
+		if limit, ok := newLimit(budget); ok {
+			limits[class] = limit
+		}
+	}
+	return &Limiter{
+		store:         store,
+		limits:        limits,
+		concurrency:   rl.UploadConcurrency,
+		sweepInterval: rl.SweepInterval,
+		inflight:      make(map[string]int),
+	}
+}
+
+func newLimit(b config.RateBudget) (Limit, bool) {
+	if b.Requests <= 0 || b.Period <= 0 {
+		return Limit{}, false
+	}
+	burst := b.Burst
+	if burst <= 0 {
+		burst = b.Requests
+	}
+	return Limit{
+		Requests: b.Requests,
+		Period:   b.Period,
+		Burst:    float64(burst),
+		Rate:     float64(b.Requests) / b.Period.Seconds(),
+	}, true
+}
+
+// Allow забирает токен клиента client из бюджета класса; без бюджета класс не ограничен и Decision.Limit пуст
+func (l *Limiter) Allow(class Class, client string) (Decision, error) {
+	limit, ok := l.limits[class]
+	if !ok {
+		return Decision{Allowed: true}, nil
+	}
+	tokens, allowed, err := l.store.TakeRateToken(string(class)+":"+client, limit.Burst, limit.Rate)
+	if err != nil {
+		return Decision{}, fmt.Errorf("take rate limit token: %w", err)
+	}
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((limit.Burst - tokens) / limit.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return d, nil
+}
+
+// Acquire занимает место одновременной загрузки клиента; release нужно вызвать по её завершении.
+// Счётчик ведётся в памяти реплики
+func (l *Limiter) Acquire(client string) (func(), bool) {
+	if l.concurrency <= 0 {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[client] >= l.concurrency {
+		return nil, false
+	}
+	l.inflight[client]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.inflight[client]--; l.inflight[client] <= 0 {
+				delete(l.inflight, client)
+			}
+		})
+	}, true
+}
+
+// Run периодически удаляет наполнившиеся bucket'ы до отмены ctx
+func (l *Limiter) Run(ctx context.Context) {
+	if l.sweepInterval <= 0 || len(l.limits) == 0 {
+		return
+	}
+	ticker := time.NewTicker(l.sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Rate limit sweeper stopping...")
+			return
+		case <-ticker.C:
+		}
+		removed, err := l.store.SweepRateLimits(l.idle())
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to sweep rate limit buckets")
+			continue
+		}
+		wbzlog.Logger.Debug().Int("removed", removed).Msg("Rate limit buckets swept")
+	}
+}
+
+// idle — время, за которое наполняется самый медленный bucket
+func (l *Limiter) idle() time.Duration {
+	var idle time.Duration
+	for _, limit := range l.limits {
+		if full := time.Duration(limit.Burst / limit.Rate * float64(time.Second)); full > idle {
+			idle = full
+		}
+	}
+	return idle
+}
+
+// seconds округляет вверх до целых секунд, как их передают заголовки
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s)) * time.Second
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"testing"
+	"time"
+)
+
+// newTestLimiter — бюджет загрузок 60 запросов в минуту (токен в секунду) с запасом 3
+func newTestLimiter(concurrency int) (*Limiter, *MemoryStore, *time.Time) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	cfg := &config.AppConfig{RateLimitConfig: config.RateLimitConfig{
+		Upload:            config.RateBudget{Requests: 60, Period: time.Minute, Burst: 3},
+		Read:              config.RateBudget{Requests: 10, Period: time.Second},
+		UploadConcurrency: concurrency,
+	}}
+	return NewLimiter(store, cfg), store, &now
+}
+
+func TestAllow_TokenBucket(t *testing.T) {
+	limiter, _, now := newTestLimiter(0)
+
+	for i := 2; i >= 0; i-- {
+		d, err := limiter.Allow(ClassUpload, "key:a")
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+		assert.Equal(t, 60, d.Limit.Requests)
+	}
+	d, err := limiter.Allow(ClassUpload, "key:a")
+	assert.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.Reset)
+
+	// другой клиент и другой класс считаются отдельно
+	d, _ = limiter.Allow(ClassUpload, "key:b")
+	assert.True(t, d.Allowed)
+	d, _ = limiter.Allow(ClassRead, "key:a")
+	assert.True(t, d.Allowed)
+
+	// за секунду восстанавливается один токен
+	*now = now.Add(time.Second)
+	d, _ = limiter.Allow(ClassUpload, "key:a")
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	d, _ = limiter.Allow(ClassUpload, "key:a")
+	assert.False(t, d.Allowed)
+
+	// запас не превышает burst
+	*now = now.Add(time.Hour)
+	d, _ = limiter.Allow(ClassUpload, "key:a")
+	assert.Equal(t, 2, d.Remaining)
+}
+
+func TestAllow_BurstDefaultsToRequests(t *testing.T) {
+	limiter, _, _ := newTestLimiter(0)
+	d, err := limiter.Allow(ClassRead, "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), d.Limit.Burst)
+	assert.Equal(t, 9, d.Remaining)
+}
+
+func TestAllow_UnlimitedClass(t *testing.T) {
+	limiter, store, _ := newTestLimiter(0)
+	for i := 0; i < 100; i++ {
+		d, err := limiter.Allow(ClassDelete, "key:a")
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Zero(t, d.Limit.Requests)
+	}
+	assert.Empty(t, store.buckets)
+}
+
+func TestAcquire(t *testing.T) {
+	limiter, _, _ := newTestLimiter(2)
+
+	release1, ok := limiter.Acquire("key:a")
+	assert.True(t, ok)
+	_, ok = limiter.Acquire("key:a")
+	assert.True(t, ok)
+	_, ok = limiter.Acquire("key:a")
+	assert.False(t, ok)
+	_, ok = limiter.Acquire("key:b")
+	assert.True(t, ok)
+
+	release1()
+	release1() // повторный вызов не освобождает чужое место
+	_, ok = limiter.Acquire("key:a")
+	assert.True(t, ok)
+	_, ok = limiter.Acquire("key:a")
+	assert.False(t, ok)
+}
+
+func TestAcquire_Unlimited(t *testing.T) {
+	limiter, _, _ := newTestLimiter(0)
+	for i := 0; i < 10; i++ {
+		_, ok := limiter.Acquire("key:a")
+		assert.True(t, ok)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	limiter, store, now := newTestLimiter(0)
+	_, _ = limiter.Allow(ClassUpload, "key:a")
+	*now = now.Add(2 * time.Second)
+	_, _ = limiter.Allow(ClassRead, "key:b")
+
+	// самый медленный bucket (загрузки) наполняется за 3 секунды
+	assert.Equal(t, 3*time.Second, limiter.idle())
+	*now = now.Add(2 * time.Second)
+	removed, err := store.SweepRateLimits(limiter.idle())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Contains(t, store.buckets, "read:key:b")
+}
+
+func TestRun_StopsOnCancel(t *testing.T) {
+	limiter, _, _ := newTestLimiter(0)
+	limiter.sweepInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		limiter.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	shared := NewMemoryStore()
+	store, err := NewStore(&config.AppConfig{}, shared)
+	assert.NoError(t, err)
+	assert.NotSame(t, shared, store)
+
+	store, err = NewStore(&config.AppConfig{RateLimitConfig: config.RateLimitConfig{Store: "postgres"}}, shared)
+	assert.NoError(t, err)
+	assert.Same(t, shared, store)
+
+	_, err = NewStore(&config.AppConfig{RateLimitConfig: config.RateLimitConfig{Store: "redis"}}, shared)
+	assert.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStore — bucket'ы в памяти реплики: при нескольких репликах каждая считает лимит отдельно
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryStore) TakeRateToken(key string, burst, rate float64) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updatedAt: now}
+		s.buckets[key] = b
+	}
+	elapsed := math.Max(0, now.Sub(b.updatedAt).Seconds())
+	b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	b.updatedAt = now
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+func (s *MemoryStore) SweepRateLimits(idle time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := s.now().Add(-idle)
+	removed := 0
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(before) {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+const apiKeyColumns = `id, tenant_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+func (s *Postgres) SaveAPIKey(key *domain.APIKey) error {
+	ctx := context.Background()
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+	query := `
+		INSERT INTO api_keys (id, tenant_id, name, prefix, key_hash, scopes, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query,
+		key.ID,
+		key.TenantID,
+		key.Name,
+		key.Prefix,
+		key.Hash,
+		pq.Array(scopes),
+		key.CreatedAt,
+	)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert api key query")
+		return err
+	}
+	return nil
+}
+
+// GetAPIKeyByHash ищет действующий ключ по хэшу; отозванные ключи не находятся
+func (s *Postgres) GetAPIKeyByHash(hash string) (*domain.APIKey, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, hash)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get api key query")
+		return nil, err
+	}
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get api key query (scan)")
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает ключи арендатора, включая отозванные, новые первыми
+func (s *Postgres) ListAPIKeys(tenant string) ([]domain.APIKey, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute list api keys query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan api key row")
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey отзывает ключ арендатора; повторный отзыв и чужой ключ — domain.ErrAPIKeyNotFound
+func (s *Postgres) RevokeAPIKey(tenant, id string) error {
+	ctx := context.Background()
+	query := `
+		UPDATE api_keys
+		SET revoked_at = now()
+		WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL
+	`
+	res, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute revoke api key query")
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey записывает время последнего использования ключа
+func (s *Postgres) TouchAPIKey(id string, at time.Time) error {
+	ctx := context.Background()
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs},
+		`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute touch api key query")
+		return err
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes []string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		pq.Array(&scopes),
+		&key.CreatedAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, domain.Scope(scope))
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
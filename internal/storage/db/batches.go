@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+)
+
+func (s *Postgres) SaveBatch(b *domain.Batch) error {
+	ctx := context.Background()
+	query := `
+		INSERT INTO batches (id, created_at, total, tenant_id)
+		VALUES($1, $2, $3, $4)
+	`
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, b.ID, b.CreatedAt, b.Total, b.TenantID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert batch query")
+		return err
+	}
+	return nil
+}
+
+// GetBatchProgress считает статусы изображений пакета арендатора; изображения пакета принадлежат его арендатору
+func (s *Postgres) GetBatchProgress(tenant, id string) (*domain.BatchProgress, error) {
+	ctx := context.Background()
+	var batch domain.Batch
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs},
+		`SELECT id, tenant_id, created_at, total FROM batches WHERE id = $1 AND tenant_id = $2`, id, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get batch query")
+		return nil, err
+	}
+	if err := row.Scan(&batch.ID, &batch.TenantID, &batch.CreatedAt, &batch.Total); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrBatchNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get batch query (scan)")
+		return nil, err
+	}
+
+	query := `
+		SELECT status, count(*)
+		FROM images
+		WHERE batch_id = $1 AND tenant_id = $2
+		GROUP BY status
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute batch progress query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	counts := make(map[domain.StatusType]int)
+	for rows.Next() {
+		var status domain.StatusType
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan batch progress row")
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return domain.NewBatchProgress(batch, counts), rows.Err()
+}
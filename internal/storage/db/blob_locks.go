@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// blobLockRetry — пауза между попытками взять занятую блокировку объекта
+const blobLockRetry = 20 * time.Millisecond
+
+// blobLockSlots — сколько блокировок объектов реплика держит одновременно. Каждая занимает соединение,
+// а её держатель ещё пишет ссылку в БД, поэтому блокировкам отдаётся не больше половины пула
+func blobLockSlots(maxOpenConns int) chan struct{} {
+	if maxOpenConns <= 0 {
+		return nil
+	}
+	return make(chan struct{}, max(1, maxOpenConns/2))
+}
+
+// LockBlob берёт advisory-блокировку ключа объекта хранилища файлов и держит её на отдельном соединении до вызова
+// unlock, поэтому блокировка действует между репликами и не зависит от транзакций. Занятая блокировка ожидается
+// через pg_try_advisory_lock без удержания соединения
+func (s *Postgres) LockBlob(ctx context.Context, key string) (func(), error) {
+	if s.blobLocks != nil {
+		select {
+		case s.blobLocks <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if s.blobLocks != nil {
+			<-s.blobLocks
+		}
+	}
+	for {
+		conn, err := s.db.Master.Conn(ctx)
+		if err != nil {
+			release()
+			wbzlog.Logger.Error().Err(err).Msg("Failed to get connection for blob lock")
+			return nil, err
+		}
+		var locked bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, key).Scan(&locked); err != nil {
+			_ = conn.Close()
+			release()
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute blob lock query")
+			return nil, err
+		}
+		if locked {
+			return func() {
+				if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, key); err != nil {
+					wbzlog.Logger.Error().Err(err).Str("key", key).Msg("Failed to release blob lock")
+					// соединение с неснятой блокировкой не должно вернуться в пул
+					_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+				}
+				_ = conn.Close()
+				release()
+			}, nil
+		}
+		_ = conn.Close()
+		select {
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		case <-time.After(blobLockRetry):
+		}
+	}
+}
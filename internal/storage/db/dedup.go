@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+)
+
+// FindProcessedDuplicate ищет обработанное изображение того же арендатора с тем же исходником (по SHA-256) и теми же
+// операциями, результат которого лежит в хранилище по содержимому; если такого нет — domain.ErrImageNotFound.
+// Результаты других арендаторов не переиспользуются: они лежат в чужом пространстве хранилища
+func (s *Postgres) FindProcessedDuplicate(img *domain.Image) (*domain.Image, error) {
+	if img.Source == nil || img.Source.SHA256 == "" {
+		return nil, domain.ErrImageNotFound
+	}
+	ctx := context.Background()
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE source_sha256 = $1
+			AND tenant_id = $7
+			AND status = 'processed'
+			AND output_key IS NOT NULL
+			AND format = $2
+			AND COALESCE(watermark, '') = $3
+			AND COALESCE(resize_width, 0) = $4
+			AND COALESCE(resize_height, 0) = $5
+			AND mini = $6
+		ORDER BY processed_at DESC
+		LIMIT 1
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query,
+		img.Source.SHA256, img.Format, img.Watermark, img.Resize.Width, img.Resize.Height, img.Mini, img.TenantID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute find duplicate query")
+		return nil, err
+	}
+	duplicate, err := scanImage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrImageNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute find duplicate query (scan)")
+		return nil, err
+	}
+	return duplicate, nil
+}
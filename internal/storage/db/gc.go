@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+)
+
+// ReferencedOriginals возвращает ключи из keys, под которыми лежит неудалённый исходник хотя бы одного изображения
+func (s *Postgres) ReferencedOriginals(keys []string) ([]string, error) {
+	query := `
+		SELECT k FROM unnest($1::text[]) AS k
+		WHERE EXISTS (SELECT 1 FROM images WHERE source_key = k AND original_deleted_at IS NULL)
+			OR EXISTS (SELECT 1 FROM images WHERE name = k AND source_key IS NULL AND original_deleted_at IS NULL)
+	`
+	return s.referencedKeys(query, keys)
+}
+
+// ReferencedOutputs возвращает ключи из keys, под которыми лежит результат обработки хотя бы одного изображения
+func (s *Postgres) ReferencedOutputs(keys []string) ([]string, error) {
+	query := `
+		SELECT k FROM unnest($1::text[]) AS k
+		WHERE EXISTS (SELECT 1 FROM images WHERE output_key = k)
+			OR EXISTS (SELECT 1 FROM images WHERE name = k AND output_key IS NULL)
+	`
+	return s.referencedKeys(query, keys)
+}
+
+func (s *Postgres) referencedKeys(query string, keys []string) ([]string, error) {
+	ctx := context.Background()
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, pq.Array(keys))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute referenced keys query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	referenced := make([]string, 0, len(keys))
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan referenced key")
+			return nil, err
+		}
+		referenced = append(referenced, key)
+	}
+	return referenced, rows.Err()
+}
+
+// ImagesAfter постранично обходит все изображения в порядке id, начиная после after (uuid.Nil — с начала)
+func (s *Postgres) ImagesAfter(after uuid.UUID, limit int) ([]domain.Image, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, after, limit)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute images after query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	images := make([]domain.Image, 0, limit)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan image row")
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+	return images, rows.Err()
+}
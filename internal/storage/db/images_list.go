@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"strings"
+)
+
+// ListImages возвращает до filter.Limit+1 изображений: лишняя строка означает, что есть следующая страница
+func (s *Postgres) ListImages(filter domain.ImageFilter) ([]domain.Image, error) {
+	ctx := context.Background()
+	query, args := listImagesQuery(filter)
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute list images query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	images := make([]domain.Image, 0, filter.Limit+1)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan image row")
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+	return images, rows.Err()
+}
+
+func listImagesQuery(filter domain.ImageFilter) (string, []any) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where = append(where, "tenant_id = "+arg(filter.TenantID))
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, st := range filter.Statuses {
+			statuses = append(statuses, string(st))
+		}
+		where = append(where, "status = ANY("+arg(pq.Array(statuses))+")")
+	} else {
+		where = append(where, "status != 'deleted'")
+	}
+	if len(filter.Formats) > 0 {
+		where = append(where, "format = ANY("+arg(pq.Array(filter.Formats))+")")
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, "created_at < "+arg(*filter.CreatedTo))
+	}
+	if filter.Tag != "" {
+		where = append(where, "tags @> "+arg(pq.Array([]string{filter.Tag})))
+	}
+	if filter.Owner != "" {
+		where = append(where, "owner = "+arg(filter.Owner))
+	}
+
+	cmp, order := "<", "DESC"
+	if filter.Asc {
+		cmp, order = ">", "ASC"
+	}
+	if filter.After != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(filter.After.CreatedAt), arg(filter.After.ID)))
+	}
+
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY created_at ` + order + `, id ` + order + `
+		LIMIT ` + arg(filter.Limit+1)
+	return query, args
+}
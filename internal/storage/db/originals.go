@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"github.com/lib/pq"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+// ExpiredOriginals возвращает изображения в статусах statuses, исходники которых ещё не удалены;
+// createdBefore, если задан, ограничивает выборку загруженными раньше этого момента
+func (s *Postgres) ExpiredOriginals(statuses []domain.StatusType, createdBefore *time.Time, limit int) ([]domain.Image, error) {
+	ctx := context.Background()
+	st := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		st = append(st, string(status))
+	}
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE original_deleted_at IS NULL
+			AND status = ANY($1)
+			AND ($2::timestamptz IS NULL OR created_at < $2)
+		ORDER BY created_at
+		LIMIT $3
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, pq.Array(st), createdBefore, limit)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute expired originals query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	images := make([]domain.Image, 0, limit)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan image row")
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+	return images, rows.Err()
+}
+
+func (s *Postgres) MarkOriginalDeleted(id string) error {
+	ctx := context.Background()
+	query := `
+		UPDATE images
+		SET original_deleted_at = now()
+		WHERE id = $1 AND original_deleted_at IS NULL
+	`
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute mark original deleted query")
+		return err
+	}
+	return nil
+}
+
+// OriginalInUse сообщает, ссылается ли на исходник key другое изображение (кроме exceptID), исходник которого
+// не удалён: при адресации по содержимому один файл может принадлежать нескольким загрузкам
+func (s *Postgres) OriginalInUse(key string, exceptID string) (bool, error) {
+	ctx := context.Background()
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM images WHERE source_key = $1 AND id != $2 AND original_deleted_at IS NULL
+		)
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, key, exceptID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute original in use query")
+		return false, err
+	}
+	var inUse bool
+	if err := row.Scan(&inUse); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute original in use query (scan)")
+		return false, err
+	}
+	return inUse, nil
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	wbdb "github.com/wb-go/wbf/dbpg"
 	wbretry "github.com/wb-go/wbf/retry"
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
+	"time"
 )
 
 type Postgres struct {
 	db  *wbdb.DB
 	cfg *config.RetrysConfig
+	// blobLocks ограничивает число блокировок объектов, удерживаемых репликой, см. LockBlob
+	blobLocks chan struct{}
 }
 
 func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
@@ -48,7 +53,7 @@ func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
 		return nil, err
 	}
 	wbzlog.Logger.Info().Msg("Connected to Postgres")
-	return &Postgres{db: db, cfg: &cfg.RetrysConfig}, nil
+	return &Postgres{db: db, cfg: &cfg.RetrysConfig, blobLocks: blobLockSlots(cfg.DBConfig.MaxOpenConns)}, nil
 }
 
 func (p *Postgres) Close() error {
@@ -69,13 +74,44 @@ func (p *Postgres) Close() error {
 	return nil
 }
 
-func (s *Postgres) SaveImage(img *domain.Image) error {
+// SaveImage сохраняет новое изображение, если оно укладывается в квоты limits. Строка tenant_usage арендатора
+// блокируется до конца транзакции, поэтому параллельные загрузки проверяют квоту по очереди и не превышают её
+func (s *Postgres) SaveImage(img *domain.Image, limits domain.QuotaLimits) error {
 	ctx := context.Background()
+	tx, err := s.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to begin save image transaction")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	usage := domain.Usage{Tenant: img.TenantID}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tenant_usage AS u (tenant) VALUES ($1)
+		ON CONFLICT (tenant) DO UPDATE SET updated_at = now()
+		RETURNING u.images, u.original_bytes, u.processed_bytes
+	`, img.TenantID).Scan(&usage.Images, &usage.OriginalBytes, &usage.ProcessedBytes)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute lock tenant usage query")
+		return err
+	}
+
+	var source domain.FileInfo
+	if img.Source != nil {
+		source = *img.Source
+	}
+	if err := limits.Check(usage, source.Size); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO images (id, created_at, status, format, name, watermark, resize_height, resize_width)
-		VALUES($1, $2, 'created', $3, $4, $5, $6, $7)
+		INSERT INTO images (id, created_at, status, format, name, watermark, resize_height, resize_width, mini, callback_url, priority, batch_id, tags, owner,
+			source_width, source_height, source_size, source_sha256, source_key, tenant_id)
+		VALUES($1, $2, 'created', $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, NULLIF($13, ''), $14, $15, $16, NULLIF($17, ''), NULLIF($18, ''), $19)
 	`
-	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query,
+	_, err = tx.ExecContext(ctx, query,
 		img.ID,
 		img.CreatedAt,
 		img.Format,
@@ -83,113 +119,152 @@ func (s *Postgres) SaveImage(img *domain.Image) error {
 		img.Watermark,
 		img.Resize.Height,
 		img.Resize.Width,
+		img.Mini,
+		img.CallbackURL,
+		img.Priority,
+		img.BatchID,
+		pq.Array(append([]string{}, img.Tags...)), // nil дал бы NULL в NOT NULL колонке
+		img.Owner,
+		nullInt(int64(source.Width)),
+		nullInt(int64(source.Height)),
+		nullInt(source.Size),
+		source.SHA256,
+		source.Key,
+		img.TenantID,
 	)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert comment query")
 		return err
 	}
+	if err := tx.Commit(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit save image transaction")
+		return err
+	}
 	return nil
 }
 
-func (s *Postgres) GetImage(id string) (*domain.Image, error) {
+// GetImage возвращает изображение арендатора; чужое изображение неотличимо от несуществующего
+func (s *Postgres) GetImage(tenant, id string) (*domain.Image, error) {
 	ctx := context.Background()
 	query := `
-		SELECT id, created_at, status, format, name, watermark, resize_height, resize_width
+		SELECT ` + imageColumns + `
 		FROM images
-		WHERE id = $1 AND status != 'deleted'
+		WHERE id = $1 AND tenant_id = $2 AND status != 'deleted'
 	`
-	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id)
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id, tenant)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image query")
 		return nil, err
 	}
-	var img domain.Image
-	var resizeHeight sql.NullInt64
-	var resizeWidth sql.NullInt64
-	err = row.Scan(
-		&img.ID,
-		&img.CreatedAt,
-		&img.Status,
-		&img.Format,
-		&img.Name,
-		&img.Watermark,
-		&resizeHeight,
-		&resizeWidth,
-	)
+	img, err := scanImage(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("image not found")
+			return nil, domain.ErrImageNotFound
 		}
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image query (scan)")
 		return nil, err
 	}
-	if resizeHeight.Valid && resizeWidth.Valid {
-		img.Resize = &domain.Resize{
-			Width:  int(resizeWidth.Int64),
-			Height: int(resizeHeight.Int64),
-		}
-	} else {
-		img.Resize = &domain.Resize{
-			Width:  0,
-			Height: 0,
-		}
-	}
-	return &img, nil
+	return img, nil
 }
 
-func (s *Postgres) DeleteImage(id string) error {
-	ctx := context.Background()
-	query := `
-		UPDATE images
-		SET status = 'deleted'
-		WHERE id = $1
-	`
-	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id)
-	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete image query")
-		return err
+// DeleteImage помещает изображение в корзину, запоминая статус для восстановления
+func (s *Postgres) DeleteImage(tenant, id string) error {
+	return s.setStatus(tenant, id, domain.Deleted, assignment{"deleted_at", time.Now()}, assignment{"deleted_from", sqlExpr("status")})
+}
+
+// SetProcessing сбрасывает причину ошибки прошлой попытки
+func (s *Postgres) SetProcessing(tenant, id string) error {
+	return s.setStatus(tenant, id, domain.Processing, assignment{"error_reason", nil})
+}
+
+func (s *Postgres) SetProcessed(tenant, id string, output *domain.FileInfo) error {
+	var out domain.FileInfo
+	if output != nil {
+		out = *output
 	}
-	return nil
+	return s.setStatus(tenant, id, domain.Processed,
+		assignment{"output_width", nullInt(int64(out.Width))},
+		assignment{"output_height", nullInt(int64(out.Height))},
+		assignment{"output_size", nullInt(out.Size)},
+		assignment{"output_sha256", sql.NullString{String: out.SHA256, Valid: out.SHA256 != ""}},
+		assignment{"output_key", sql.NullString{String: out.Key, Valid: out.Key != ""}},
+		assignment{"processed_at", time.Now()},
+	)
+}
+
+func (s *Postgres) SetFailed(tenant, id string, reason string) error {
+	return s.setStatus(tenant, id, domain.Failed, assignment{"error_reason", sql.NullString{String: reason, Valid: reason != ""}})
+}
 
+func (s *Postgres) SetCancelled(tenant, id string) error {
+	return s.setStatus(tenant, id, domain.Cancelled)
 }
 
-func (s *Postgres) SetProcessing(id string) error {
+// assignment — дополнительная колонка, обновляемая вместе со статусом
+type assignment struct {
+	column string
+	value  any
+}
+
+// sqlExpr — значение assignment, подставляемое в запрос как SQL-выражение над старыми значениями строки
+type sqlExpr string
+
+// setStatus меняет статус изображения арендатора только если переход допустим из текущего состояния,
+// иначе возвращает domain.ErrImageNotFound или *domain.TransitionError
+func (s *Postgres) setStatus(tenant, id string, next domain.StatusType, extra ...assignment) error {
 	ctx := context.Background()
+	from := make([]string, 0)
+	for _, st := range domain.AllowedFrom(next) {
+		from = append(from, string(st))
+	}
+	args := []any{id, next, pq.Array(from), tenant}
+	set := "status = $2, updated_at = now()"
+	for _, a := range extra {
+		if expr, ok := a.value.(sqlExpr); ok {
+			set += fmt.Sprintf(", %s = %s", a.column, expr)
+			continue
+		}
+		args = append(args, a.value)
+		set += fmt.Sprintf(", %s = $%d", a.column, len(args))
+	}
 	query := `
 		UPDATE images
-		SET status = 'processing'
-		WHERE id = $1
+		SET ` + set + `
+		WHERE id = $1 AND tenant_id = $4 AND status = ANY($3)
 	`
-	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id)
+	res, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, args...)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to execute set processing image query")
+		wbzlog.Logger.Error().Err(err).Msgf("Failed to execute set %s image query", next)
 		return err
 	}
-	return nil
-}
-
-func (s *Postgres) SetProcessed(id string) error {
-	ctx := context.Background()
-	query := `
-		UPDATE images
-		SET status = 'processed'
-		WHERE id = $1
-	`
-	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id)
+	affected, err := res.RowsAffected()
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to execute set processed image query")
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get affected rows")
 		return err
 	}
-	return nil
+	if affected > 0 {
+		return nil
+	}
 
+	var current domain.StatusType
+	err = s.db.Master.QueryRowContext(ctx, `SELECT status FROM images WHERE id = $1 AND tenant_id = $2`, id, tenant).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ErrImageNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image status query")
+		return err
+	}
+	return &domain.TransitionError{From: current, To: next}
 }
 
 func (s *Postgres) UploadInProducer() ([]domain.Image, error) {
 	ctx := context.Background()
 	query := `
-		SELECT id, created_at, status, format, name, watermark, resize_height, resize_width
+		SELECT ` + imageColumns + `
 		FROM images
 		WHERE status = 'created'
+		ORDER BY priority = 'bulk', created_at
 	`
 	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query)
 	if err != nil {
@@ -204,35 +279,112 @@ func (s *Postgres) UploadInProducer() ([]domain.Image, error) {
 	}()
 	var images []domain.Image
 	for rows.Next() {
-		var img domain.Image
-		var resizeHeight sql.NullInt64
-		var resizeWidth sql.NullInt64
-		err := rows.Scan(
-			&img.ID,
-			&img.CreatedAt,
-			&img.Status,
-			&img.Format,
-			&img.Name,
-			&img.Watermark,
-			&resizeHeight,
-			&resizeWidth,
-		)
+		img, err := scanImage(rows)
 		if err != nil {
 			wbzlog.Logger.Error().Err(err).Msg("Failed to scan image row")
 			return nil, err
 		}
-		if resizeHeight.Valid && resizeWidth.Valid {
-			img.Resize = &domain.Resize{
-				Width:  int(resizeWidth.Int64),
-				Height: int(resizeHeight.Int64),
-			}
-		} else {
-			img.Resize = &domain.Resize{
-				Width:  0,
-				Height: 0,
-			}
-		}
-		images = append(images, img)
+		images = append(images, *img)
 	}
 	return images, nil
 }
+
+// imageColumns — порядок колонок, который ожидает scanImage
+const imageColumns = `id, tenant_id, created_at, status, format, name, watermark, resize_height, resize_width, mini,
+		COALESCE(callback_url, ''), priority, batch_id, tags, COALESCE(owner, ''),
+		source_width, source_height, source_size, COALESCE(source_sha256, ''), COALESCE(source_key, ''),
+		output_width, output_height, output_size, COALESCE(output_sha256, ''), COALESCE(output_key, ''),
+		COALESCE(error_reason, ''), updated_at, processed_at, original_deleted_at, deleted_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImage(row rowScanner) (*domain.Image, error) {
+	var img domain.Image
+	var resizeHeight sql.NullInt64
+	var resizeWidth sql.NullInt64
+	var batchID uuid.NullUUID
+	var source, output [3]sql.NullInt64
+	var sourceSHA256, sourceKey, outputSHA256, outputKey string
+	var updatedAt, processedAt, originalDeletedAt, deletedAt sql.NullTime
+	err := row.Scan(
+		&img.ID,
+		&img.TenantID,
+		&img.CreatedAt,
+		&img.Status,
+		&img.Format,
+		&img.Name,
+		&img.Watermark,
+		&resizeHeight,
+		&resizeWidth,
+		&img.Mini,
+		&img.CallbackURL,
+		&img.Priority,
+		&batchID,
+		pq.Array(&img.Tags),
+		&img.Owner,
+		&source[0], &source[1], &source[2], &sourceSHA256, &sourceKey,
+		&output[0], &output[1], &output[2], &outputSHA256, &outputKey,
+		&img.ErrorReason,
+		&updatedAt,
+		&processedAt,
+		&originalDeletedAt,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if resizeHeight.Valid && resizeWidth.Valid {
+		img.Resize = &domain.Resize{
+			Width:  int(resizeWidth.Int64),
+			Height: int(resizeHeight.Int64),
+		}
+	} else {
+		img.Resize = &domain.Resize{
+			Width:  0,
+			Height: 0,
+		}
+	}
+	if batchID.Valid {
+		img.BatchID = &batchID.UUID
+	}
+	img.Source = fileInfo(source)
+	if img.Source != nil {
+		img.Source.SHA256, img.Source.Key = sourceSHA256, sourceKey
+	}
+	img.Output = fileInfo(output)
+	if img.Output != nil {
+		img.Output.SHA256, img.Output.Key = outputSHA256, outputKey
+	}
+	if updatedAt.Valid {
+		img.UpdatedAt = &updatedAt.Time
+	}
+	if processedAt.Valid {
+		img.ProcessedAt = &processedAt.Time
+	}
+	if originalDeletedAt.Valid {
+		img.OriginalDeletedAt = &originalDeletedAt.Time
+	}
+	if deletedAt.Valid {
+		img.DeletedAt = &deletedAt.Time
+	}
+	return &img, nil
+}
+
+// fileInfo собирает размеры из колонок (width, height, size); до заполнения колонок — nil
+func fileInfo(cols [3]sql.NullInt64) *domain.FileInfo {
+	if !cols[0].Valid || !cols[1].Valid {
+		return nil
+	}
+	return &domain.FileInfo{
+		Width:  int(cols[0].Int64),
+		Height: int(cols[1].Int64),
+		Size:   cols[2].Int64,
+	}
+}
+
+// nullInt пишет NULL вместо нуля для ещё неизвестных размеров
+func nullInt(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v > 0}
+}
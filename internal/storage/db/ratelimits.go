@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// TakeRateToken пополняет и забирает токен bucket'а одним запросом, поэтому реплики не выдают один токен дважды.
+// Время берётся из БД, чтобы расхождение часов реплик не влияло на пополнение. Запрос не повторяется:
+// повтор после неизвестного исхода мог бы списать токен дважды
+func (s *Postgres) TakeRateToken(key string, burst, rate float64) (float64, bool, error) {
+	ctx := context.Background()
+	query := `
+		INSERT INTO rate_limits AS r (key, tokens, allowed, updated_at)
+		VALUES ($1, $2 - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST($2, r.tokens + GREATEST(EXTRACT(EPOCH FROM now() - r.updated_at), 0) * $3)
+				- CASE WHEN LEAST($2, r.tokens + GREATEST(EXTRACT(EPOCH FROM now() - r.updated_at), 0) * $3) >= 1 THEN 1 ELSE 0 END,
+			allowed = LEAST($2, r.tokens + GREATEST(EXTRACT(EPOCH FROM now() - r.updated_at), 0) * $3) >= 1,
+			updated_at = now()
+		RETURNING tokens, allowed
+	`
+	var tokens float64
+	var allowed bool
+	if err := s.db.Master.QueryRowContext(ctx, query, key, burst, rate).Scan(&tokens, &allowed); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute take rate token query")
+		return 0, false, err
+	}
+	return tokens, allowed, nil
+}
+
+// SweepRateLimits удаляет bucket'ы, не тронутые дольше idle
+func (s *Postgres) SweepRateLimits(idle time.Duration) (int, error) {
+	ctx := context.Background()
+	res, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs},
+		`DELETE FROM rate_limits WHERE updated_at < now() - make_interval(secs => $1)`, idle.Seconds())
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute sweep rate limits query")
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+// GetImageWithDeleted возвращает изображение арендатора в любом статусе, в том числе из корзины
+func (s *Postgres) GetImageWithDeleted(tenant, id string) (*domain.Image, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE id = $1 AND tenant_id = $2
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, id, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image with deleted query")
+		return nil, err
+	}
+	img, err := scanImage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrImageNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image with deleted query (scan)")
+		return nil, err
+	}
+	return img, nil
+}
+
+// RestoreImage возвращает изображение из корзины, если оно удалено позже deletedAfter, в статус
+// domain.RestoredStatus. Иначе — domain.ErrImageNotFound, domain.ErrNotInTrash или domain.ErrTrashExpired
+func (s *Postgres) RestoreImage(tenant, id string, deletedAfter time.Time) (*domain.Image, error) {
+	ctx := context.Background()
+	query := `
+		UPDATE images
+		SET status = CASE WHEN deleted_from IN ('processed', 'failed', 'cancelled') THEN deleted_from ELSE 'created' END,
+			deleted_at = NULL, deleted_from = NULL, updated_at = now()
+		WHERE id = $1 AND tenant_id = $3 AND status = 'deleted' AND deleted_at > $2
+		RETURNING ` + imageColumns
+	img, err := scanImage(s.db.Master.QueryRowContext(ctx, query, id, deletedAfter, tenant))
+	if err == nil {
+		return img, nil
+	}
+	if err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute restore image query")
+		return nil, err
+	}
+
+	var status domain.StatusType
+	err = s.db.Master.QueryRowContext(ctx, `SELECT status FROM images WHERE id = $1 AND tenant_id = $2`, id, tenant).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrImageNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get image status query")
+		return nil, err
+	}
+	if status != domain.Deleted {
+		return nil, domain.ErrNotInTrash
+	}
+	return nil, domain.ErrTrashExpired
+}
+
+// ExpiredTrash возвращает изображения, пролежавшие в корзине дольше срока (удалённые до deletedBefore)
+func (s *Postgres) ExpiredTrash(deletedBefore time.Time, limit int) ([]domain.Image, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE status = 'deleted' AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, deletedBefore, limit)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute expired trash query")
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	images := make([]domain.Image, 0, limit)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan image row")
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+	return images, rows.Err()
+}
+
+// PurgeImage удаляет запись изображения арендатора вместе с журналом доставки webhook
+func (s *Postgres) PurgeImage(tenant, id string) error {
+	ctx := context.Background()
+	res, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs},
+		`DELETE FROM images WHERE id = $1 AND tenant_id = $2`, id, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute purge image query")
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get affected rows")
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrImageNotFound
+	}
+	return nil
+}
+
+// OutputInUse сообщает, ссылается ли на результат key другое изображение, кроме exceptID
+func (s *Postgres) OutputInUse(key string, exceptID string) (bool, error) {
+	ctx := context.Background()
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM images WHERE output_key = $1 AND id != $2
+		)
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, key, exceptID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute output in use query")
+		return false, err
+	}
+	var inUse bool
+	if err := row.Scan(&inUse); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute output in use query (scan)")
+		return false, err
+	}
+	return inUse, nil
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+)
+
+// GetUsage возвращает счётчики арендатора из tenant_usage; у арендатора без изображений — нулевые
+func (s *Postgres) GetUsage(tenant string) (*domain.Usage, error) {
+	ctx := context.Background()
+	query := `
+		SELECT images, original_bytes, processed_bytes
+		FROM tenant_usage
+		WHERE tenant = $1
+	`
+	row, err := s.db.QueryRowWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, tenant)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get usage query")
+		return nil, err
+	}
+	usage := domain.Usage{Tenant: tenant}
+	if err := row.Scan(&usage.Images, &usage.OriginalBytes, &usage.ProcessedBytes); err != nil && err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get usage query (scan)")
+		return nil, err
+	}
+	return &usage, nil
+}
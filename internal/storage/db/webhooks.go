@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	wbretry "github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"time"
+)
+
+const webhookColumns = `id, image_id, url, event, payload, status, attempts, next_attempt_at,
+		COALESCE(last_status_code, 0), COALESCE(last_error, ''), created_at, updated_at`
+
+func (s *Postgres) SaveWebhookDelivery(d *domain.WebhookDelivery) error {
+	ctx := context.Background()
+	query := `
+		INSERT INTO webhook_deliveries (id, image_id, url, event, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query,
+		d.ID,
+		d.ImageID,
+		d.URL,
+		d.Event,
+		[]byte(d.Payload),
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.CreatedAt,
+		d.UpdatedAt,
+	)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert webhook delivery query")
+		return err
+	}
+	return nil
+}
+
+func (s *Postgres) GetWebhookDeliveries(imageID string) ([]domain.WebhookDelivery, error) {
+	ctx := context.Background()
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhook_deliveries
+		WHERE image_id = $1
+		ORDER BY created_at
+	`
+	rows, err := s.db.QueryWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query, imageID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get webhook deliveries query")
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+// ClaimWebhookDeliveries забирает готовые к отправке доставки и сдвигает их next_attempt_at на lease,
+// чтобы другие реплики не отправили их повторно, пока идёт текущая попытка
+func (s *Postgres) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	ctx := context.Background()
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookColumns
+	rows, err := s.db.Master.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute claim webhook deliveries query")
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func (s *Postgres) UpdateWebhookDelivery(d *domain.WebhookDelivery) error {
+	ctx := context.Background()
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = NULLIF($5, 0), last_error = NULLIF($6, ''), updated_at = $7
+		WHERE id = $1
+	`
+	_, err := s.db.ExecWithRetry(ctx, wbretry.Strategy{Attempts: s.cfg.Attempts, Delay: s.cfg.Delay, Backoff: s.cfg.Backoffs}, query,
+		d.ID,
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastStatusCode,
+		d.LastError,
+		d.UpdatedAt,
+	)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update webhook delivery query")
+		return err
+	}
+	return nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]domain.WebhookDelivery, error) {
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(
+			&d.ID,
+			&d.ImageID,
+			&d.URL,
+			&d.Event,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastStatusCode,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan webhook delivery row")
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,368 @@
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadLocked   = errors.New("upload is locked by another request")
+)
+
+// tusFileExts — файлы загрузки в каталоге tus/; .tmp остаётся, если процесс упал во время saveInfo
+var tusFileExts = []string{".part", ".info", ".info.tmp"}
+
+// Upload — состояние возобновляемой загрузки; Offset не хранится, а берётся из размера .part файла,
+// поэтому после обрыва соединения он совпадает с реально записанными байтами
+type Upload struct {
+	ID        string            `json:"id"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata"`
+	ImageID   string            `json:"image_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	// ExpiresAt — когда загрузка будет удалена, если к ней не обращаться; пустое значение — без срока
+	ExpiresAt time.Time `json:"-"`
+}
+
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// Store хранит незавершённые загрузки в подкаталоге tus/ каталога оригиналов:
+// <id>.part — полученные байты, <id>.info — длина и метаданные.
+// Загрузка истекает через tus.expiration после последнего полученного куска (завершённая — после завершения)
+type Store struct {
+	dir           string
+	expiration    time.Duration
+	sweepInterval time.Duration
+
+	mu   sync.Mutex
+	busy map[string]struct{}
+}
+
+func NewStore(cfg *config.AppConfig) *Store {
+	return &Store{
+		dir:           filepath.Join(cfg.StoragePathConfig.InputDir, "tus"),
+		expiration:    cfg.TusConfig.Expiration,
+		sweepInterval: cfg.TusConfig.SweepInterval,
+		busy:          make(map[string]struct{}),
+	}
+}
+
+// Create начинает загрузку арендатора tenant; остальные методы работают по ID, принадлежность проверяет вызывающий
+func (s *Store) Create(tenant string, length int64, metadata map[string]string) (*Upload, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to create tus directory")
+		return nil, err
+	}
+	u := &Upload{
+		ID:        uuid.New().String(),
+		Tenant:    tenant,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	u.ExpiresAt = s.expiresAt(u.CreatedAt)
+	part, err := os.Create(s.partPath(u.ID))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to create tus part file")
+		return nil, err
+	}
+	_ = part.Close()
+	if err := s.saveInfo(u); err != nil {
+		_ = os.Remove(s.partPath(u.ID))
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Store) Get(id string) (*Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to read tus info file")
+		return nil, err
+	}
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to decode tus info file")
+		return nil, err
+	}
+	// part уже передан в обработку и удалён, срок отсчитывается от записи info
+	path := s.infoPath(id)
+	if u.ImageID == "" {
+		path = s.partPath(id)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	u.Offset = u.Length
+	if u.ImageID == "" {
+		u.Offset = st.Size()
+	}
+	u.ExpiresAt = s.expiresAt(st.ModTime())
+	// истёкшая загрузка недоступна, даже если сборщик ещё не удалил её файлы
+	if !u.ExpiresAt.IsZero() && !time.Now().Before(u.ExpiresAt) {
+		return nil, ErrUploadNotFound
+	}
+	return &u, nil
+}
+
+// Lock не даёт двум запросам одновременно дописывать и завершать одну загрузку
+func (s *Store) Lock(id string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.busy[id]; ok {
+		return nil, ErrUploadLocked
+	}
+	s.busy[id] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		delete(s.busy, id)
+		s.mu.Unlock()
+	}, nil
+}
+
+// Write дописывает данные с offset; байты сверх Upload-Length не принимаются.
+// При обрыве чтения уже записанная часть сохраняется и возвращается вместе с ошибкой.
+func (s *Store) Write(id string, offset int64, r io.Reader) (*Upload, error) {
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Offset {
+		return u, ErrOffsetMismatch
+	}
+	if u.Complete() {
+		return u, nil
+	}
+	part, err := os.OpenFile(s.partPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to open tus part file")
+		return nil, err
+	}
+	n, copyErr := io.Copy(part, io.LimitReader(r, u.Length-u.Offset))
+	u.Offset += n
+	u.ExpiresAt = s.expiresAt(time.Now())
+	if err := part.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	return u, copyErr
+}
+
+func (s *Store) Open(id string) (*os.File, error) {
+	return os.Open(s.partPath(id))
+}
+
+// Finish запоминает созданное изображение и удаляет part; info остаётся,
+// чтобы клиент мог узнать ID изображения через HEAD
+func (s *Store) Finish(u *Upload, imageID string) error {
+	u.ImageID = imageID
+	if err := s.saveInfo(u); err != nil {
+		return err
+	}
+	if err := os.Remove(s.partPath(u.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to remove tus part file")
+	}
+	return nil
+}
+
+func (s *Store) Terminate(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	return s.remove(id)
+}
+
+// Sweep удаляет загрузки, к которым не обращались дольше tus.expiration, и возвращает их количество.
+// Загрузки, в которые сейчас пишут, пропускаются
+func (s *Store) Sweep() (int, error) {
+	if s.expiration <= 0 {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to read tus directory")
+		return 0, err
+	}
+	// последнее изменение любого файла загрузки
+	active := make(map[string]time.Time)
+	for _, entry := range entries {
+		id, ok := uploadID(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(active[id]) {
+			active[id] = info.ModTime()
+		}
+	}
+
+	before := time.Now().Add(-s.expiration)
+	removed := 0
+	for id, modTime := range active {
+		if modTime.After(before) {
+			continue
+		}
+		unlock, err := s.Lock(id)
+		if err != nil {
+			continue
+		}
+		err = s.remove(id)
+		unlock()
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Run периодически удаляет истёкшие загрузки до отмены ctx
+func (s *Store) Run(ctx context.Context) {
+	if s.sweepInterval <= 0 || s.expiration <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Tus sweeper stopping...")
+			return
+		case <-ticker.C:
+		}
+		removed, err := s.Sweep()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to sweep expired tus uploads")
+			continue
+		}
+		wbzlog.Logger.Debug().Int("removed", removed).Msg("Expired tus uploads swept")
+	}
+}
+
+func (s *Store) remove(id string) error {
+	for _, ext := range tusFileExts {
+		if err := os.Remove(filepath.Join(s.dir, id+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to remove tus upload file")
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) expiresAt(active time.Time) time.Time {
+	if s.expiration <= 0 {
+		return time.Time{}
+	}
+	return active.Add(s.expiration)
+}
+
+// uploadID возвращает ID загрузки по имени её файла; посторонние файлы не трогаются
+func uploadID(name string) (string, bool) {
+	for _, ext := range tusFileExts {
+		id, ok := strings.CutSuffix(name, ext)
+		if !ok {
+			continue
+		}
+		if _, err := uuid.Parse(id); err == nil {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func (s *Store) saveInfo(u *Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	// запись через временный файл, чтобы не оставить обрезанный info при падении
+	tmp := s.infoPath(u.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to write tus info file")
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(u.ID))
+}
+
+func (s *Store) partPath(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// ParseMetadata разбирает заголовок Upload-Metadata: пары "ключ base64(значение)" через запятую,
+// значение может отсутствовать
+func ParseMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			meta[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.New("invalid Upload-Metadata value for key " + fields[0])
+			}
+			meta[fields[0]] = string(value)
+		default:
+			return nil, errors.New("invalid Upload-Metadata pair: " + strings.TrimSpace(pair))
+		}
+	}
+	return meta, nil
+}
+
+func EncodeMetadata(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if meta[k] == "" {
+			pairs = append(pairs, k)
+			continue
+		}
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(meta[k])))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,153 @@
+package tus
+
+import (
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestConfig(t *testing.T) *config.AppConfig {
+	t.Helper()
+	return &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/"},
+	}
+}
+
+func TestStore_ResumeAfterPartialWrite(t *testing.T) {
+	cfg := newTestConfig(t)
+	s := NewStore(cfg)
+	u, err := s.Create("", 10, map[string]string{"filename": "a.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), u.Offset)
+
+	u, err = s.Write(u.ID, 0, strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), u.Offset)
+
+	// состояние читается с диска, как после перезапуска
+	u, err = NewStore(cfg).Get(u.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), u.Offset)
+	assert.Equal(t, "a.png", u.Metadata["filename"])
+
+	_, err = s.Write(u.ID, 0, strings.NewReader("again"))
+	assert.ErrorIs(t, err, ErrOffsetMismatch)
+
+	// лишние байты сверх Upload-Length не принимаются
+	u, err = s.Write(u.ID, 5, strings.NewReader("world!!!"))
+	assert.NoError(t, err)
+	assert.True(t, u.Complete())
+
+	f, err := s.Open(u.ID)
+	assert.NoError(t, err)
+	data := make([]byte, 20)
+	n, _ := f.Read(data)
+	_ = f.Close()
+	assert.Equal(t, "helloworld", string(data[:n]))
+
+	assert.NoError(t, s.Finish(u, "image-id"))
+	assert.NoFileExists(t, s.partPath(u.ID))
+	u, err = s.Get(u.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "image-id", u.ImageID)
+	assert.True(t, u.Complete())
+}
+
+func TestStore_NotFoundAndTerminate(t *testing.T) {
+	s := NewStore(newTestConfig(t))
+	_, err := s.Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	u, err := s.Create("", 3, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Terminate(u.ID))
+	_, err = os.Stat(s.infoPath(u.ID))
+	assert.True(t, os.IsNotExist(err))
+	_, err = s.Get(u.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestStore_Expiration(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.TusConfig.Expiration = time.Hour
+	s := NewStore(cfg)
+	stale := time.Now().Add(-2 * time.Hour)
+	age := func(id string) {
+		for _, path := range []string{s.partPath(id), s.infoPath(id)} {
+			_ = os.Chtimes(path, stale, stale)
+		}
+	}
+
+	active, err := s.Create("", 10, nil)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), active.ExpiresAt, time.Minute)
+	abandoned, err := s.Create("", 10, nil)
+	assert.NoError(t, err)
+	age(abandoned.ID)
+	finished, err := s.Create("", 1, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Finish(finished, "image-id"))
+	age(finished.ID)
+	busy, err := s.Create("", 10, nil)
+	assert.NoError(t, err)
+	age(busy.ID)
+	unlock, err := s.Lock(busy.ID)
+	assert.NoError(t, err)
+	// info, оставшийся от упавшей записи, и посторонние файлы
+	assert.NoError(t, os.WriteFile(s.infoPath(abandoned.ID)+".tmp", nil, 0644))
+	_ = os.Chtimes(s.infoPath(abandoned.ID)+".tmp", stale, stale)
+	foreign := filepath.Join(s.dir, "notes.txt")
+	assert.NoError(t, os.WriteFile(foreign, nil, 0644))
+	_ = os.Chtimes(foreign, stale, stale)
+
+	// истёкшая загрузка недоступна ещё до очистки
+	_, err = s.Get(abandoned.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	removed, err := s.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	for _, id := range []string{abandoned.ID, finished.ID} {
+		assert.NoFileExists(t, s.partPath(id))
+		assert.NoFileExists(t, s.infoPath(id))
+	}
+	assert.NoFileExists(t, s.infoPath(abandoned.ID)+".tmp")
+	assert.FileExists(t, foreign)
+	_, err = s.Get(active.ID)
+	assert.NoError(t, err)
+
+	// загрузку, в которую сейчас пишут, очистка не трогает
+	assert.FileExists(t, s.partPath(busy.ID))
+	unlock()
+	removed, err = s.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
+
+func TestStore_Lock(t *testing.T) {
+	s := NewStore(newTestConfig(t))
+	unlock, err := s.Lock("id")
+	assert.NoError(t, err)
+	_, err = s.Lock("id")
+	assert.ErrorIs(t, err, ErrUploadLocked)
+	unlock()
+	unlock, err = s.Lock("id")
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestMetadata(t *testing.T) {
+	meta, err := ParseMetadata("filename YS5wbmc=, mini,resize MTB4MTA=")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "a.png", "mini": "", "resize": "10x10"}, meta)
+	assert.Equal(t, "filename YS5wbmc=,mini,resize MTB4MTA=", EncodeMetadata(meta))
+
+	_, err = ParseMetadata("filename !!!")
+	assert.Error(t, err)
+	_, err = ParseMetadata("a b c")
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"imageProcessor/internal/config"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSigningDisabled — не задан ни один ключ подписи
+	ErrSigningDisabled  = errors.New("url signing is not configured")
+	ErrInvalidSignature = errors.New("invalid url signature")
+	ErrExpired          = errors.New("signed url has expired")
+)
+
+// параметры подписанной ссылки
+const (
+	paramTenant  = "tenant"
+	paramVariant = "variant"
+	paramExpires = "expires"
+	paramBind    = "bind"
+	paramKeyID   = "kid"
+	paramSig     = "sig"
+
+	// bindIP — ссылка действительна только для адреса, для которого выпущена; сам адрес в ссылку не попадает
+	bindIP = "ip"
+	// version меняется вместе с форматом подписываемой строки, чтобы старые подписи не совпали с новыми
+	version = "v1"
+	// ticketPath — ресурс билета на потоки статусов; ни с одним путём изображения не совпадает,
+	// поэтому подпись билета не подходит к ссылке и наоборот
+	ticketPath = "/api/streams"
+)
+
+// Link — что разрешает подписанная ссылка: чтение изображения ImageID арендатора Tenant до Expires.
+// Непустые Variant и IP ограничивают ссылку вариантом изображения и адресом клиента
+type Link struct {
+	ImageID string
+	Tenant  string
+	Variant string
+	IP      string
+	Expires time.Time
+}
+
+// Signer подписывает ссылки HMAC-SHA256 первым ключом из signed_urls и проверяет подпись любым из них
+type Signer struct {
+	keys []config.SigningKey
+	now  func() time.Time
+}
+
+func NewSigner(cfg *config.AppConfig) *Signer {
+	return &Signer{
+		keys: cfg.SignedURLConfig.Keys,
+		now:  time.Now,
+	}
+}
+
+// Sign возвращает параметры запроса, которые нужно добавить к /api/image/{id}
+func (s *Signer) Sign(link Link) (url.Values, error) {
+	if len(s.keys) == 0 {
+		return nil, ErrSigningDisabled
+	}
+	key := s.keys[0]
+	expires := strconv.FormatInt(link.Expires.Unix(), 10)
+
+	query := url.Values{}
+	if link.Tenant != "" {
+		query.Set(paramTenant, link.Tenant)
+	}
+	if link.Variant != "" {
+		query.Set(paramVariant, link.Variant)
+	}
+	query.Set(paramExpires, expires)
+	if link.IP != "" {
+		query.Set(paramBind, bindIP)
+	}
+	query.Set(paramKeyID, key.ID)
+	query.Set(paramSig, signature(key.Secret, imagePath(link.ImageID), link.Tenant, link.Variant, expires, link.IP))
+	return query, nil
+}
+
+// SignTicket выпускает билет на потоки статусов арендатора tenant, действующий до expires: браузер не может
+// передать Authorization в WebSocket и EventSource, поэтому билет передаётся параметрами запроса
+func (s *Signer) SignTicket(tenant string, expires time.Time) (url.Values, error) {
+	if len(s.keys) == 0 {
+		return nil, ErrSigningDisabled
+	}
+	key := s.keys[0]
+	unix := strconv.FormatInt(expires.Unix(), 10)
+
+	query := url.Values{}
+	if tenant != "" {
+		query.Set(paramTenant, tenant)
+	}
+	query.Set(paramExpires, unix)
+	query.Set(paramKeyID, key.ID)
+	query.Set(paramSig, signature(key.Secret, ticketPath, tenant, "", unix, ""))
+	return query, nil
+}
+
+// VerifyTicket проверяет билет на потоки статусов и возвращает его арендатора
+func (s *Signer) VerifyTicket(query url.Values) (string, error) {
+	key, ok := s.key(query.Get(paramKeyID))
+	if !ok || query.Has(paramVariant) || query.Has(paramBind) {
+		return "", ErrInvalidSignature
+	}
+	expires := query.Get(paramExpires)
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	tenant := query.Get(paramTenant)
+	want := signature(key.Secret, ticketPath, tenant, "", expires, "")
+	if !hmac.Equal([]byte(query.Get(paramSig)), []byte(want)) {
+		return "", ErrInvalidSignature
+	}
+	if !s.now().Before(time.Unix(unix, 0)) {
+		return "", ErrExpired
+	}
+	return tenant, nil
+}
+
+// Signed сообщает, что запрос несёт подпись и его нужно проверить
+func Signed(query url.Values) bool {
+	return query.Has(paramSig)
+}
+
+// Verify проверяет подпись ссылки на изображение imageID, открытой с адреса clientIP
+func (s *Signer) Verify(imageID string, query url.Values, clientIP string) (*Link, error) {
+	key, ok := s.key(query.Get(paramKeyID))
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	expires := query.Get(paramExpires)
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	link := &Link{
+		ImageID: imageID,
+		Tenant:  query.Get(paramTenant),
+		Variant: query.Get(paramVariant),
+		Expires: time.Unix(unix, 0),
+	}
+	switch query.Get(paramBind) {
+	case "":
+	case bindIP:
+		link.IP = clientIP
+	default:
+		return nil, ErrInvalidSignature
+	}
+
+	want := signature(key.Secret, imagePath(link.ImageID), link.Tenant, link.Variant, expires, link.IP)
+	if !hmac.Equal([]byte(query.Get(paramSig)), []byte(want)) {
+		return nil, ErrInvalidSignature
+	}
+	// срок проверяется после подписи, чтобы поддельная ссылка не выдавала себя за просроченную
+	if !s.now().Before(link.Expires) {
+		return nil, ErrExpired
+	}
+	return link, nil
+}
+
+func (s *Signer) key(id string) (config.SigningKey, bool) {
+	for _, key := range s.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return config.SigningKey{}, false
+}
+
+func imagePath(imageID string) string {
+	return "/api/image/" + imageID
+}
+
+// signature подписывает ресурс path и все ограничения ссылки; поля разделены переводом строки, который не встречается
+// ни в идентификаторах, ни в IP, поэтому границы полей нельзя сдвинуть
+func signature(secret []byte, path, tenant, variant, expires, ip string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{version, path, tenant, variant, expires, ip}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
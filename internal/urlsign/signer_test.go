@@ -0,0 +1,176 @@
+package urlsign
+
+import (
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const imageID = "123e4567-e89b-12d3-a456-426614174000"
+
+func newSigner(keys ...config.SigningKey) *Signer {
+	return NewSigner(&config.AppConfig{SignedURLConfig: config.SignedURLConfig{Keys: keys}})
+}
+
+func signingKey(id string) config.SigningKey {
+	return config.SigningKey{ID: id, Secret: []byte("secret-of-key-" + id + "-0123456789abcdef")}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	expires := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	query, err := signer.Sign(Link{ImageID: imageID, Tenant: "acme", Variant: "original", Expires: expires})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", query.Get("tenant"))
+	assert.Equal(t, "original", query.Get("variant"))
+	assert.Equal(t, "k1", query.Get("kid"))
+	assert.False(t, query.Has("bind"))
+	assert.True(t, Signed(query))
+
+	link, err := signer.Verify(imageID, query, "203.0.113.7")
+	assert.NoError(t, err)
+	assert.Equal(t, &Link{ImageID: imageID, Tenant: "acme", Variant: "original", Expires: expires}, link)
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	expires := time.Now().Add(time.Minute)
+	query, err := signer.Sign(Link{ImageID: imageID, Tenant: "acme", Expires: expires})
+	assert.NoError(t, err)
+
+	with := func(name, value string) url.Values {
+		changed, _ := url.ParseQuery(query.Encode())
+		if value == "" {
+			changed.Del(name)
+		} else {
+			changed.Set(name, value)
+		}
+		return changed
+	}
+	cases := map[string]url.Values{
+		"other tenant":     with("tenant", "globex"),
+		"added variant":    with("variant", "original"),
+		"extended expiry":  with("expires", strconv.FormatInt(expires.Add(time.Hour).Unix(), 10)),
+		"unknown key":      with("kid", "k2"),
+		"no key":           with("kid", ""),
+		"forged signature": with("sig", "AAAA"),
+		"bound to ip":      with("bind", "ip"),
+		"unknown binding":  with("bind", "cookie"),
+		"malformed expiry": with("expires", "soon"),
+	}
+	for name, q := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := signer.Verify(imageID, q, "203.0.113.7")
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+
+	_, err = signer.Verify("00000000-0000-0000-0000-000000000000", query, "203.0.113.7")
+	assert.ErrorIs(t, err, ErrInvalidSignature, "signature is bound to the image")
+}
+
+func TestVerify_BoundToIP(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	query, err := signer.Sign(Link{ImageID: imageID, IP: "203.0.113.7", Expires: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, "ip", query.Get("bind"))
+	assert.NotContains(t, query.Encode(), "203.0.113.7")
+
+	link, err := signer.Verify(imageID, query, "203.0.113.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", link.IP)
+
+	_, err = signer.Verify(imageID, query, "198.51.100.1")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// без bind=ip подпись проверяется без адреса и не совпадает
+	query.Del("bind")
+	_, err = signer.Verify(imageID, query, "203.0.113.7")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerify_Expired(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+	query, err := signer.Sign(Link{ImageID: imageID, Expires: now.Add(time.Minute)})
+	assert.NoError(t, err)
+
+	_, err = signer.Verify(imageID, query, "")
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = signer.Verify(imageID, query, "")
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestVerify_KeyRotation(t *testing.T) {
+	old := newSigner(signingKey("2025"))
+	query, err := old.Sign(Link{ImageID: imageID, Expires: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+
+	// новый ключ подписывает, старый ещё проверяет выданные им ссылки
+	rotated := newSigner(signingKey("2026"), signingKey("2025"))
+	_, err = rotated.Verify(imageID, query, "")
+	assert.NoError(t, err)
+	fresh, err := rotated.Sign(Link{ImageID: imageID, Expires: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, "2026", fresh.Get("kid"))
+
+	// старый ключ выведен из набора
+	retired := newSigner(signingKey("2026"))
+	_, err = retired.Verify(imageID, query, "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = retired.Verify(imageID, fresh, "")
+	assert.NoError(t, err)
+}
+
+func TestSign_Disabled(t *testing.T) {
+	signer := newSigner()
+	_, err := signer.Sign(Link{ImageID: imageID, Expires: time.Now().Add(time.Minute)})
+	assert.ErrorIs(t, err, ErrSigningDisabled)
+
+	_, err = signer.Verify(imageID, url.Values{"sig": {"AAAA"}, "kid": {""}, "expires": {"0"}}, "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestTicket(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	query, err := signer.SignTicket("acme", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, Signed(query))
+
+	tenant, err := signer.VerifyTicket(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	forged, _ := url.ParseQuery(query.Encode())
+	forged.Set("tenant", "globex")
+	_, err = signer.VerifyTicket(forged)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	expired, err := signer.SignTicket("acme", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	_, err = signer.VerifyTicket(expired)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestTicket_NotALink(t *testing.T) {
+	signer := newSigner(signingKey("k1"))
+	expires := time.Now().Add(time.Minute)
+
+	// билет не открывает изображение, а ссылка на изображение не открывает потоки
+	ticket, err := signer.SignTicket("acme", expires)
+	assert.NoError(t, err)
+	_, err = signer.Verify(imageID, ticket, "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	link, err := signer.Sign(Link{ImageID: imageID, Tenant: "acme", Expires: expires})
+	assert.NoError(t, err)
+	_, err = signer.VerifyTicket(link)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
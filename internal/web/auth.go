@@ -0,0 +1,101 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"net/http"
+	"strings"
+)
+
+// principalKey — ключ контекста запроса с *domain.Principal, определённым authenticate
+const principalKey = "principal"
+
+type KeyAuthenticator interface {
+	AuthenticateKey(secret string) (*domain.Principal, error)
+}
+
+// authenticate определяет арендатора и права по Authorization: Bearer <token>. Статические токены TENANT_TOKENS
+// дают арендатору право admin, чтобы выпустить первые ключи; остальные токены ищутся среди ключей API.
+// Без TENANT_TOKENS, но с OIDC, принимаются только JWT и ключи API.
+// Без настроенных токенов все запросы относятся к арендатору по умолчанию
+func authenticate(auth config.AuthConfig, keys KeyAuthenticator) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		// OPTIONS описывает возможности сервера и приходит в CORS-префлайте без Authorization;
+		// JWT уже проверен BearerJWT
+		if !auth.Enabled() || ctx.Request.Method == http.MethodOptions || authenticated(ctx) {
+			ctx.Next()
+			return
+		}
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || given == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, wbgin.H{"error": "invalid or missing bearer token"})
+			return
+		}
+		if tenant, found := lookupTenant(auth.Tokens, given); found {
+			ctx.Set(principalKey, &domain.Principal{Tenant: tenant, Scopes: domain.Scopes{domain.ScopeAdmin}})
+			ctx.Next()
+			return
+		}
+		principal, err := keys.AuthenticateKey(given)
+		if err != nil {
+			if errors.Is(err, domain.ErrAPIKeyNotFound) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, wbgin.H{"error": "invalid or missing bearer token"})
+				return
+			}
+			wbzlog.Logger.Error().Err(err).Msg("Failed to authenticate api key")
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+			return
+		}
+		ctx.Set(principalKey, principal)
+		ctx.Next()
+	}
+}
+
+// requireScope пропускает запрос, только если у ключа есть право scope. Без аутентификации права не проверяются
+func requireScope(scope domain.Scope) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		principal := principalOf(ctx)
+		if principal != nil && !principal.Scopes.Allows(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, wbgin.H{"error": "insufficient scope: " + string(scope) + " required"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// lookupTenant сравнивает токен со всеми известными за постоянное время, чтобы время ответа не выдавало совпавший префикс
+func lookupTenant(tokens map[string]string, given string) (string, bool) {
+	var tenant string
+	found := false
+	for token, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			tenant, found = t, true
+		}
+	}
+	return tenant, found
+}
+
+// principalOf возвращает, кто выполняет запрос, или nil без аутентификации
+func principalOf(ctx *wbgin.Context) *domain.Principal {
+	if principal, ok := ctx.Get(principalKey); ok {
+		return principal.(*domain.Principal)
+	}
+	return nil
+}
+
+// tenantOf возвращает арендатора запроса
+func tenantOf(ctx *wbgin.Context) string {
+	if principal := principalOf(ctx); principal != nil {
+		return principal.Tenant
+	}
+	return domain.DefaultTenant
+}
+
+// authenticated сообщает, что арендатор запроса подтверждён токеном
+func authenticated(ctx *wbgin.Context) bool {
+	return principalOf(ctx) != nil
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tenantRequest(engine http.Handler, method, path, token string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	mockSvc := new(MockImageService)
+	mockKeys := new(MockKeyProvider)
+	engine, _ := newTestEngine(t, mockSvc, withTenants(), withKeys(mockKeys))
+	mockKeys.On("AuthenticateKey", "tok-unknown").Return((*domain.Principal)(nil), domain.ErrAPIKeyNotFound)
+	mockSvc.On("GetUsage", "acme").Return(domain.NewTenantUsage(domain.Usage{Tenant: "acme"}, domain.QuotaLimits{}), nil)
+
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/usage", "", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/usage", "tok-unknown", nil).Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/usage", "tok-acme", nil).Code)
+	// документация доступна без токена
+	assert.NotEqual(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/swagger/index.html", "", nil).Code)
+}
+
+func TestCrossTenantAccess_NotFound(t *testing.T) {
+	id := uuid.New().String()
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withTenants())
+
+	mockSvc.On("GetImage", "acme", id).Return(&domain.Image{ID: uuid.MustParse(id), TenantID: "acme", Status: domain.Processing}, nil)
+	mockSvc.On("GetImage", "globex", id).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	mockSvc.On("DeleteImage", "globex", id).Return(domain.ErrImageNotFound)
+	mockSvc.On("HardDeleteImage", "globex", id).Return(domain.ErrImageNotFound)
+	mockSvc.On("RestoreImage", "globex", id).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+	mockSvc.On("CancelImage", "globex", id).Return(domain.ErrImageNotFound)
+	mockSvc.On("GetWebhookDeliveries", "globex", id).Return([]domain.WebhookDelivery(nil), domain.ErrImageNotFound)
+	mockSvc.On("GetBatch", "globex", id).Return((*domain.BatchProgress)(nil), domain.ErrBatchNotFound)
+
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "tok-acme", nil).Code)
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/image/" + id},
+		{http.MethodGet, "/api/image/" + id + "/meta"},
+		{http.MethodGet, "/api/image/" + id + "/file"},
+		{http.MethodGet, "/api/image/" + id + "/original"},
+		{http.MethodGet, "/api/image/" + id + "/events"},
+		{http.MethodGet, "/api/image/" + id + "/webhooks"},
+		{http.MethodDelete, "/api/image/" + id},
+		{http.MethodDelete, "/api/image/" + id + "?hard=true"},
+		{http.MethodPost, "/api/image/" + id + "/restore"},
+		{http.MethodPost, "/api/image/" + id + "/cancel"},
+		{http.MethodGet, "/api/batch/" + id},
+	}
+	for _, r := range requests {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			assert.Equal(t, http.StatusNotFound, tenantRequest(engine, r.method, r.path, "tok-globex", nil).Code)
+		})
+	}
+}
+
+func TestGetImageOriginal_TenantToken(t *testing.T) {
+	id := uuid.New().String()
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withTenants())
+	img := &domain.Image{ID: uuid.MustParse(id), TenantID: "acme", Name: "src.png", Source: &domain.FileInfo{Key: "tenants/acme/ab/cd/src.png"}}
+	path := filepath.Join(cfg.StoragePathConfig.InputDir, filepath.FromSlash(img.OriginalKey()))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte("original"), 0644))
+	mockSvc.On("GetImage", "acme", id).Return(img, nil)
+
+	// ORIGINALS_TOKEN не задан, но владелец исходника подтверждён своим токеном
+	w := tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/original", "tok-acme", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "original", w.Body.String())
+}
+
+func TestTus_CrossTenantUpload(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withTenants())
+	mockSvc.On("CheckQuota", "acme", int64(5)).Return(nil)
+	tusHeaders := func(extra map[string]string) map[string]string {
+		headers := map[string]string{"Tus-Resumable": TusVersion}
+		for k, v := range extra {
+			headers[k] = v
+		}
+		return headers
+	}
+
+	w := tenantRequest(engine, http.MethodPost, "/api/tus/", "tok-acme", tusHeaders(map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": "filename " + b64("a.png"),
+	}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+
+	assert.Equal(t, http.StatusNotFound, tenantRequest(engine, http.MethodHead, location, "tok-globex", tusHeaders(nil)).Code)
+	assert.Equal(t, http.StatusNotFound, tenantRequest(engine, http.MethodPatch, location, "tok-globex",
+		tusHeaders(map[string]string{"Content-Type": tusOffsetStreamType, "Upload-Offset": "0"})).Code)
+	assert.Equal(t, http.StatusNotFound, tenantRequest(engine, http.MethodDelete, location, "tok-globex", tusHeaders(nil)).Code)
+
+	// загрузка владельца не пострадала
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodHead, location, "tok-acme", tusHeaders(nil)).Code)
+	mockSvc.AssertNotCalled(t, "UploadImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthenticate_APIKeyScopes(t *testing.T) {
+	id := uuid.New().String()
+	mockSvc := new(MockImageService)
+	mockKeys := new(MockKeyProvider)
+	engine, _ := newTestEngine(t, mockSvc, withTenants(), withKeys(mockKeys))
+	mockKeys.On("AuthenticateKey", "ipk_reader").Return(&domain.Principal{Tenant: "acme", Scopes: domain.Scopes{domain.ScopeImagesRead}, KeyID: "k1"}, nil)
+	mockKeys.On("AuthenticateKey", "ipk_admin").Return(&domain.Principal{Tenant: "acme", Scopes: domain.Scopes{domain.ScopeAdmin}, KeyID: "k2"}, nil)
+	mockSvc.On("GetImage", "acme", id).Return(&domain.Image{ID: uuid.MustParse(id), TenantID: "acme", Status: domain.Processing}, nil)
+	mockSvc.On("DeleteImage", "acme", id).Return(nil)
+	mockKeys.On("ListAPIKeys", "acme").Return([]domain.APIKey{}, nil)
+
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "ipk_reader", nil).Code)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodDelete, "/api/image/"+id, "ipk_reader", nil).Code)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodPost, "/api/upload/url", "ipk_reader", nil).Code)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, "/api/keys", "ipk_reader", nil).Code)
+	mockSvc.AssertNotCalled(t, "DeleteImage", mock.Anything, mock.Anything)
+
+	// admin включает остальные права
+	assert.Equal(t, http.StatusNoContent, tenantRequest(engine, http.MethodDelete, "/api/image/"+id, "ipk_admin", nil).Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/keys", "ipk_admin", nil).Code)
+	// статический токен арендатора — admin для выпуска первых ключей
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/keys", "tok-acme", nil).Code)
+}
+
+func TestAuthenticate_KeyStorageError(t *testing.T) {
+	mockSvc := new(MockImageService)
+	mockKeys := new(MockKeyProvider)
+	engine, _ := newTestEngine(t, mockSvc, withTenants(), withKeys(mockKeys))
+	mockKeys.On("AuthenticateKey", "ipk_any").Return((*domain.Principal)(nil), errors.New("db down"))
+
+	assert.Equal(t, http.StatusInternalServerError, tenantRequest(engine, http.MethodGet, "/api/usage", "ipk_any", nil).Code)
+}
+
+func TestLookupTenant(t *testing.T) {
+	tokens := map[string]string{"tok-acme": "acme"}
+	tenant, ok := lookupTenant(tokens, "tok-acme")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenant)
+	_, ok = lookupTenant(tokens, "tok-acm")
+	assert.False(t, ok)
+	_, ok = lookupTenant(tokens, "")
+	assert.False(t, ok)
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/domain"
+	"mime/multipart"
+	"net/http"
+)
+
+// BatchItemParams — параметры отдельного файла пакета, заданные поля переопределяют общие
+type BatchItemParams struct {
+	Watermark   *string  `json:"watermark,omitempty" example:"WM"`
+	Resize      *string  `json:"resize,omitempty" example:"300x300"`
+	Mini        *bool    `json:"mini,omitempty"`
+	CallbackURL *string  `json:"callback_url,omitempty"`
+	Priority    *string  `json:"priority,omitempty" example:"bulk"`
+	Tags        []string `json:"tags,omitempty"`
+	Owner       *string  `json:"owner,omitempty"`
+}
+
+// BatchUploadResponse — результат пакетной загрузки по каждому файлу
+type BatchUploadResponse struct {
+	BatchID string                   `json:"batch_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Total   int                      `json:"total" example:"3"`
+	Failed  int                      `json:"failed" example:"1"`
+	Items   []domain.BatchItemResult `json:"items"`
+}
+
+func (p BatchItemParams) apply(params domain.ImageParams) domain.ImageParams {
+	if p.Watermark != nil {
+		params.Watermark = *p.Watermark
+	}
+	if p.Resize != nil {
+		params.Resize = *p.Resize
+	}
+	if p.Mini != nil {
+		params.Mini = *p.Mini
+	}
+	if p.CallbackURL != nil {
+		params.CallbackURL = *p.CallbackURL
+	}
+	if p.Priority != nil {
+		params.Priority = *p.Priority
+	}
+	if p.Tags != nil {
+		params.Tags = p.Tags
+	}
+	if p.Owner != nil {
+		params.Owner = *p.Owner
+	}
+	return params
+}
+
+// UploadBatch godoc
+// @Summary Пакетная загрузка изображений
+// @Description Загружает несколько файлов с общими параметрами; параметры отдельных файлов можно переопределить JSON-массивом params (по порядку файлов). Ошибка одного файла не мешает остальным.
+// @Tags Batches
+// @Accept multipart/form-data
+// @Produce json
+// @Param files formData file true "Image files (repeat the field for each file)"
+// @Param watermark formData string false "Shared watermark text"
+// @Param resize formData string false "Shared resize in format WIDTHxHEIGHT"
+// @Param mini formData string false "Shared thumbnail flag, 1 = true, 0 = false"
+// @Param callback_url formData string false "Shared webhook URL"
+// @Param priority formData string false "Shared processing lane: interactive or bulk"
+// @Param tags formData string false "Shared comma-separated tags"
+// @Param owner formData string false "Shared owner label"
+// @Param params formData string false "JSON array of per-file overrides, e.g. [{\"resize\":\"100x100\"},{\"watermark\":\"WM\"}]"
+// @Success 200 {object} BatchUploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 413 {object} UploadErrorResponse "A file exceeds upload.max_bytes"
+// @Failure 429 {object} ErrorResponse "Rate limit or concurrent upload limit exceeded"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/upload/batch [post]
+func (h *ImageHandler) UploadBatch(ctx *wbgin.Context) {
+	var req ImageReqUpload
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(requestErrorStatus(err), errorResponse(err))
+		return
+	}
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		ctx.JSON(requestErrorStatus(err), errorResponse(err))
+		return
+	}
+	headers := form.File["files"]
+	if len(headers) == 0 {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "at least one file is required in field 'files'"})
+		return
+	}
+	if h.cfg.BatchConfig.MaxFiles > 0 && len(headers) > h.cfg.BatchConfig.MaxFiles {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": fmt.Sprintf("too many files in batch: %d, max %d", len(headers), h.cfg.BatchConfig.MaxFiles)})
+		return
+	}
+	var overrides []BatchItemParams
+	if raw := ctx.PostForm("params"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "params must be a JSON array: " + err.Error()})
+			return
+		}
+		if len(overrides) > len(headers) {
+			ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "params has more entries than files"})
+			return
+		}
+	}
+
+	shared := domain.ImageParams{
+		Watermark:   req.Watermark,
+		Resize:      req.Resize,
+		Mini:        req.Mini == "1",
+		CallbackURL: req.CallbackURL,
+		Priority:    req.Priority,
+		Tags:        domain.ParseTags(req.Tags),
+		Owner:       req.Owner,
+	}
+	files := make([]domain.BatchFile, 0, len(headers))
+	opened := make([]multipart.File, 0, len(headers))
+	defer func() {
+		for _, f := range opened {
+			_ = f.Close()
+		}
+	}()
+	for i, header := range headers {
+		if max := h.cfg.UploadConfig.MaxBytes; max > 0 && header.Size > max {
+			err := &domain.UploadError{Code: domain.CodeFileTooLarge, Message: fmt.Sprintf("file %q exceeds %d bytes", header.Filename, max)}
+			ctx.JSON(errorStatus(err), errorResponse(err))
+			return
+		}
+		params := shared
+		if i < len(overrides) {
+			params = overrides[i].apply(shared)
+		}
+		f, err := header.Open()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+			return
+		}
+		opened = append(opened, f)
+		files = append(files, domain.BatchFile{Filename: header.Filename, Params: params, File: f})
+	}
+
+	batch, results, err := h.imageProcessor.UploadBatch(tenantOf(ctx), files)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	resp := BatchUploadResponse{
+		BatchID: batch.ID.String(),
+		Total:   len(results),
+		Items:   results,
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			resp.Failed++
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetBatch godoc
+// @Summary Прогресс пакета
+// @Description Возвращает агрегированный прогресс обработки всех изображений пакета
+// @Tags Batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} domain.BatchProgress
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/batch/{id} [get]
+func (h *ImageHandler) GetBatch(ctx *wbgin.Context) {
+	progress, err := h.imageProcessor.GetBatch(tenantOf(ctx), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, progress)
+}
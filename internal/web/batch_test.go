@@ -0,0 +1,112 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newBatchRequest(t *testing.T, files []string, fields map[string]string) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, name := range files {
+		part, _ := writer.CreateFormFile("files", name)
+		_, _ = part.Write([]byte("data"))
+	}
+	for k, v := range fields {
+		_ = writer.WriteField(k, v)
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest("POST", "/api/upload/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadBatch_MergesParams(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = newBatchRequest(t, []string{"a.png", "b.png"}, map[string]string{
+		"watermark": "Shared",
+		"resize":    "500x500",
+		"params":    `[{"resize":"100x100","mini":true}]`,
+	})
+
+	batch := domain.NewBatch("", 2)
+	results := []domain.BatchItemResult{
+		{Index: 0, Filename: "a.png", Image: &domain.Image{ID: uuid.New()}},
+		{Index: 1, Filename: "b.png", Error: "boom"},
+	}
+	mockSvc.On("UploadBatch", "", mock.MatchedBy(func(files []domain.BatchFile) bool {
+		return len(files) == 2 &&
+			assert.ObjectsAreEqual(domain.ImageParams{Watermark: "Shared", Resize: "100x100", Mini: true}, files[0].Params) &&
+			assert.ObjectsAreEqual(domain.ImageParams{Watermark: "Shared", Resize: "500x500"}, files[1].Params)
+	})).Return(batch, results, nil)
+
+	handler.UploadBatch(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp BatchUploadResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, batch.ID.String(), resp.BatchID)
+	assert.Equal(t, 2, resp.Total)
+	assert.Equal(t, 1, resp.Failed)
+}
+
+func TestUploadBatch_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string
+		fields map[string]string
+	}{
+		{"no files", nil, map[string]string{"watermark": "WM"}},
+		{"too many files", []string{"a.png", "b.png", "c.png"}, nil},
+		{"invalid params", []string{"a.png"}, map[string]string{"params": "{"}},
+		{"params longer than files", []string{"a.png"}, map[string]string{"params": "[{},{}]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			cfg := &config.AppConfig{BatchConfig: config.BatchConfig{MaxFiles: 2}}
+			handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = newBatchRequest(t, tt.files, tt.fields)
+
+			handler.UploadBatch(ctx)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockSvc.AssertNotCalled(t, "UploadBatch", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetBatch_NotFound(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+	mockSvc.On("GetBatch", "", "1").Return((*domain.BatchProgress)(nil), domain.ErrBatchNotFound)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/batch/1", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.GetBatch(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/config"
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods  = "POST, GET, OPTIONS, DELETE, HEAD, PATCH"
+	corsAllowHeaders  = "Content-Type, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata"
+	corsExposeHeaders = "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, " +
+		"RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, " + HeaderImageID
+)
+
+// CORS разрешает браузеру читать ответы API только со страниц источников cors.allowed_origins;
+// "*" разрешает любой источник. Префлайт маршрута без своего OPTIONS-обработчика получает 204
+func CORS(cfg config.CORSConfig) wbgin.HandlerFunc {
+	origins := newOriginPolicy(cfg)
+	return func(c *wbgin.Context) {
+		origin := c.GetHeader("Origin")
+		if !origins.any && len(origins.allowed) > 0 {
+			// ответ зависит от Origin, кэш не должен отдать его другому источнику
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+		if origin != "" && origins.allows(origin) {
+			if origins.any {
+				origin = "*"
+			}
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+		// маршруты со своим OPTIONS-обработчиком (tus) отвечают сами
+		if c.Request.Method == http.MethodOptions && c.FullPath() == "" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// originPolicy — источники cors.allowed_origins; any означает "*"
+type originPolicy struct {
+	any     bool
+	allowed map[string]bool
+}
+
+func newOriginPolicy(cfg config.CORSConfig) originPolicy {
+	policy := originPolicy{allowed: make(map[string]bool, len(cfg.AllowedOrigins))}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			policy.any = true
+		}
+		policy.allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return policy
+}
+
+func (p originPolicy) allows(origin string) bool {
+	return p.any || p.allowed[origin]
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newEngine := func(origins ...string) *gin.Engine {
+		engine := gin.New()
+		engine.Use(CORS(config.CORSConfig{AllowedOrigins: origins}))
+		engine.GET("/api/images", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+		return engine
+	}
+	request := func(engine http.Handler, method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/images", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// по умолчанию браузер не может читать ответы с чужих страниц
+	w := request(newEngine(), http.MethodGet, "https://evil.example")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	engine := newEngine("https://app.example.com/")
+	w = request(engine, http.MethodGet, "https://app.example.com")
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Upload-Offset")
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	w = request(engine, http.MethodGet, "https://evil.example")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+
+	// префлайт маршрута без своего OPTIONS-обработчика
+	w = request(engine, http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+
+	w = request(newEngine("*"), http.MethodGet, "https://any.example")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
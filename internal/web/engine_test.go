@@ -0,0 +1,88 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/pubsub"
+	"imageProcessor/internal/ratelimit"
+	"imageProcessor/internal/tus"
+	"imageProcessor/internal/urlsign"
+	"testing"
+)
+
+// testTenants — арендаторы acme (токен tok-acme) и globex (tok-globex)
+var testTenants = map[string]string{"tok-acme": "acme", "tok-globex": "globex"}
+
+// testEngine — зависимости тестового API, которые меняют опции newTestEngine
+type testEngine struct {
+	cfg      *config.AppConfig
+	hub      *pubsub.Hub
+	keys     *MockKeyProvider
+	verifier TokenVerifier
+}
+
+type engineOption func(*testEngine)
+
+// withConfig меняет конфигурацию до сборки API
+func withConfig(apply func(cfg *config.AppConfig)) engineOption {
+	return func(e *testEngine) { apply(e.cfg) }
+}
+
+// withTenants включает аутентификацию арендаторов testTenants
+func withTenants() engineOption {
+	return withConfig(func(cfg *config.AppConfig) { cfg.AuthConfig.Tokens = testTenants })
+}
+
+// withKeys проверяет ключи API через keys
+func withKeys(keys *MockKeyProvider) engineOption {
+	return func(e *testEngine) { e.keys = keys }
+}
+
+// withHub публикует статусы изображений в hub
+func withHub(hub *pubsub.Hub) engineOption {
+	return func(e *testEngine) { e.hub = hub }
+}
+
+// withJWT включает проверку JWT провайдера единого входа через verifier
+func withJWT(verifier TokenVerifier) engineOption {
+	return func(e *testEngine) {
+		e.cfg.AuthConfig.OIDC.Issuer = "https://idp.example.com/"
+		e.verifier = verifier
+	}
+}
+
+// newTestEngine собирает API так же, как di.StartHTTPServer. По умолчанию аутентификация, лимиты
+// и подпись ссылок выключены, доверенных прокси нет, файлы хранятся во временных каталогах теста
+func newTestEngine(t *testing.T, mockSvc *MockImageService, opts ...engineOption) (*wbgin.Engine, *config.AppConfig) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	e := &testEngine{
+		cfg: &config.AppConfig{
+			ImageFormats:      config.ImageFormats{SupportedFormats: map[string]bool{"png": true}},
+			StoragePathConfig: config.StoragePathConfig{InputDir: t.TempDir() + "/", OutputDir: t.TempDir() + "/"},
+		},
+		hub:  pubsub.NewHub(),
+		keys: new(MockKeyProvider),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	cfg := e.cfg
+
+	engine := &wbgin.Engine{Engine: gin.New()}
+	assert.NoError(t, engine.SetTrustedProxies(cfg.ServerConfig.TrustedProxies))
+	if e.verifier != nil {
+		engine.Use(BearerJWT(e.verifier))
+	}
+	RegisterRoutes(engine,
+		NewCommentHandler(mockSvc, e.hub, blob.NewLocalStores(cfg), cfg),
+		NewTusHandler(tus.NewStore(cfg), mockSvc, cfg),
+		NewKeyHandler(e.keys, cfg),
+		NewSignedURLHandler(mockSvc, urlsign.NewSigner(cfg), cfg),
+		ratelimit.NewLimiter(ratelimit.NewMemoryStore(), cfg),
+	)
+	return engine, cfg
+}
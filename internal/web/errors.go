@@ -0,0 +1,97 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/tus"
+	"net/http"
+)
+
+// UploadErrorResponse — ошибка отклонённой загрузки с машинно-читаемым кодом
+type UploadErrorResponse struct {
+	Error          string `json:"error" example:"file extension .png does not match content (jpeg)"`
+	Code           string `json:"code" example:"format_mismatch" enums:"invalid_params,unsupported_media_type,format_mismatch,invalid_image,file_too_large,image_too_large,quota_exceeded"`
+	DetectedFormat string `json:"detected_format,omitempty" example:"jpeg"`
+}
+
+// errorResponse формирует тело ответа: для ошибок загрузки — с кодом причины
+func errorResponse(err error) any {
+	var uploadErr *domain.UploadError
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return UploadErrorResponse{
+			Error: fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit),
+			Code:  string(domain.CodeFileTooLarge),
+		}
+	}
+	if errors.As(err, &uploadErr) {
+		return UploadErrorResponse{
+			Error:          uploadErr.Message,
+			Code:           string(uploadErr.Code),
+			DetectedFormat: uploadErr.DetectedFormat,
+		}
+	}
+	return wbgin.H{"error": err.Error()}
+}
+
+// requestErrorStatus — статус для ошибок разбора запроса: превышение размера тела отличается от некорректного ввода
+func requestErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// limitBody ограничивает размер тела запроса; превышение всплывает при разборе формы как *http.MaxBytesError
+func limitBody(limit int64) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		if limit > 0 {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		}
+		ctx.Next()
+	}
+}
+
+// errorStatus сопоставляет ошибки доменного слоя с HTTP-статусами
+func errorStatus(err error) int {
+	var trErr *domain.TransitionError
+	var uploadErr *domain.UploadError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &uploadErr):
+		switch uploadErr.Code {
+		case domain.CodeUnsupportedMedia:
+			return http.StatusUnsupportedMediaType
+		case domain.CodeFileTooLarge, domain.CodeImageTooLarge:
+			return http.StatusRequestEntityTooLarge
+		case domain.CodeQuotaExceeded:
+			return http.StatusInsufficientStorage
+		}
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrImageNotFound), errors.Is(err, domain.ErrBatchNotFound), errors.Is(err, tus.ErrUploadNotFound),
+		errors.Is(err, domain.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &trErr), errors.Is(err, tus.ErrOffsetMismatch), errors.Is(err, domain.ErrNotInTrash):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrTrashExpired):
+		return http.StatusGone
+	case errors.Is(err, tus.ErrUploadLocked):
+		return http.StatusLocked
+	case errors.Is(err, fetcher.ErrInvalidURL), errors.Is(err, fetcher.ErrForbiddenAddress), errors.Is(err, domain.ErrInvalidAPIKey):
+		return http.StatusBadRequest
+	case errors.Is(err, fetcher.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, fetcher.ErrUnsupportedContentType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, fetcher.ErrUpstream):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
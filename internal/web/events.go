@@ -0,0 +1,187 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"golang.org/x/net/websocket"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const sseHeartbeat = 15 * time.Second
+
+type StatusSubscriber interface {
+	Subscribe(ids ...uuid.UUID) *pubsub.Subscription
+}
+
+// WSRequest — сообщение клиента в WebSocket: подписка/отписка от изображений
+type WSRequest struct {
+	Action string   `json:"action" example:"subscribe" enums:"subscribe,unsubscribe"`
+	IDs    []string `json:"ids"`
+}
+
+func isFinalStatus(status domain.StatusType) bool {
+	return status == domain.Processed || status == domain.Failed || status == domain.Cancelled || status == domain.Deleted
+}
+
+// StreamImageEvents godoc
+// @Summary Поток статусов изображения (SSE)
+// @Description Отправляет текущий статус и далее каждую смену статуса как событие `status`, закрывает поток после конечного статуса
+// @Tags Images
+// @Produce text/event-stream
+// @Param id path string true "Image ID"
+// @Success 200 {object} domain.StatusEvent "Stream of status events"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/events [get]
+func (h *ImageHandler) StreamImageEvents(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+
+	img, err := h.imageProcessor.GetImage(tenantOf(ctx), id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	// подписываемся до отправки снимка, чтобы не потерять переход между ними
+	sub := h.events.Subscribe(img.ID)
+	defer sub.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ctx.SSEvent("status", domain.NewStatusEvent(img.ID, img.Status))
+	ctx.Writer.Flush()
+	if isFinalStatus(img.Status) {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, _ = ctx.Writer.WriteString(": ping\n\n")
+			ctx.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			ctx.SSEvent("status", event)
+			ctx.Writer.Flush()
+			if isFinalStatus(event.Status) {
+				return
+			}
+		}
+	}
+}
+
+// StatusWebSocket godoc
+// @Summary Мультиплексированные статусы изображений (WebSocket)
+// @Description Клиент отправляет {"action":"subscribe","ids":[...]} или unsubscribe, сервер присылает текущий статус каждого нового ID и далее события смены статуса
+// @Tags Images
+// @Success 101 {object} domain.StatusEvent "Switching Protocols"
+// @Router /api/ws [get]
+func (h *ImageHandler) StatusWebSocket(ctx *wbgin.Context) {
+	tenant := tenantOf(ctx)
+	origins := newOriginPolicy(h.cfg.CORSConfig)
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			return checkWebSocketOrigin(origins, r)
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.serveStatusWebSocket(ws, tenant)
+		},
+	}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// checkWebSocketOrigin пускает WebSocket только со страниц источников cors.allowed_origins и самого сервиса:
+// на WebSocket не действует CORS, и без проверки любой сайт открыл бы поток от имени браузера пользователя.
+// Запрос без Origin приходит не из браузера и проверяется только аутентификацией
+func checkWebSocketOrigin(origins originPolicy, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origins.allows(origin) {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return fmt.Errorf("websocket origin %q is not allowed", origin)
+}
+
+// serveStatusWebSocket подписывает только на изображения арендатора tenant; чужие ID пропускаются, как несуществующие
+func (h *ImageHandler) serveStatusWebSocket(ws *websocket.Conn, tenant string) {
+	sub := h.events.Subscribe()
+	defer sub.Close()
+	// снимки статусов пишет читающая горутина, события — основная; websocket.Conn
+	// не допускает конкурентную запись, поэтому всё идёт через один канал
+	out := make(chan *domain.StatusEvent, 16)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			var req WSRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			for _, rawID := range req.IDs {
+				uid, err := uuid.Parse(rawID)
+				if err != nil {
+					continue
+				}
+				switch req.Action {
+				case "subscribe":
+					// владелец проверяется до подписки, снимок читается после неё, чтобы не потерять переход между ними
+					if _, err := h.imageProcessor.GetImage(tenant, rawID); err != nil {
+						continue
+					}
+					sub.Add(uid)
+					img, err := h.imageProcessor.GetImage(tenant, rawID)
+					if err != nil {
+						continue
+					}
+					select {
+					case out <- domain.NewStatusEvent(img.ID, img.Status):
+					case <-ws.Request().Context().Done():
+						return
+					}
+				case "unsubscribe":
+					sub.Remove(uid)
+				}
+			}
+		}
+	}()
+
+	for {
+		var event *domain.StatusEvent
+		select {
+		case <-done:
+			return
+		case event = <-out:
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			event = e
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		if err := websocket.Message.Send(ws, string(data)); err != nil {
+			wbzlog.Logger.Debug().Err(err).Msg("websocket send failed")
+			return
+		}
+	}
+}
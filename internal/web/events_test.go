@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newEventsServer(t *testing.T, mockSvc *MockImageService, hub *pubsub.Hub) *httptest.Server {
+	t.Helper()
+	engine, _ := newTestEngine(t, mockSvc, withHub(hub))
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamImageEvents(t *testing.T) {
+	mockSvc := new(MockImageService)
+	hub := pubsub.NewHub()
+	server := newEventsServer(t, mockSvc, hub)
+
+	id := uuid.New()
+	mockSvc.On("GetImage", "", id.String()).Return(&domain.Image{ID: id, Status: domain.Processing}, nil)
+
+	resp, err := http.Get(server.URL + "/api/image/" + id.String() + "/events")
+	assert.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() domain.StatusEvent {
+		var event domain.StatusEvent
+		for {
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			if strings.HasPrefix(line, "data:") {
+				assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event))
+				return event
+			}
+		}
+	}
+
+	assert.Equal(t, domain.Processing, readEvent().Status)
+
+	_ = hub.PublishEvent(domain.NewStatusEvent(id, domain.Processed))
+	assert.Equal(t, domain.Processed, readEvent().Status)
+
+	// после конечного статуса сервер закрывает поток
+	_, err = reader.ReadString('\n')
+	for err == nil {
+		_, err = reader.ReadString('\n')
+	}
+}
+
+func TestStreamImageEvents_NotFound(t *testing.T) {
+	mockSvc := new(MockImageService)
+	server := newEventsServer(t, mockSvc, pubsub.NewHub())
+
+	mockSvc.On("GetImage", "", "missing").Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	resp, err := http.Get(server.URL + "/api/image/missing/events")
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestStatusWebSocket(t *testing.T) {
+	mockSvc := new(MockImageService)
+	hub := pubsub.NewHub()
+	server := newEventsServer(t, mockSvc, hub)
+
+	first, second := uuid.New(), uuid.New()
+	mockSvc.On("GetImage", "", first.String()).Return(&domain.Image{ID: first, Status: domain.Created}, nil)
+	mockSvc.On("GetImage", "", second.String()).Return(&domain.Image{ID: second, Status: domain.Processing}, nil)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	assert.NoError(t, err)
+	defer func() { _ = ws.Close() }()
+
+	err = websocket.JSON.Send(ws, WSRequest{Action: "subscribe", IDs: []string{first.String(), second.String()}})
+	assert.NoError(t, err)
+
+	received := map[uuid.UUID]domain.StatusType{}
+	for i := 0; i < 2; i++ {
+		var event domain.StatusEvent
+		assert.NoError(t, websocket.JSON.Receive(ws, &event))
+		received[event.ImageID] = event.Status
+	}
+	assert.Equal(t, domain.Created, received[first])
+	assert.Equal(t, domain.Processing, received[second])
+
+	_ = hub.PublishEvent(domain.NewStatusEvent(uuid.New(), domain.Processed))
+	_ = hub.PublishEvent(domain.NewStatusEvent(second, domain.Processed))
+
+	var event domain.StatusEvent
+	assert.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, second, event.ImageID)
+	assert.Equal(t, domain.Processed, event.Status)
+}
+
+func TestStreamTicket(t *testing.T) {
+	mockSvc := new(MockImageService)
+	hub := pubsub.NewHub()
+	engine, _ := newTestEngine(t, mockSvc, withHub(hub), withTenants(), withSignedURLs(false))
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	id := uuid.New()
+	mockSvc.On("GetImage", "acme", id.String()).Return(&domain.Image{ID: id, Status: domain.Processed}, nil)
+
+	w := tenantRequest(engine, http.MethodPost, "/api/stream-ticket", "tok-acme", nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var ticket StreamTicketResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ticket))
+
+	// EventSource и WebSocket в браузере не передают Authorization, арендатор берётся из билета
+	resp, err := http.Get(server.URL + "/api/image/" + id.String() + "/events?" + ticket.Query)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws?"+ticket.Query, "", server.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, websocket.JSON.Send(ws, WSRequest{Action: "subscribe", IDs: []string{id.String()}}))
+	var event domain.StatusEvent
+	assert.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, domain.Processed, event.Status)
+	_ = ws.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/image/"+id.String()+"/events", "", nil).Code)
+	forged := strings.Replace(ticket.Query, "tenant=acme", "tenant=globex", 1)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, "/api/image/"+id.String()+"/events?"+forged, "", nil).Code)
+	// билет открывает только потоки статусов
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, "/api/image/"+id.String()+"?"+ticket.Query, "", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/image/"+id.String()+"/meta?"+ticket.Query, "", nil).Code)
+}
+
+func TestStreamTicket_SigningDisabled(t *testing.T) {
+	engine, _ := newTestEngine(t, new(MockImageService), withTenants())
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodPost, "/api/stream-ticket", "tok-acme", nil).Code)
+}
+
+func TestStatusWebSocket_Origin(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withHub(pubsub.NewHub()), withConfig(func(cfg *config.AppConfig) {
+		cfg.CORSConfig.AllowedOrigins = []string{"https://ui.example.com"}
+	}))
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "allowed origin", origin: "https://ui.example.com", allowed: true},
+		{name: "same origin", origin: server.URL, allowed: true},
+		{name: "foreign origin", origin: "https://evil.example.com", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := websocket.Dial(wsURL, "", tt.origin)
+			if tt.allowed {
+				assert.NoError(t, err)
+				_ = ws.Close()
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"errors"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/domain"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveFile отдаёт объект хранилища через http.ServeContent: он отвечает 304 по If-None-Match и If-Modified-Since
+// и поддерживает Range. etag — хэш содержимого без кавычек, пустой для файлов без сохранённого хэша
+func serveFile(ctx *wbgin.Context, store blob.Store, key, etag, cacheControl string, modTime *time.Time) {
+	obj, err := store.Get(ctx.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, wbgin.H{"error": "file not found"})
+			return
+		}
+		wbzlog.Logger.Error().Err(err).Msg("Failed to open file for download")
+		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		return
+	}
+	defer func() {
+		_ = obj.Close()
+	}()
+
+	modified := obj.Info.ModTime
+	if modTime != nil {
+		modified = *modTime
+	}
+	if etag != "" {
+		ctx.Header("ETag", `"`+etag+`"`)
+	}
+	if cacheControl = responseCacheControl(ctx, cacheControl); cacheControl != "" {
+		ctx.Header("Cache-Control", cacheControl)
+	}
+	http.ServeContent(ctx.Writer, ctx.Request, key, modified, obj)
+	// у 304 нет тела, gin отправил бы заголовок только после выхода из обработчика
+	ctx.Writer.WriteHeaderNow()
+}
+
+// responseCacheControl не даёт общим кэшам сохранить файл, открытый токеном или подписанной ссылкой:
+// иначе CDN отдал бы его следующим клиентам без проверки. public в таких ответах заменяется на private
+func responseCacheControl(ctx *wbgin.Context, cacheControl string) string {
+	if cacheControl == "" || !authenticated(ctx) {
+		return cacheControl
+	}
+	directives := []string{"private"}
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		switch strings.ToLower(directive) {
+		case "", "public", "private":
+			continue
+		}
+		directives = append(directives, directive)
+	}
+	return strings.Join(directives, ", ")
+}
+
+// serveOutput отдаёт результат обработки с ETag из хэша, посчитанного воркером
+func (h *ImageHandler) serveOutput(ctx *wbgin.Context, img *domain.Image) {
+	var etag string
+	if img.Output != nil {
+		etag = img.Output.SHA256
+	}
+	serveFile(ctx, h.blobs.Processed, img.OutputKey(), etag, h.cfg.CacheConfig.ProcessedCacheControl, img.ProcessedAt)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/blob"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetImageFile_Caching(t *testing.T) {
+	outDir := t.TempDir() + "/"
+	assert.NoError(t, os.WriteFile(outDir+"done.png", []byte("0123456789"), 0644))
+	processedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockSvc := new(MockImageService)
+	mockSvc.On("GetImage", "", "1").Return(&domain.Image{
+		Name:        "done.png",
+		Status:      domain.Processed,
+		Output:      &domain.FileInfo{SHA256: "abc123"},
+		ProcessedAt: &processedAt,
+	}, nil)
+	cfg := &config.AppConfig{
+		StoragePathConfig: config.StoragePathConfig{OutputDir: outDir},
+		CacheConfig:       config.CacheConfig{ProcessedCacheControl: "public, max-age=31536000, immutable"},
+	}
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/api/image/1/file", nil)
+		for k, v := range headers {
+			ctx.Request.Header.Set(k, v)
+		}
+		ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+		handler.GetImageFile(ctx)
+		return w
+	}
+
+	w := serve(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	assert.Equal(t, processedAt.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+
+	w = serve(map[string]string{"If-None-Match": `"abc123"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = serve(map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(map[string]string{"If-Modified-Since": processedAt.Add(time.Hour).Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = serve(map[string]string{"Range": "bytes=2-5"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "2345", w.Body.String())
+	assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"))
+}
+
+func TestGetImageFile_ContentAddressed(t *testing.T) {
+	outDir := t.TempDir() + "/"
+	assert.NoError(t, os.MkdirAll(outDir+"ab/cd", 0755))
+	assert.NoError(t, os.WriteFile(outDir+"ab/cd/abcd.png", []byte("shared"), 0644))
+
+	mockSvc := new(MockImageService)
+	mockSvc.On("GetImage", "", "1").Return(&domain.Image{
+		Name:   "own-name.png",
+		Status: domain.Processed,
+		Output: &domain.FileInfo{SHA256: "abcd", Key: "ab/cd/abcd.png"},
+	}, nil)
+	cfg := &config.AppConfig{StoragePathConfig: config.StoragePathConfig{OutputDir: outDir}}
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/image/1/file", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+	handler.GetImageFile(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "shared", w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+}
+
+func TestServeFile_PrivateCacheControl(t *testing.T) {
+	public := withConfig(func(cfg *config.AppConfig) {
+		cfg.CacheConfig.ProcessedCacheControl = "public, max-age=31536000, immutable"
+	})
+
+	// без аутентификации результат можно кэшировать в CDN
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, public)
+	img := processedImage(t, cfg, "")
+	mockSvc.On("GetImage", "", img.ID.String()).Return(img, nil)
+	w := tenantRequest(engine, http.MethodGet, "/api/image/"+img.ID.String()+"/file", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+
+	mockSvc = new(MockImageService)
+	engine, cfg = newTestEngine(t, mockSvc, public, withTenants(), withSignedURLs(false))
+	img = processedImage(t, cfg, "acme")
+	mockSvc.On("GetImage", "acme", img.ID.String()).Return(img, nil)
+	w = tenantRequest(engine, http.MethodGet, "/api/image/"+img.ID.String()+"/file", "tok-acme", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+
+	signed := mintURL(t, engine, img.ID.String(), "tok-acme", "")
+	w = tenantRequest(engine, http.MethodGet, signed, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+}
@@ -1,18 +1,45 @@
 package web
 
 import (
+	"context"
+	"crypto/subtle"
 	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
 	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // ImageReqUpload представляет параметры запроса на загрузку изображения
 type ImageReqUpload struct {
-	Resize    string `form:"resize" example:"500x500" description:"Размер изображения в формате WIDTHxHEIGHT"`
-	Mini      string `form:"mini" example:"1" description:"Создать миниатюру, 1 = да, 0 = нет"`
-	Watermark string `form:"watermark" example:"Мой Водяной Знак" description:"Текст водяного знака"`
+	Resize      string `form:"resize" example:"500x500" description:"Размер изображения в формате WIDTHxHEIGHT"`
+	Mini        string `form:"mini" example:"1" description:"Создать миниатюру, 1 = да, 0 = нет"`
+	Watermark   string `form:"watermark" example:"Мой Водяной Знак" description:"Текст водяного знака"`
+	CallbackURL string `form:"callback_url" example:"https://example.com/hooks/image" description:"URL для webhook по завершении обработки"`
+	Priority    string `form:"priority" example:"interactive" description:"Очередь обработки: interactive (по умолчанию) или bulk"`
+	Tags        string `form:"tags" example:"catalog,summer" description:"Теги через запятую"`
+	Owner       string `form:"owner" example:"partner-42" description:"Владелец изображения"`
+}
+
+// ImageReqUploadURL — загрузка изображения по URL источника
+type ImageReqUploadURL struct {
+	URL         string   `json:"url" binding:"required" example:"https://cdn.example.com/images/photo.jpg"`
+	Resize      string   `json:"resize" example:"500x500"`
+	Mini        bool     `json:"mini"`
+	Watermark   string   `json:"watermark" example:"Мой Водяной Знак"`
+	CallbackURL string   `json:"callback_url" example:"https://example.com/hooks/image"`
+	Priority    string   `json:"priority" example:"interactive"`
+	Tags        []string `json:"tags" example:"catalog,summer"`
+	Owner       string   `json:"owner" example:"partner-42"`
+}
+
+// ImageStatusError — файл запрошен до завершения обработки
+type ImageStatusError struct {
+	Error  string `json:"error" example:"image is not processed"`
+	Status string `json:"status" example:"processing"`
 }
 
 // ImageResponse представляет ответ с информацией об изображении
@@ -23,20 +50,49 @@ type ImageResponse struct {
 	URL    string `json:"URL" example:"/data_img/processed/example.png" description:"URL обработанного изображения"`
 }
 
+// ImageMetaResponse — полные метаданные изображения независимо от статуса обработки
+type ImageMetaResponse struct {
+	domain.Image
+	Operations []string   `json:"operations" example:"watermark,resize"`
+	Links      ImageLinks `json:"links"`
+}
+
+// ImageLinks — ссылки на метаданные и файлы изображения; file появляется после обработки,
+// original пропадает после удаления исходника по политике хранения
+type ImageLinks struct {
+	Meta     string `json:"meta" example:"/api/image/123e4567-e89b-12d3-a456-426614174000/meta"`
+	File     string `json:"file,omitempty" example:"/api/image/123e4567-e89b-12d3-a456-426614174000/file"`
+	Original string `json:"original,omitempty" example:"/api/image/123e4567-e89b-12d3-a456-426614174000/original"`
+}
+
 type ImageHandler struct {
 	imageProcessor ImageProcessorProvider
+	events         StatusSubscriber
+	blobs          *blob.Stores
 	cfg            *config.AppConfig
 }
 
 type ImageProcessorProvider interface {
-	UploadImage(filename, watermark, resize string, mini bool, file multipart.File) (*domain.Image, error)
-	GetImage(id string) (*domain.Image, error)
-	DeleteImage(id string) error
+	UploadImage(tenant, filename string, params domain.ImageParams, file multipart.File) (*domain.Image, error)
+	UploadFromURL(ctx context.Context, tenant, rawURL string, params domain.ImageParams) (*domain.Image, error)
+	GetImage(tenant, id string) (*domain.Image, error)
+	ListImages(filter domain.ImageFilter) (*domain.ImagePage, error)
+	DeleteImage(tenant, id string) error
+	HardDeleteImage(tenant, id string) error
+	RestoreImage(tenant, id string) (*domain.Image, error)
+	CancelImage(tenant, id string) error
+	GetWebhookDeliveries(tenant, id string) ([]domain.WebhookDelivery, error)
+	UploadBatch(tenant string, files []domain.BatchFile) (*domain.Batch, []domain.BatchItemResult, error)
+	GetBatch(tenant, id string) (*domain.BatchProgress, error)
+	GetUsage(tenant string) (*domain.TenantUsage, error)
+	CheckQuota(tenant string, size int64) error
 }
 
-func NewCommentHandler(imageProcessor ImageProcessorProvider, cfg *config.AppConfig) *ImageHandler {
+func NewCommentHandler(imageProcessor ImageProcessorProvider, events StatusSubscriber, blobs *blob.Stores, cfg *config.AppConfig) *ImageHandler {
 	return &ImageHandler{
 		imageProcessor: imageProcessor,
+		events:         events,
+		blobs:          blobs,
 		cfg:            cfg,
 	}
 }
@@ -48,7 +104,7 @@ type ErrorResponse struct {
 
 // UploadImage godoc
 // @Summary Загрузка изображения
-// @Description Загружает изображение и ставит его на обработку (ресайз, миниатюра, водяной знак)
+// @Description Загружает изображение и ставит его на обработку (ресайз, миниатюра, водяной знак). Формат определяется по содержимому файла и должен совпадать с расширением, если оно указано.
 // @Tags Images
 // @Accept multipart/form-data
 // @Produce json
@@ -56,20 +112,28 @@ type ErrorResponse struct {
 // @Param watermark formData string false "Watermark text"
 // @Param resize formData string false "Resize in format WIDTHxHEIGHT, e.g., 500x500"
 // @Param mini formData string false "Generate thumbnail, 1 = true, 0 = false"
+// @Param callback_url formData string false "URL to POST a signed webhook to when processing finishes or fails"
+// @Param priority formData string false "Processing lane: interactive (default) or bulk" Enums(interactive, bulk)
+// @Param tags formData string false "Comma-separated tags, up to 20"
+// @Param owner formData string false "Owner label used for filtering"
 // @Success 200 {object} ImageResponse
-// @Failure 400 {object} ErrorResponse
+// @Failure 400 {object} UploadErrorResponse "Invalid parameters, corrupted image or extension/content mismatch"
+// @Failure 413 {object} UploadErrorResponse "File exceeds upload.max_bytes or image dimensions exceed limits"
+// @Failure 415 {object} UploadErrorResponse "Content is not a supported image"
+// @Failure 507 {object} UploadErrorResponse "Tenant quota exceeded"
+// @Failure 429 {object} ErrorResponse "Rate limit or concurrent upload limit exceeded"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/upload [post]
 func (h *ImageHandler) UploadImage(ctx *wbgin.Context) {
 
 	var req ImageReqUpload
 	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		ctx.JSON(requestErrorStatus(err), errorResponse(err))
 		return
 	}
 	file, err := ctx.FormFile("file")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		ctx.JSON(requestErrorStatus(err), errorResponse(err))
 		return
 	}
 	var m bool
@@ -90,9 +154,18 @@ func (h *ImageHandler) UploadImage(ctx *wbgin.Context) {
 		}
 	}()
 
-	img, err := h.imageProcessor.UploadImage(file.Filename, req.Watermark, req.Resize, m, f)
+	params := domain.ImageParams{
+		Watermark:   req.Watermark,
+		Resize:      req.Resize,
+		Mini:        m,
+		CallbackURL: req.CallbackURL,
+		Priority:    req.Priority,
+		Tags:        domain.ParseTags(req.Tags),
+		Owner:       req.Owner,
+	}
+	img, err := h.imageProcessor.UploadImage(tenantOf(ctx), file.Filename, params, f)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), errorResponse(err))
 		return
 	}
 
@@ -105,26 +178,91 @@ func (h *ImageHandler) UploadImage(ctx *wbgin.Context) {
 	ctx.JSON(http.StatusOK, resp)
 }
 
+// UploadImageByURL godoc
+// @Summary Загрузка изображения по URL
+// @Description Скачивает изображение с указанного адреса (с ограничением размера и времени, только image/png, image/jpeg, image/gif) и ставит его на обработку. Приватные и служебные адреса запрещены, если не перечислены в fetch.allowlist.
+// @Tags Images
+// @Accept json
+// @Produce json
+// @Param request body ImageReqUploadURL true "Source URL and processing parameters"
+// @Success 200 {object} ImageResponse
+// @Failure 400 {object} ErrorResponse "Invalid or forbidden source URL"
+// @Failure 413 {object} UploadErrorResponse "Source file or image dimensions are too large"
+// @Failure 415 {object} UploadErrorResponse "Source is not a supported image"
+// @Failure 507 {object} UploadErrorResponse "Tenant quota exceeded"
+// @Failure 502 {object} ErrorResponse "Source could not be fetched"
+// @Failure 429 {object} ErrorResponse "Rate limit or concurrent upload limit exceeded"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/upload/url [post]
+func (h *ImageHandler) UploadImageByURL(ctx *wbgin.Context) {
+	var req ImageReqUploadURL
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+	params := domain.ImageParams{
+		Watermark:   req.Watermark,
+		Resize:      req.Resize,
+		Mini:        req.Mini,
+		CallbackURL: req.CallbackURL,
+		Priority:    req.Priority,
+		Tags:        req.Tags,
+		Owner:       req.Owner,
+	}
+	img, err := h.imageProcessor.UploadFromURL(ctx.Request.Context(), tenantOf(ctx), req.URL, params)
+	if err != nil {
+		ctx.JSON(errorStatus(err), errorResponse(err))
+		return
+	}
+	resp := ImageResponse{
+		ID:     img.ID.String(),
+		Name:   img.Name,
+		Status: string(img.Status),
+		URL:    h.cfg.StoragePathConfig.OutputDir + img.Name,
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
 // GetImage godoc
 // @Summary Получение изображения
-// @Description Возвращает обработанное изображение, если оно готово, иначе — статус обработки
+// @Description Возвращает обработанное изображение, если оно готово, иначе — статус обработки; variant=original отдаёт исходник. Открывается без токена по подписанной ссылке (POST /api/image/{id}/signed-url), с signed_urls.required — только по ней или с токеном арендатора
 // @Tags Images
 // @Produce json
 // @Param id path string true "Image ID"
-// @Success 200 {file} file "Processed image file"
+// @Param variant query string false "processed (default) or original"
+// @Param expires query int false "Signed URL expiry, unix seconds"
+// @Param kid query string false "Signing key ID"
+// @Param sig query string false "Signed URL signature"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file "Processed image file with ETag, Last-Modified and Cache-Control"
 // @Success 202 {object} ImageResponse "Processing status"
+// @Success 206 {file} file "Requested byte range"
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Invalid or expired signature, or signature required"
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/image/{id} [get]
 func (h *ImageHandler) GetImage(ctx *wbgin.Context) {
+	switch variant := ctx.Query("variant"); variant {
+	case "", variantProcessed:
+	case variantOriginal:
+		h.GetImageOriginal(ctx)
+		return
+	default:
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "unknown variant: " + variant})
+		return
+	}
 	id := ctx.Param("id")
 
-	img, err := h.imageProcessor.GetImage(id)
+	img, err := h.imageProcessor.GetImage(tenantOf(ctx), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
 		return
 	}
 	if img.Status == domain.Processed {
-		ctx.File(h.cfg.StoragePathConfig.OutputDir + img.Name)
+		h.serveOutput(ctx, img)
 		return
 	}
 	resp := ImageResponse{
@@ -136,21 +274,211 @@ func (h *ImageHandler) GetImage(ctx *wbgin.Context) {
 	ctx.JSON(http.StatusAccepted, resp)
 }
 
+// GetImageMeta godoc
+// @Summary Метаданные изображения
+// @Description Всегда возвращает JSON: статус, размеры и объём исходника и результата, формат, операции, временные метки, причину ошибки и ссылки на файлы
+// @Tags Images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {object} ImageMetaResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/meta [get]
+func (h *ImageHandler) GetImageMeta(ctx *wbgin.Context) {
+	img, err := h.imageProcessor.GetImage(tenantOf(ctx), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, h.metaResponse(img))
+}
+
+func (h *ImageHandler) metaResponse(img *domain.Image) ImageMetaResponse {
+	resp := ImageMetaResponse{
+		Image:      *img,
+		Operations: img.Operations(),
+		Links:      ImageLinks{Meta: h.imageURL(img, "meta")},
+	}
+	if img.Status == domain.Processed {
+		resp.Links.File = h.imageURL(img, "file")
+	}
+	if img.OriginalDeletedAt == nil {
+		resp.Links.Original = h.imageURL(img, "original")
+	}
+	return resp
+}
+
+// GetImageFile godoc
+// @Summary Файл обработанного изображения
+// @Description Возвращает обработанное изображение; пока обработка не завершена — 409 с текущим статусом. Поддерживает If-None-Match, If-Modified-Since и Range. С signed_urls.required без токена арендатора отвечает 403
+// @Tags Images
+// @Produce octet-stream
+// @Param id path string true "Image ID"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file "Processed image file with ETag, Last-Modified and Cache-Control"
+// @Success 206 {file} file "Requested byte range"
+// @Success 304 {string} string "Not Modified"
+// @Failure 403 {object} ErrorResponse "Signature required"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ImageStatusError "Image is not processed"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/file [get]
+func (h *ImageHandler) GetImageFile(ctx *wbgin.Context) {
+	img, err := h.imageProcessor.GetImage(tenantOf(ctx), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	if img.Status != domain.Processed {
+		ctx.JSON(http.StatusConflict, ImageStatusError{Error: "image is not processed", Status: string(img.Status)})
+		return
+	}
+	h.serveOutput(ctx, img)
+}
+
+// GetImageOriginal godoc
+// @Summary Исходный файл изображения
+// @Description Возвращает загруженный исходник. Без аутентификации арендаторов требует токен ORIGINALS_TOKEN в заголовке Authorization, с ней — токен арендатора-владельца; исходник, удалённый по политике хранения, отдаёт 410
+// @Tags Images
+// @Produce octet-stream
+// @Param Authorization header string true "Bearer <ORIGINALS_TOKEN> or tenant token or API key"
+// @Param id path string true "Image ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file "Original image file"
+// @Success 206 {file} file "Requested byte range"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Original download is disabled"
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse "Original removed by retention policy"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/original [get]
+func (h *ImageHandler) GetImageOriginal(ctx *wbgin.Context) {
+	// токен арендатора уже проверен, а исходник ищется только среди его изображений
+	if !authenticated(ctx) {
+		token := h.cfg.OriginalsConfig.Token
+		if token == "" {
+			ctx.JSON(http.StatusForbidden, wbgin.H{"error": "original download is disabled"})
+			return
+		}
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			ctx.JSON(http.StatusUnauthorized, wbgin.H{"error": "invalid or missing bearer token"})
+			return
+		}
+	}
+
+	img, err := h.imageProcessor.GetImage(tenantOf(ctx), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	if img.OriginalDeletedAt != nil {
+		ctx.JSON(http.StatusGone, wbgin.H{"error": "original has been removed by retention policy"})
+		return
+	}
+	serveFile(ctx, h.blobs.Originals, img.OriginalKey(), "", h.cfg.CacheConfig.OriginalCacheControl, nil)
+}
+
+// imageURL — адрес ресурса изображения относительно public_base_url
+func (h *ImageHandler) imageURL(img *domain.Image, resource string) string {
+	return strings.TrimSuffix(h.cfg.WebhookConfig.PublicBaseURL, "/") + "/api/image/" + img.ID.String() + "/" + resource
+}
+
 // DeleteImage godoc
 // @Summary Удаление изображения
-// @Description Удаляет изображение из хранилища (помечает, как удаленное)
+// @Description Перемещает изображение в корзину: в течение trash.period его можно восстановить, затем файлы и запись удаляются. С hard=true файлы и запись удаляются сразу, в том числе из корзины
 // @Tags Images
 // @Param id path string true "Image ID"
+// @Param hard query bool false "Delete files and record immediately"
 // @Success 204 {string} string "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Image already deleted"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/image/{id} [delete]
 func (h *ImageHandler) DeleteImage(ctx *wbgin.Context) {
 	id := ctx.Param("id")
-	err := h.imageProcessor.DeleteImage(id)
+	hard := false
+	if raw := ctx.Query("hard"); raw != "" {
+		var err error
+		if hard, err = strconv.ParseBool(raw); err != nil {
+			ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid hard parameter"})
+			return
+		}
+	}
+	var err error
+	if hard {
+		err = h.imageProcessor.HardDeleteImage(tenantOf(ctx), id)
+	} else {
+		err = h.imageProcessor.DeleteImage(tenantOf(ctx), id)
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
 		return
 	}
 	ctx.Status(http.StatusNoContent)
 	ctx.Writer.WriteHeaderNow()
 }
+
+// RestoreImage godoc
+// @Summary Восстановление изображения из корзины
+// @Description Возвращает удалённое изображение в статус, в котором оно было удалено; незавершённая обработка запускается заново. После trash.period отвечает 410
+// @Tags Images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {object} ImageMetaResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Image is not deleted"
+// @Failure 410 {object} ErrorResponse "Trash period has expired"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/restore [post]
+func (h *ImageHandler) RestoreImage(ctx *wbgin.Context) {
+	img, err := h.imageProcessor.RestoreImage(tenantOf(ctx), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, h.metaResponse(img))
+}
+
+// CancelImage godoc
+// @Summary Отмена обработки
+// @Description Отменяет обработку изображения, ожидающего в очереди или обрабатываемого в данный момент
+// @Tags Images
+// @Param id path string true "Image ID"
+// @Success 204 {string} string "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Processing already finished"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/cancel [post]
+func (h *ImageHandler) CancelImage(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+	err := h.imageProcessor.CancelImage(tenantOf(ctx), id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Журнал доставки webhook
+// @Description Возвращает все попытки доставки webhook по изображению
+// @Tags Images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {array} domain.WebhookDelivery
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/webhooks [get]
+func (h *ImageHandler) GetWebhookDeliveries(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+	deliveries, err := h.imageProcessor.GetWebhookDeliveries(tenantOf(ctx), id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, deliveries)
+}
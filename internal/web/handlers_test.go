@@ -2,44 +2,103 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/blob"
 	"imageProcessor/internal/config"
 	"imageProcessor/internal/domain"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/pubsub"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 type MockImageService struct {
 	mock.Mock
 }
 
-func (m *MockImageService) UploadImage(filename, watermark, resize string, mini bool, file multipart.File) (*domain.Image, error) {
-	args := m.Called(filename, watermark, resize, mini, file)
+func (m *MockImageService) UploadImage(tenant, filename string, params domain.ImageParams, file multipart.File) (*domain.Image, error) {
+	args := m.Called(tenant, filename, params, file)
 	return args.Get(0).(*domain.Image), args.Error(1)
 }
 
-func (m *MockImageService) GetImage(id string) (*domain.Image, error) {
-	args := m.Called(id)
+func (m *MockImageService) UploadFromURL(ctx context.Context, tenant, rawURL string, params domain.ImageParams) (*domain.Image, error) {
+	args := m.Called(tenant, rawURL, params)
 	return args.Get(0).(*domain.Image), args.Error(1)
 }
 
-func (m *MockImageService) DeleteImage(id string) error {
-	args := m.Called(id)
+func (m *MockImageService) GetImage(tenant, id string) (*domain.Image, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).(*domain.Image), args.Error(1)
+}
+
+func (m *MockImageService) ListImages(filter domain.ImageFilter) (*domain.ImagePage, error) {
+	args := m.Called(filter)
+	return args.Get(0).(*domain.ImagePage), args.Error(1)
+}
+
+func (m *MockImageService) DeleteImage(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func (m *MockImageService) HardDeleteImage(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func (m *MockImageService) RestoreImage(tenant, id string) (*domain.Image, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).(*domain.Image), args.Error(1)
+}
+
+func (m *MockImageService) CancelImage(tenant, id string) error {
+	args := m.Called(tenant, id)
 	return args.Error(0)
 }
 
+func (m *MockImageService) UploadBatch(tenant string, files []domain.BatchFile) (*domain.Batch, []domain.BatchItemResult, error) {
+	args := m.Called(tenant, files)
+	return args.Get(0).(*domain.Batch), args.Get(1).([]domain.BatchItemResult), args.Error(2)
+}
+
+func (m *MockImageService) GetBatch(tenant, id string) (*domain.BatchProgress, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).(*domain.BatchProgress), args.Error(1)
+}
+
+func (m *MockImageService) GetUsage(tenant string) (*domain.TenantUsage, error) {
+	args := m.Called(tenant)
+	return args.Get(0).(*domain.TenantUsage), args.Error(1)
+}
+
+func (m *MockImageService) CheckQuota(tenant string, size int64) error {
+	args := m.Called(tenant, size)
+	return args.Error(0)
+}
+
+func (m *MockImageService) GetWebhookDeliveries(tenant, id string) ([]domain.WebhookDelivery, error) {
+	args := m.Called(tenant, id)
+	return args.Get(0).([]domain.WebhookDelivery), args.Error(1)
+}
+
 func TestUploadImage_Success(t *testing.T) {
 	mockSvc := new(MockImageService)
 	cfg := &config.AppConfig{
 		StoragePathConfig: config.StoragePathConfig{OutputDir: "/tmp/"},
 	}
-	handler := NewCommentHandler(mockSvc, cfg)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -67,7 +126,7 @@ func TestUploadImage_Success(t *testing.T) {
 	}
 
 	mockSvc.
-		On("UploadImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		On("UploadImage", "", mock.Anything, mock.Anything, mock.Anything).
 		Return(img, nil)
 
 	handler.UploadImage(ctx)
@@ -83,7 +142,7 @@ func TestUploadImage_Success(t *testing.T) {
 func TestUploadImage_ErrorUpload(t *testing.T) {
 	mockSvc := new(MockImageService)
 	cfg := &config.AppConfig{}
-	handler := NewCommentHandler(mockSvc, cfg)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -102,7 +161,7 @@ func TestUploadImage_ErrorUpload(t *testing.T) {
 	ctx.Request = req
 
 	mockSvc.
-		On("UploadImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		On("UploadImage", "", mock.Anything, mock.Anything, mock.Anything).
 		Return((*domain.Image)(nil), errors.New("fail"))
 
 	handler.UploadImage(ctx)
@@ -113,14 +172,14 @@ func TestUploadImage_ErrorUpload(t *testing.T) {
 func TestGetImage_Processing(t *testing.T) {
 	mockSvc := new(MockImageService)
 	cfg := &config.AppConfig{}
-	handler := NewCommentHandler(mockSvc, cfg)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
 
 	img := &domain.Image{
 		Name:   "test.png",
 		Status: domain.Processing,
 	}
 
-	mockSvc.On("GetImage", "1").Return(img, nil)
+	mockSvc.On("GetImage", "", "1").Return(img, nil)
 
 	req := httptest.NewRequest("GET", "/api/image/1", nil)
 	w := httptest.NewRecorder()
@@ -139,7 +198,7 @@ func TestDeleteImage_Success(t *testing.T) {
 		imageProcessor: mockSvc,
 	}
 
-	mockSvc.On("DeleteImage", "1").Return(nil)
+	mockSvc.On("DeleteImage", "", "1").Return(nil)
 
 	req := httptest.NewRequest("DELETE", "/api/image/1", nil)
 	w := httptest.NewRecorder()
@@ -153,7 +212,7 @@ func TestDeleteImage_Success(t *testing.T) {
 	handler.DeleteImage(ctx)
 
 	assert.Equal(t, http.StatusNoContent, w.Code)
-	mockSvc.AssertCalled(t, "DeleteImage", "1")
+	mockSvc.AssertCalled(t, "DeleteImage", "", "1")
 }
 
 func TestDeleteImage_Error(t *testing.T) {
@@ -162,7 +221,7 @@ func TestDeleteImage_Error(t *testing.T) {
 		imageProcessor: mockSvc,
 	}
 
-	mockSvc.On("DeleteImage", "1").Return(errors.New("fail"))
+	mockSvc.On("DeleteImage", "", "1").Return(errors.New("fail"))
 
 	req := httptest.NewRequest("DELETE", "/api/image/1", nil)
 	w := httptest.NewRecorder()
@@ -176,7 +235,7 @@ func TestDeleteImage_Error(t *testing.T) {
 	handler.DeleteImage(ctx)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	mockSvc.AssertCalled(t, "DeleteImage", "1")
+	mockSvc.AssertCalled(t, "DeleteImage", "", "1")
 }
 
 func TestUploadImage_Errors(t *testing.T) {
@@ -228,7 +287,7 @@ func TestUploadImage_Errors(t *testing.T) {
 		ctx.Request = httptest.NewRequest("POST", "/api/upload", body)
 		ctx.Request.Header.Set("Content-Type", writer.FormDataContentType())
 
-		mockSvc.On("UploadImage", mock.Anything, "WM", "500x500", true, mock.Anything).
+		mockSvc.On("UploadImage", "", mock.Anything, domain.ImageParams{Watermark: "WM", Resize: "500x500", Mini: true}, mock.Anything).
 			Return((*domain.Image)(nil), errors.New("fail"))
 
 		handler.UploadImage(ctx)
@@ -236,3 +295,411 @@ func TestUploadImage_Errors(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 	})
 }
+
+func TestUploadImage_Rejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *domain.UploadError
+		code int
+	}{
+		{"mismatch", &domain.UploadError{Code: domain.CodeFormatMismatch, Message: "file extension .png does not match content (jpeg)", DetectedFormat: "jpeg"}, http.StatusBadRequest},
+		{"not an image", &domain.UploadError{Code: domain.CodeUnsupportedMedia, Message: "file content is not a supported image"}, http.StatusUnsupportedMediaType},
+		{"invalid params", &domain.UploadError{Code: domain.CodeInvalidParams, Message: "resize width must be a positive integer"}, http.StatusBadRequest},
+		{"quota", &domain.UploadError{Code: domain.CodeQuotaExceeded, Message: "storage quota exceeded: 90 of 100 bytes used"}, http.StatusInsufficientStorage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+			mockSvc.On("UploadImage", "", "test.png", mock.Anything, mock.Anything).Return((*domain.Image)(nil), tt.err)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "test.png")
+			_, _ = part.Write([]byte("data"))
+			_ = writer.Close()
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("POST", "/api/upload", body)
+			ctx.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			handler.UploadImage(ctx)
+
+			assert.Equal(t, tt.code, w.Code)
+			var resp UploadErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, string(tt.err.Code), resp.Code)
+			assert.Equal(t, tt.err.Message, resp.Error)
+			assert.Equal(t, tt.err.DetectedFormat, resp.DetectedFormat)
+		})
+	}
+}
+
+func TestDeleteImage_Conflict(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := &ImageHandler{
+		imageProcessor: mockSvc,
+	}
+
+	mockSvc.On("DeleteImage", "", "1").Return(&domain.TransitionError{From: domain.Deleted, To: domain.Deleted})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("DELETE", "/api/image/1", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.DeleteImage(ctx)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeleteImage_Hard(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := &ImageHandler{
+		imageProcessor: mockSvc,
+	}
+
+	mockSvc.On("HardDeleteImage", "", "1").Return(nil)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("DELETE", "/api/image/1?hard=true", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.DeleteImage(ctx)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockSvc.AssertNotCalled(t, "DeleteImage", mock.Anything, mock.Anything)
+}
+
+func TestDeleteImage_InvalidHard(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := &ImageHandler{
+		imageProcessor: mockSvc,
+	}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("DELETE", "/api/image/1?hard=maybe", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.DeleteImage(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockSvc.AssertNotCalled(t, "DeleteImage", mock.Anything, mock.Anything)
+	mockSvc.AssertNotCalled(t, "HardDeleteImage", mock.Anything, mock.Anything)
+}
+
+func TestRestoreImage(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	id := uuid.New()
+	mockSvc.On("RestoreImage", "", id.String()).Return(&domain.Image{ID: id, Status: domain.Created}, nil)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("POST", "/api/image/"+id.String()+"/restore", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+	handler.RestoreImage(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp ImageMetaResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, domain.Created, resp.Status)
+	assert.Empty(t, resp.Links.File)
+}
+
+func TestRestoreImage_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"not deleted", domain.ErrNotInTrash, http.StatusConflict},
+		{"expired", domain.ErrTrashExpired, http.StatusGone},
+		{"not found", domain.ErrImageNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			handler := &ImageHandler{
+				imageProcessor: mockSvc,
+			}
+			mockSvc.On("RestoreImage", "", "1").Return((*domain.Image)(nil), tt.err)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("POST", "/api/image/1/restore", nil)
+			ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+			handler.RestoreImage(ctx)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestGetImage_NotFound(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	mockSvc.On("GetImage", "", "1").Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/image/1", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.GetImage(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetWebhookDeliveries_Success(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	deliveries := []domain.WebhookDelivery{{Status: domain.DeliveryPending, Attempts: 2}}
+	mockSvc.On("GetWebhookDeliveries", "", "1").Return(deliveries, nil)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/image/1/webhooks", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	handler.GetWebhookDeliveries(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp []domain.WebhookDelivery
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp, 1)
+	assert.Equal(t, 2, resp[0].Attempts)
+}
+
+func TestCancelImage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"success", nil, http.StatusNoContent},
+		{"already processed", &domain.TransitionError{From: domain.Processed, To: domain.Cancelled}, http.StatusConflict},
+		{"not found", domain.ErrImageNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+			mockSvc.On("CancelImage", "", "1").Return(tt.err)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("POST", "/api/image/1/cancel", nil)
+			ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+
+			handler.CancelImage(ctx)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestUploadImage_PassesPriority(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.png")
+	_, _ = part.Write([]byte("data"))
+	_ = writer.WriteField("priority", "bulk")
+	_ = writer.Close()
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("POST", "/api/upload", body)
+	ctx.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	mockSvc.On("UploadImage", "", "test.png", domain.ImageParams{Priority: "bulk"}, mock.Anything).
+		Return(&domain.Image{Name: "test.png", Status: domain.Created, Priority: domain.PriorityBulk}, nil)
+
+	handler.UploadImage(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestUploadImageByURL(t *testing.T) {
+	img := &domain.Image{ID: uuid.New(), Name: "a.png", Status: domain.Created}
+	tests := []struct {
+		name string
+		body string
+		img  *domain.Image
+		err  error
+		code int
+	}{
+		{"success", `{"url":"https://cdn.example.com/a.png","resize":"10x10","mini":true}`, img, nil, http.StatusOK},
+		{"missing url", `{"resize":"10x10"}`, nil, nil, http.StatusBadRequest},
+		{"forbidden address", `{"url":"http://169.254.169.254/"}`, nil, fetcher.ErrForbiddenAddress, http.StatusBadRequest},
+		{"too large", `{"url":"https://cdn.example.com/a.png"}`, nil, fetcher.ErrTooLarge, http.StatusRequestEntityTooLarge},
+		{"not an image", `{"url":"https://cdn.example.com/a.png"}`, nil, fetcher.ErrUnsupportedContentType, http.StatusUnsupportedMediaType},
+		{"upstream failure", `{"url":"https://cdn.example.com/a.png"}`, nil, fmt.Errorf("%w: status 500", fetcher.ErrUpstream), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+			mockSvc.On("UploadFromURL", "", mock.Anything, mock.Anything).Return(tt.img, tt.err)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("POST", "/api/upload/url", strings.NewReader(tt.body))
+			ctx.Request.Header.Set("Content-Type", "application/json")
+
+			handler.UploadImageByURL(ctx)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				mockSvc.AssertCalled(t, "UploadFromURL", "", "https://cdn.example.com/a.png", domain.ImageParams{Resize: "10x10", Mini: true})
+			}
+		})
+	}
+}
+
+func TestUploadImage_BodyTooLarge(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withConfig(func(cfg *config.AppConfig) {
+		cfg.UploadConfig = config.UploadConfig{MaxBytes: 1024}
+	}))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "big.png")
+	_, _ = part.Write(bytes.Repeat([]byte("x"), 3<<20))
+	_ = writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var resp UploadErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, string(domain.CodeFileTooLarge), resp.Code)
+	mockSvc.AssertNotCalled(t, "UploadImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetImageMeta(t *testing.T) {
+	mockSvc := new(MockImageService)
+	cfg := &config.AppConfig{WebhookConfig: config.WebhookConfig{PublicBaseURL: "http://img.local/"}}
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+	id := uuid.New()
+
+	tests := []struct {
+		name   string
+		status domain.StatusType
+		file   string
+	}{
+		{"processing", domain.Processing, ""},
+		{"processed", domain.Processed, "http://img.local/api/image/" + id.String() + "/file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &domain.Image{
+				ID:     id,
+				Status: tt.status,
+				Format: "png",
+				Resize: &domain.Resize{Width: 100, Height: 100},
+				Source: &domain.FileInfo{Width: 400, Height: 300, Size: 1024},
+			}
+			mockSvc.On("GetImage", "", id.String()).Return(img, nil).Once()
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("GET", "/api/image/"+id.String()+"/meta", nil)
+			ctx.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+			handler.GetImageMeta(ctx)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp ImageMetaResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.status, resp.Status)
+			assert.Equal(t, img.Source, resp.Source)
+			assert.Equal(t, []string{"resize"}, resp.Operations)
+			assert.Equal(t, "http://img.local/api/image/"+id.String()+"/meta", resp.Links.Meta)
+			assert.Equal(t, tt.file, resp.Links.File)
+		})
+	}
+}
+
+func TestGetImageFile(t *testing.T) {
+	outDir := t.TempDir() + "/"
+	mockSvc := new(MockImageService)
+	cfg := &config.AppConfig{StoragePathConfig: config.StoragePathConfig{OutputDir: outDir}}
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+	assert.NoError(t, os.WriteFile(outDir+"done.png", []byte("processed"), 0644))
+
+	mockSvc.On("GetImage", "", "1").Return(&domain.Image{Name: "done.png", Status: domain.Processed}, nil)
+	mockSvc.On("GetImage", "", "2").Return(&domain.Image{Name: "wip.png", Status: domain.Processing}, nil)
+	mockSvc.On("GetImage", "", "3").Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/api/image/"+id+"/file", nil)
+		ctx.Params = gin.Params{{Key: "id", Value: id}}
+		handler.GetImageFile(ctx)
+		return w
+	}
+
+	w := serve("1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "processed", w.Body.String())
+
+	w = serve("2")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error":"image is not processed","status":"processing"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve("3").Code)
+}
+
+func TestGetImageOriginal(t *testing.T) {
+	inDir := t.TempDir() + "/"
+	assert.NoError(t, os.WriteFile(inDir+"src.png", []byte("original"), 0644))
+	removed := time.Now()
+
+	mockSvc := new(MockImageService)
+	mockSvc.On("GetImage", "", "1").Return(&domain.Image{Name: "src.png", Status: domain.Processed}, nil)
+	mockSvc.On("GetImage", "", "2").Return(&domain.Image{Name: "old.png", Status: domain.Processed, OriginalDeletedAt: &removed}, nil)
+
+	serve := func(token, auth, id string) *httptest.ResponseRecorder {
+		cfg := &config.AppConfig{
+			StoragePathConfig: config.StoragePathConfig{InputDir: inDir},
+			OriginalsConfig:   config.OriginalsConfig{Token: token},
+		}
+		handler := NewCommentHandler(mockSvc, pubsub.NewHub(), blob.NewLocalStores(cfg), cfg)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/api/image/"+id+"/original", nil)
+		if auth != "" {
+			ctx.Request.Header.Set("Authorization", auth)
+		}
+		ctx.Params = gin.Params{{Key: "id", Value: id}}
+		handler.GetImageOriginal(ctx)
+		return w
+	}
+
+	w := serve("secret", "Bearer secret", "1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "original", w.Body.String())
+
+	assert.Equal(t, http.StatusGone, serve("secret", "Bearer secret", "2").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("secret", "Bearer wrong", "1").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("secret", "", "1").Code)
+	assert.Equal(t, http.StatusForbidden, serve("", "Bearer ", "1").Code)
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"context"
+	"errors"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/oidc"
+	"net/http"
+	"strings"
+)
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*domain.Principal, error)
+}
+
+// BearerJWT принимает JWT провайдера единого входа из Authorization: Bearer и определяет по нему арендатора
+// и права. Остальные токены (ключи API, TENANT_TOKENS) и запросы без токена проверяет authenticate
+func BearerJWT(verifier TokenVerifier) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || !oidc.LooksLikeJWT(token) {
+			ctx.Next()
+			return
+		}
+		principal, err := verifier.Verify(ctx.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, oidc.ErrInvalidToken) {
+				wbzlog.Logger.Debug().Err(err).Msg("Rejected bearer JWT")
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, wbgin.H{"error": "invalid or missing bearer token"})
+				return
+			}
+			wbzlog.Logger.Error().Err(err).Msg("Failed to verify bearer JWT")
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, wbgin.H{"error": err.Error()})
+			return
+		}
+		ctx.Set(principalKey, principal)
+		ctx.Next()
+	}
+}
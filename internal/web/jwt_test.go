@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/oidc"
+	"net/http"
+	"testing"
+)
+
+type MockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *MockTokenVerifier) Verify(ctx context.Context, token string) (*domain.Principal, error) {
+	args := m.Called(token)
+	return args.Get(0).(*domain.Principal), args.Error(1)
+}
+
+func TestBearerJWT(t *testing.T) {
+	const (
+		reader  = "eyJhbGciOiJSUzI1NiJ9.reader.sig"
+		writer  = "eyJhbGciOiJSUzI1NiJ9.writer.sig"
+		expired = "eyJhbGciOiJSUzI1NiJ9.expired.sig"
+		outage  = "eyJhbGciOiJSUzI1NiJ9.outage.sig"
+	)
+	mockSvc := new(MockImageService)
+	mockKeys := new(MockKeyProvider)
+	verifier := new(MockTokenVerifier)
+	engine, _ := newTestEngine(t, mockSvc, withKeys(mockKeys), withJWT(verifier))
+
+	verifier.On("Verify", reader).Return(&domain.Principal{Tenant: "acme", Scopes: domain.Scopes{domain.ScopeImagesRead}, Subject: "u1"}, nil)
+	verifier.On("Verify", writer).Return(&domain.Principal{Tenant: "acme", Scopes: domain.Scopes{domain.ScopeImagesWrite}, Subject: "u2"}, nil)
+	verifier.On("Verify", expired).Return((*domain.Principal)(nil), fmt.Errorf("%w: token expired", oidc.ErrInvalidToken))
+	verifier.On("Verify", outage).Return((*domain.Principal)(nil), oidc.ErrKeysUnavailable)
+	mockKeys.On("AuthenticateKey", "ipk_0123456789abcdef").Return(&domain.Principal{Tenant: "acme", Scopes: domain.Scopes{domain.ScopeImagesRead}}, nil)
+	mockSvc.On("GetUsage", "acme").Return(domain.NewTenantUsage(domain.Usage{Tenant: "acme"}, domain.QuotaLimits{}), nil)
+
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/usage", reader, nil).Code)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, "/api/usage", writer, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/usage", expired, nil).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, tenantRequest(engine, http.MethodGet, "/api/usage", outage, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/usage", "", nil).Code)
+	// ключи API продолжают работать рядом с JWT
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/usage", "ipk_0123456789abcdef", nil).Code)
+	verifier.AssertNotCalled(t, "Verify", "ipk_0123456789abcdef")
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"net/http"
+)
+
+type KeyProvider interface {
+	KeyAuthenticator
+	CreateAPIKey(tenant, name string, scopes []string) (*domain.APIKey, string, error)
+	ListAPIKeys(tenant string) ([]domain.APIKey, error)
+	RevokeAPIKey(tenant, id string) error
+}
+
+type KeyHandler struct {
+	keys KeyProvider
+	cfg  *config.AppConfig
+}
+
+func NewKeyHandler(keys KeyProvider, cfg *config.AppConfig) *KeyHandler {
+	return &KeyHandler{
+		keys: keys,
+		cfg:  cfg,
+	}
+}
+
+// CreateKeyRequest — параметры нового ключа API
+type CreateKeyRequest struct {
+	Name   string   `json:"name" binding:"required" example:"ci-uploader"`
+	Scopes []string `json:"scopes" binding:"required" example:"images:read,images:write" enums:"images:read,images:write,images:delete,admin"`
+}
+
+// CreateKeyResponse — выпущенный ключ; key показывается только в этом ответе
+type CreateKeyResponse struct {
+	domain.APIKey
+	Key string `json:"key" example:"ipk_3q2-7w1ZkJ0rQ8xY4mN6bV2cT9aL5eH1uS7dF3gW0pI"`
+}
+
+// keysEnabled отвечает 403, пока аутентификация не включена: без неё ключи не проверяются
+func (h *KeyHandler) keysEnabled(ctx *wbgin.Context) bool {
+	if !h.cfg.AuthConfig.Enabled() {
+		ctx.JSON(http.StatusForbidden, wbgin.H{"error": "api keys require TENANT_TOKENS or OIDC to be configured"})
+		return false
+	}
+	return true
+}
+
+// CreateKey godoc
+// @Summary Выпуск ключа API
+// @Description Создаёт ключ арендатора запроса с указанными правами (images:read, images:write, images:delete, admin). Сам ключ возвращается только в этом ответе, сервис хранит лишь его хэш. Требует права admin
+// @Tags Keys
+// @Accept json
+// @Produce json
+// @Param request body CreateKeyRequest true "Key name and scopes"
+// @Success 201 {object} CreateKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys [post]
+func (h *KeyHandler) CreateKey(ctx *wbgin.Context) {
+	if !h.keysEnabled(ctx) {
+		return
+	}
+	var req CreateKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+	key, secret, err := h.keys.CreateAPIKey(tenantOf(ctx), req.Name, req.Scopes)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, CreateKeyResponse{APIKey: *key, Key: secret})
+}
+
+// ListKeys godoc
+// @Summary Список ключей API
+// @Description Возвращает ключи арендатора запроса, в том числе отозванные, с префиксом и временем последнего использования (с точностью до минуты). Требует права admin
+// @Tags Keys
+// @Produce json
+// @Success 200 {array} domain.APIKey
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys [get]
+func (h *KeyHandler) ListKeys(ctx *wbgin.Context) {
+	if !h.keysEnabled(ctx) {
+		return
+	}
+	keys, err := h.keys.ListAPIKeys(tenantOf(ctx))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeKey godoc
+// @Summary Отзыв ключа API
+// @Description Отзывает ключ арендатора запроса; следующие запросы с ним получают 401. Требует права admin
+// @Tags Keys
+// @Param id path string true "Key ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys/{id} [delete]
+func (h *KeyHandler) RevokeKey(ctx *wbgin.Context) {
+	if !h.keysEnabled(ctx) {
+		return
+	}
+	if err := h.keys.RevokeAPIKey(tenantOf(ctx), ctx.Param("id")); err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
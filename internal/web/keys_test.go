@@ -0,0 +1,105 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockKeyProvider struct {
+	mock.Mock
+}
+
+func (m *MockKeyProvider) AuthenticateKey(secret string) (*domain.Principal, error) {
+	args := m.Called(secret)
+	return args.Get(0).(*domain.Principal), args.Error(1)
+}
+
+func (m *MockKeyProvider) CreateAPIKey(tenant, name string, scopes []string) (*domain.APIKey, string, error) {
+	args := m.Called(tenant, name, scopes)
+	return args.Get(0).(*domain.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockKeyProvider) ListAPIKeys(tenant string) ([]domain.APIKey, error) {
+	args := m.Called(tenant)
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockKeyProvider) RevokeAPIKey(tenant, id string) error {
+	args := m.Called(tenant, id)
+	return args.Error(0)
+}
+
+func newKeyContext(method, body string, tenant string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(method, "/api/keys", bytes.NewBufferString(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Set(principalKey, &domain.Principal{Tenant: tenant, Scopes: domain.Scopes{domain.ScopeAdmin}})
+	return ctx, w
+}
+
+func authConfig() *config.AppConfig {
+	return &config.AppConfig{AuthConfig: config.AuthConfig{Tokens: map[string]string{"tok-acme": "acme"}}}
+}
+
+func TestCreateKey(t *testing.T) {
+	mockKeys := new(MockKeyProvider)
+	handler := NewKeyHandler(mockKeys, authConfig())
+	key := &domain.APIKey{ID: uuid.New(), TenantID: "acme", Name: "ci", Prefix: "ipk_abcdefgh", Hash: "secret-hash", Scopes: domain.Scopes{domain.ScopeImagesRead}}
+	mockKeys.On("CreateAPIKey", "acme", "ci", []string{"images:read"}).Return(key, "ipk_abcdefghijkl", nil)
+
+	ctx, w := newKeyContext(http.MethodPost, `{"name":"ci","scopes":["images:read"]}`, "acme")
+	handler.CreateKey(ctx)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ipk_abcdefghijkl", resp["key"])
+	assert.Equal(t, "ipk_abcdefgh", resp["prefix"])
+	assert.NotContains(t, w.Body.String(), "secret-hash")
+}
+
+func TestCreateKey_InvalidScope(t *testing.T) {
+	mockKeys := new(MockKeyProvider)
+	handler := NewKeyHandler(mockKeys, authConfig())
+	mockKeys.On("CreateAPIKey", "acme", "ci", []string{"images:everything"}).
+		Return((*domain.APIKey)(nil), "", domain.ErrInvalidAPIKey)
+
+	ctx, w := newKeyContext(http.MethodPost, `{"name":"ci","scopes":["images:everything"]}`, "acme")
+	handler.CreateKey(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateKey_AuthDisabled(t *testing.T) {
+	mockKeys := new(MockKeyProvider)
+	handler := NewKeyHandler(mockKeys, &config.AppConfig{})
+
+	ctx, w := newKeyContext(http.MethodPost, `{"name":"ci","scopes":["images:read"]}`, "")
+	handler.CreateKey(ctx)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockKeys.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevokeKey_NotFound(t *testing.T) {
+	mockKeys := new(MockKeyProvider)
+	handler := NewKeyHandler(mockKeys, authConfig())
+	mockKeys.On("RevokeAPIKey", "acme", "1").Return(domain.ErrAPIKeyNotFound)
+
+	ctx, w := newKeyContext(http.MethodDelete, "", "acme")
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+	handler.RevokeKey(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
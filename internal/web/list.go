@@ -0,0 +1,136 @@
+package web
+
+import (
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/domain"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var listStatuses = map[domain.StatusType]bool{
+	domain.Created:    true,
+	domain.Processing: true,
+	domain.Processed:  true,
+	domain.Failed:     true,
+	domain.Cancelled:  true,
+	domain.Deleted:    true,
+}
+
+// ImageListQuery — параметры запроса списка изображений
+type ImageListQuery struct {
+	Status      []string `form:"status"`
+	Format      []string `form:"format"`
+	CreatedFrom string   `form:"created_from"`
+	CreatedTo   string   `form:"created_to"`
+	Tag         string   `form:"tag"`
+	Owner       string   `form:"owner"`
+	Sort        string   `form:"sort"`
+	Limit       int      `form:"limit"`
+	Cursor      string   `form:"cursor"`
+}
+
+// ListImages godoc
+// @Summary Список изображений
+// @Description Возвращает изображения с фильтрами и курсорной пагинацией по (created_at, id). Удалённые изображения возвращаются только при явном status=deleted. Для следующей страницы передайте next_cursor с теми же фильтрами и сортировкой.
+// @Tags Images
+// @Produce json
+// @Param status query []string false "Statuses, repeated or comma-separated" collectionFormat(csv)
+// @Param format query []string false "Formats, repeated or comma-separated (jpg and jpeg are the same)" collectionFormat(csv)
+// @Param created_from query string false "Created at or after, RFC3339"
+// @Param created_to query string false "Created before, RFC3339"
+// @Param tag query string false "Tag the image must have"
+// @Param owner query string false "Owner label"
+// @Param sort query string false "Sort order" Enums(-created_at, created_at) default(-created_at)
+// @Param limit query int false "Page size, max 200" default(50)
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} domain.ImagePage
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/images [get]
+func (h *ImageHandler) ListImages(ctx *wbgin.Context) {
+	var q ImageListQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+	filter, err := q.filter()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+	filter.TenantID = tenantOf(ctx)
+	page, err := h.imageProcessor.ListImages(filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (q ImageListQuery) filter() (domain.ImageFilter, error) {
+	filter := domain.ImageFilter{
+		Formats: splitValues(q.Format),
+		Tag:     q.Tag,
+		Owner:   q.Owner,
+		Limit:   q.Limit,
+	}
+	for _, s := range splitValues(q.Status) {
+		status := domain.StatusType(strings.ToLower(s))
+		if !listStatuses[status] {
+			return filter, fmt.Errorf("unknown status: %s", s)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+	if q.Limit < 0 {
+		return filter, fmt.Errorf("limit must not be negative")
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseTime("created_from", q.CreatedFrom); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = parseTime("created_to", q.CreatedTo); err != nil {
+		return filter, err
+	}
+
+	switch q.Sort {
+	case "", "-created_at":
+	case "created_at":
+		filter.Asc = true
+	default:
+		return filter, fmt.Errorf("unsupported sort: %s, use created_at or -created_at", q.Sort)
+	}
+
+	if q.Cursor != "" {
+		if filter.After, err = domain.DecodeCursor(q.Cursor); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// splitValues принимает как повторяющиеся параметры, так и значения через запятую
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func parseTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be RFC3339, e.g. 2024-01-02T15:04:05Z", name)
+	}
+	return &t, nil
+}
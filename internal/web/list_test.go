@@ -0,0 +1,66 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListImages_Filters(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+	cursor := domain.Cursor{CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockSvc.On("ListImages", mock.MatchedBy(func(f domain.ImageFilter) bool {
+		return assert.ObjectsAreEqual([]domain.StatusType{domain.Processed, domain.Failed}, f.Statuses) &&
+			assert.ObjectsAreEqual([]string{"png", "jpg"}, f.Formats) &&
+			f.CreatedFrom != nil && f.CreatedFrom.Equal(from) && f.CreatedTo == nil &&
+			f.Tag == "catalog" && f.Owner == "partner" && f.Asc && f.Limit == 10 &&
+			f.After != nil && f.After.ID == cursor.ID
+	})).Return(&domain.ImagePage{Items: []domain.Image{}}, nil)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/images?status=processed,failed&format=png&format=jpg"+
+		"&created_from=2024-01-01T00:00:00Z&tag=catalog&owner=partner&sort=created_at&limit=10&cursor="+cursor.Encode(), nil)
+
+	handler.ListImages(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"items":[]}`, w.Body.String())
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListImages_BadRequest(t *testing.T) {
+	tests := map[string]string{
+		"unknown status": "status=done",
+		"bad time":       "created_to=yesterday",
+		"bad sort":       "sort=name",
+		"bad cursor":     "cursor=%21%21",
+		"negative limit": "limit=-1",
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := new(MockImageService)
+			handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest("GET", "/api/images?"+query, nil)
+
+			handler.ListImages(ctx)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockSvc.AssertNotCalled(t, "ListImages", mock.Anything)
+		})
+	}
+}
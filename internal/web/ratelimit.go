@@ -0,0 +1,72 @@
+package web
+
+import (
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/ratelimit"
+	"net/http"
+	"strconv"
+)
+
+type RateLimiter interface {
+	Allow(class ratelimit.Class, client string) (ratelimit.Decision, error)
+	Acquire(client string) (func(), bool)
+}
+
+// clientKey — кого ограничивает лимит: ключ API, пользователь единого входа, арендатор статического токена
+// или, без аутентификации и для подписанных ссылок, IP-адрес
+func clientKey(ctx *wbgin.Context) string {
+	principal := principalOf(ctx)
+	switch {
+	case principal == nil, principal.SignedURL:
+		return "ip:" + ctx.ClientIP()
+	case principal.KeyID != "":
+		return "key:" + principal.KeyID
+	case principal.Subject != "":
+		return "sub:" + principal.Tenant + ":" + principal.Subject
+	default:
+		return "tenant:" + principal.Tenant
+	}
+}
+
+// rateLimit списывает запрос из бюджета класса и сообщает остаток в заголовках RateLimit-*; при исчерпании
+// бюджета — 429 с Retry-After. Если хранилище лимитов недоступно, запрос пропускается
+func rateLimit(limiter RateLimiter, class ratelimit.Class) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		d, err := limiter.Allow(class, clientKey(ctx))
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Str("class", string(class)).Msg("Rate limit check failed, request allowed")
+			ctx.Next()
+			return
+		}
+		if d.Limit.Requests == 0 {
+			ctx.Next()
+			return
+		}
+		ctx.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", d.Limit.Requests, int(d.Limit.Period.Seconds()), int(d.Limit.Burst)))
+		ctx.Header("RateLimit-Limit", strconv.Itoa(d.Limit.Requests))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(int(d.Reset.Seconds())))
+		if !d.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(int(d.RetryAfter.Seconds())))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, wbgin.H{"error": "rate limit exceeded for " + string(class) + " requests"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// limitUploads ограничивает число одновременных загрузок клиента на реплике
+func limitUploads(limiter RateLimiter) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		release, ok := limiter.Acquire(clientKey(ctx))
+		if !ok {
+			ctx.Header("Retry-After", "1")
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, wbgin.H{"error": "too many concurrent uploads"})
+			return
+		}
+		defer release()
+		ctx.Next()
+	}
+}
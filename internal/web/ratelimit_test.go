@@ -0,0 +1,159 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRateLimitedEngine(t *testing.T, mockSvc *MockImageService, rl config.RateLimitConfig) *wbgin.Engine {
+	t.Helper()
+	engine, _ := newTestEngine(t, mockSvc, withConfig(func(cfg *config.AppConfig) { cfg.RateLimitConfig = rl }))
+	return engine
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	id := uuid.New().String()
+	mockSvc := new(MockImageService)
+	engine := newRateLimitedEngine(t, mockSvc, config.RateLimitConfig{
+		Read:   config.RateBudget{Requests: 60, Period: time.Minute, Burst: 2},
+		Delete: config.RateBudget{Requests: 1, Period: time.Minute},
+	})
+	mockSvc.On("GetImage", "", id).Return(&domain.Image{ID: uuid.MustParse(id), Status: domain.Processing}, nil)
+	mockSvc.On("DeleteImage", "", id).Return(nil)
+
+	w := tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "60;w=60;burst=2", w.Header().Get("RateLimit-Policy"))
+
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil).Code)
+	w = tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	// бюджет удаления отдельный от чтения
+	assert.Equal(t, http.StatusNoContent, tenantRequest(engine, http.MethodDelete, "/api/image/"+id, "", nil).Code)
+	w = tenantRequest(engine, http.MethodDelete, "/api/image/"+id, "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	mockSvc.AssertNumberOfCalls(t, "DeleteImage", 1)
+}
+
+func TestRateLimit_WriteBudget(t *testing.T) {
+	id := uuid.New().String()
+	mockSvc := new(MockImageService)
+	engine := newRateLimitedEngine(t, mockSvc, config.RateLimitConfig{
+		Read:  config.RateBudget{Requests: 1, Period: time.Minute},
+		Write: config.RateBudget{Requests: 2, Period: time.Minute},
+	})
+	mockSvc.On("GetImage", "", id).Return(&domain.Image{ID: uuid.MustParse(id), Status: domain.Processing}, nil)
+	mockSvc.On("CancelImage", "", id).Return(nil)
+
+	// исчерпанный бюджет чтения не мешает отмене, и наоборот
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil).Code)
+	w := tenantRequest(engine, http.MethodPost, "/api/image/"+id+"/cancel", "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	// отмена tus-загрузки тратит тот же бюджет
+	w = tenantRequest(engine, http.MethodDelete, "/api/tus/"+uuid.New().String(), "", map[string]string{"Tus-Resumable": TusVersion})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusTooManyRequests, tenantRequest(engine, http.MethodPost, "/api/image/"+id+"/cancel", "", nil).Code)
+	mockSvc.AssertNumberOfCalls(t, "CancelImage", 1)
+}
+
+func TestRateLimit_SpoofedForwardedFor(t *testing.T) {
+	id := uuid.New().String()
+	budget := config.RateLimitConfig{Read: config.RateBudget{Requests: 1, Period: time.Minute}}
+	mockSvc := new(MockImageService)
+	mockSvc.On("GetImage", "", id).Return(&domain.Image{ID: uuid.MustParse(id), Status: domain.Processing}, nil)
+	read := func(engine http.Handler, forwardedFor string) int {
+		return tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", map[string]string{"X-Forwarded-For": forwardedFor}).Code
+	}
+
+	// без доверенных прокси новый X-Forwarded-For не даёт нового бюджета
+	engine := newRateLimitedEngine(t, mockSvc, budget)
+	assert.Equal(t, http.StatusOK, read(engine, "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, read(engine, "203.0.113.2"))
+
+	// за доверенным прокси (httptest подключается с 192.0.2.1) клиенты различаются по заголовку
+	engine, _ = newTestEngine(t, mockSvc, withConfig(func(cfg *config.AppConfig) {
+		cfg.RateLimitConfig = budget
+		cfg.ServerConfig.TrustedProxies = []string{"192.0.2.1"}
+	}))
+	assert.Equal(t, http.StatusOK, read(engine, "203.0.113.1"))
+	assert.Equal(t, http.StatusOK, read(engine, "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, read(engine, "203.0.113.2"))
+}
+
+func TestRateLimit_UploadBudget(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine := newRateLimitedEngine(t, mockSvc, config.RateLimitConfig{
+		Upload: config.RateBudget{Requests: 1, Period: time.Hour},
+	})
+
+	// тело некорректно, но запрос всё равно тратит бюджет
+	assert.Equal(t, http.StatusBadRequest, tenantRequest(engine, http.MethodPost, "/api/upload/url", "", nil).Code)
+	w := tenantRequest(engine, http.MethodPost, "/api/upload/url", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+	w = tenantRequest(engine, http.MethodPost, "/api/tus/", "", map[string]string{"Tus-Resumable": TusVersion, "Upload-Length": "5"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	mockSvc.AssertNotCalled(t, "UploadFromURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLimitUploads(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), &config.AppConfig{RateLimitConfig: config.RateLimitConfig{UploadConcurrency: 1}})
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	entered := make(chan struct{})
+	finish := make(chan struct{})
+	engine.POST("/upload", limitUploads(limiter), func(ctx *wbgin.Context) {
+		close(entered)
+		<-finish
+		ctx.Status(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		done <- tenantRequest(engine, http.MethodPost, "/upload", "", nil).Code
+	}()
+	<-entered
+	w := tenantRequest(engine, http.MethodPost, "/upload", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	close(finish)
+	assert.Equal(t, http.StatusOK, <-done)
+
+	_, ok := limiter.Acquire("ip:192.0.2.1")
+	assert.True(t, ok, "slot is released after the upload")
+}
+
+func TestClientKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "ip:192.0.2.1", clientKey(ctx))
+
+	ctx.Set(principalKey, &domain.Principal{Tenant: "acme"})
+	assert.Equal(t, "tenant:acme", clientKey(ctx))
+	ctx.Set(principalKey, &domain.Principal{Tenant: "acme", Subject: "u1"})
+	assert.Equal(t, "sub:acme:u1", clientKey(ctx))
+	ctx.Set(principalKey, &domain.Principal{Tenant: "acme", KeyID: "k1"})
+	assert.Equal(t, "key:k1", clientKey(ctx))
+	ctx.Set(principalKey, &domain.Principal{Tenant: "acme", SignedURL: true})
+	assert.Equal(t, "ip:192.0.2.1", clientKey(ctx))
+}
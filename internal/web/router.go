@@ -4,16 +4,78 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 	wbgin "github.com/wb-go/wbf/ginext"
 	_ "imageProcessor/docs"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/ratelimit"
 )
 
-func RegisterRoutes(engine *wbgin.Engine, handler *ImageHandler) {
+// uploadBodyLimit — предел тела multipart-запроса с files файлами, с запасом на поля формы
+func uploadBodyLimit(maxBytes int64, files int) int64 {
+	if maxBytes <= 0 {
+		return 0
+	}
+	if files < 1 {
+		files = 1
+	}
+	return maxBytes*int64(files) + 1<<20
+}
+
+func RegisterRoutes(engine *wbgin.Engine, handler *ImageHandler, tusHandler *TusHandler, keyHandler *KeyHandler, signedURLHandler *SignedURLHandler, limiter RateLimiter) {
 	api := engine.Group("/api")
 	{
-		api.POST("/upload", handler.UploadImage)
-		api.GET("/image/:id", handler.GetImage)
-		api.DELETE("/image/:id", handler.DeleteImage)
 		api.GET("/swagger/*any", func(c *wbgin.Context) {
 			httpSwagger.WrapHandler(c.Writer, c.Request)
 		})
+		// документация открыта, остальные маршруты, зарегистрированные ниже, определяют арендатора запроса:
+		// по подписи ссылки или по токену
+		api.Use(verifySignedURL(signedURLHandler.signer))
+		api.Use(authenticate(handler.cfg.AuthConfig, keyHandler.keys))
+
+		// права проверяются до лимитов, чтобы запрещённые запросы не тратили бюджет клиента
+		read := []wbgin.HandlerFunc{requireScope(domain.ScopeImagesRead), rateLimit(limiter, ratelimit.ClassRead)}
+		readFile := []wbgin.HandlerFunc{requireScope(domain.ScopeImagesRead), requireSignature(handler.cfg.SignedURLConfig), rateLimit(limiter, ratelimit.ClassRead)}
+		write := []wbgin.HandlerFunc{requireScope(domain.ScopeImagesWrite), rateLimit(limiter, ratelimit.ClassWrite)}
+		upload := []wbgin.HandlerFunc{requireScope(domain.ScopeImagesWrite), rateLimit(limiter, ratelimit.ClassUpload), limitUploads(limiter)}
+		del := []wbgin.HandlerFunc{requireScope(domain.ScopeImagesDelete), rateLimit(limiter, ratelimit.ClassDelete)}
+		admin := []wbgin.HandlerFunc{requireScope(domain.ScopeAdmin), rateLimit(limiter, ratelimit.ClassRead)}
+
+		limits := handler.cfg.UploadConfig
+		api.POST("/upload", chain(upload, limitBody(uploadBodyLimit(limits.MaxBytes, 1)), handler.UploadImage)...)
+		api.POST("/upload/batch", chain(upload, limitBody(uploadBodyLimit(limits.MaxBytes, handler.cfg.BatchConfig.MaxFiles)), handler.UploadBatch)...)
+		api.POST("/upload/url", chain(upload, handler.UploadImageByURL)...)
+		api.GET("/batch/:id", chain(read, handler.GetBatch)...)
+		api.GET("/images", chain(read, handler.ListImages)...)
+		api.GET("/usage", chain(read, handler.GetUsage)...)
+		api.GET("/image/:id", chain(readFile, handler.GetImage)...)
+		api.GET("/image/:id/meta", chain(read, handler.GetImageMeta)...)
+		api.GET("/image/:id/file", chain(readFile, handler.GetImageFile)...)
+		api.HEAD("/image/:id/file", chain(readFile, handler.GetImageFile)...)
+		api.POST("/image/:id/signed-url", chain(read, signedURLHandler.CreateSignedURL)...)
+		api.GET("/image/:id/original", chain(read, handler.GetImageOriginal)...)
+		api.DELETE("/image/:id", chain(del, handler.DeleteImage)...)
+		api.POST("/image/:id/restore", chain(del, handler.RestoreImage)...)
+		api.POST("/image/:id/cancel", chain(write, handler.CancelImage)...)
+		api.GET("/image/:id/webhooks", chain(read, handler.GetWebhookDeliveries)...)
+		api.GET("/image/:id/events", chain(read, handler.StreamImageEvents)...)
+		api.GET("/ws", chain(read, handler.StatusWebSocket)...)
+		api.POST("/stream-ticket", chain(read, signedURLHandler.CreateStreamTicket)...)
+
+		// создание tus-загрузки тратит бюджет загрузок, куски файла ограничены только числом одновременных загрузок
+		uploads := api.Group("/tus", requireScope(domain.ScopeImagesWrite), tusHandler.Resumable)
+		uploads.OPTIONS("/", tusHandler.Options)
+		uploads.POST("/", rateLimit(limiter, ratelimit.ClassUpload), tusHandler.CreateUpload)
+		uploads.OPTIONS("/:id", tusHandler.Options)
+		uploads.HEAD("/:id", rateLimit(limiter, ratelimit.ClassRead), tusHandler.UploadOffset)
+		uploads.PATCH("/:id", limitUploads(limiter), tusHandler.PatchUpload)
+		uploads.DELETE("/:id", rateLimit(limiter, ratelimit.ClassWrite), tusHandler.TerminateUpload)
+
+		keys := api.Group("/keys", admin...)
+		keys.POST("", keyHandler.CreateKey)
+		keys.GET("", keyHandler.ListKeys)
+		keys.DELETE("/:id", keyHandler.RevokeKey)
 	}
 }
+
+// chain — обработчики маршрута после общих middleware группы маршрутов
+func chain(middleware []wbgin.HandlerFunc, handlers ...wbgin.HandlerFunc) []wbgin.HandlerFunc {
+	return append(append([]wbgin.HandlerFunc{}, middleware...), handlers...)
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/urlsign"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// варианты изображения, которые отдаёт GET /api/image/{id}
+const (
+	variantProcessed = "processed"
+	variantOriginal  = "original"
+)
+
+// streamTicketTTL — билет нужен только для открытия потока, открытый поток живёт дольше билета
+const streamTicketTTL = time.Minute
+
+// streamRoutes — потоки статусов, которые открываются по билету: браузер не передаёт в них Authorization
+var streamRoutes = map[string]bool{
+	"/api/ws":               true,
+	"/api/image/:id/events": true,
+}
+
+type URLSigner interface {
+	Sign(link urlsign.Link) (url.Values, error)
+	Verify(imageID string, query url.Values, clientIP string) (*urlsign.Link, error)
+	SignTicket(tenant string, expires time.Time) (url.Values, error)
+	VerifyTicket(query url.Values) (string, error)
+}
+
+type SignedURLHandler struct {
+	images ImageProcessorProvider
+	signer URLSigner
+	cfg    *config.AppConfig
+}
+
+func NewSignedURLHandler(images ImageProcessorProvider, signer URLSigner, cfg *config.AppConfig) *SignedURLHandler {
+	return &SignedURLHandler{
+		images: images,
+		signer: signer,
+		cfg:    cfg,
+	}
+}
+
+// SignedURLRequest — ограничения подписанной ссылки; пустое тело выпускает ссылку на результат обработки
+// со сроком signed_urls.default_ttl
+type SignedURLRequest struct {
+	TTL     int    `json:"ttl" example:"900" description:"Срок действия в секундах, не больше signed_urls.max_ttl"`
+	Variant string `json:"variant" example:"processed" enums:"processed,original"`
+	IP      string `json:"ip" example:"203.0.113.7" description:"Адрес клиента, которому выдаётся ссылка"`
+}
+
+// SignedURLResponse — подписанная ссылка и время, до которого она действует
+type SignedURLResponse struct {
+	URL       string    `json:"url" example:"http://localhost:8080/api/image/123e4567-e89b-12d3-a456-426614174000?expires=1767225600&kid=2026&sig=Q2hhbmdlTWU"`
+	ExpiresAt time.Time `json:"expires_at" example:"2026-01-01T00:00:00Z"`
+}
+
+// CreateSignedURL godoc
+// @Summary Подписанная ссылка на изображение
+// @Description Выпускает ссылку на GET /api/image/{id}, которая открывается без токена до истечения срока. Ссылку можно ограничить вариантом (processed — результат обработки, original — исходник, требует аутентификации арендатора) и IP-адресом клиента. Требует настроенных URL_SIGNING_KEYS и права images:read
+// @Tags Images
+// @Accept json
+// @Produce json
+// @Param id path string true "Image ID"
+// @Param request body SignedURLRequest false "Link restrictions"
+// @Success 200 {object} SignedURLResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/image/{id}/signed-url [post]
+func (h *SignedURLHandler) CreateSignedURL(ctx *wbgin.Context) {
+	cfg := h.cfg.SignedURLConfig
+	if !cfg.Enabled() {
+		ctx.JSON(http.StatusForbidden, wbgin.H{"error": "signed urls require URL_SIGNING_KEYS to be configured"})
+		return
+	}
+	var req SignedURLRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := cfg.DefaultTTL
+	if req.TTL != 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	if ttl <= 0 || ttl > cfg.MaxTTL {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": fmt.Sprintf("ttl must be between 1 and %d seconds", int(cfg.MaxTTL.Seconds()))})
+		return
+	}
+	switch req.Variant {
+	case "", variantProcessed:
+	case variantOriginal:
+		// без аутентификации ссылку может выпустить любой, а исходник без неё закрыт ORIGINALS_TOKEN
+		if !authenticated(ctx) {
+			ctx.JSON(http.StatusForbidden, wbgin.H{"error": "signed urls to originals require tenant authentication"})
+			return
+		}
+	default:
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "unknown variant: " + req.Variant})
+		return
+	}
+	if req.IP != "" {
+		ip := net.ParseIP(req.IP)
+		if ip == nil {
+			ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid ip: " + req.IP})
+			return
+		}
+		req.IP = ip.String()
+	}
+
+	tenant := tenantOf(ctx)
+	img, err := h.images.GetImage(tenant, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	query, err := h.signer.Sign(urlsign.Link{
+		ImageID: img.ID.String(),
+		Tenant:  tenant,
+		Variant: req.Variant,
+		IP:      req.IP,
+		Expires: expires,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, SignedURLResponse{
+		URL:       strings.TrimSuffix(h.cfg.WebhookConfig.PublicBaseURL, "/") + "/api/image/" + img.ID.String() + "?" + query.Encode(),
+		ExpiresAt: expires.UTC(),
+	})
+}
+
+// StreamTicketResponse — параметры билета, которые добавляются к /api/ws или /api/image/{id}/events
+type StreamTicketResponse struct {
+	Query     string    `json:"query" example:"expires=1767225600&kid=2026&sig=Q2hhbmdlTWU&tenant=acme"`
+	ExpiresAt time.Time `json:"expires_at" example:"2026-01-01T00:00:00Z"`
+}
+
+// CreateStreamTicket godoc
+// @Summary Билет на потоки статусов
+// @Description Выпускает короткоживущий билет для /api/ws и /api/image/{id}/events: браузер не может передать Authorization в WebSocket и EventSource, поэтому билет передаётся параметрами запроса. Билет действует минуту, уже открытый поток живёт дольше. Требует настроенных URL_SIGNING_KEYS и права images:read
+// @Tags Images
+// @Produce json
+// @Success 200 {object} StreamTicketResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/stream-ticket [post]
+func (h *SignedURLHandler) CreateStreamTicket(ctx *wbgin.Context) {
+	if !h.cfg.SignedURLConfig.Enabled() {
+		ctx.JSON(http.StatusForbidden, wbgin.H{"error": "stream tickets require URL_SIGNING_KEYS to be configured"})
+		return
+	}
+	expires := time.Now().Add(streamTicketTTL).Truncate(time.Second)
+	query, err := h.signer.SignTicket(tenantOf(ctx), expires)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, StreamTicketResponse{Query: query.Encode(), ExpiresAt: expires.UTC()})
+}
+
+// verifySignedURL проверяет подпись GET /api/image/{id} и открывает по ней чтение изображения арендатора ссылки,
+// а билет потоков статусов — чтение статусов арендатора билета.
+// Запрос с неверной или просроченной подписью отклоняется, даже если у него есть токен
+func verifySignedURL(signer URLSigner) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		query := ctx.Request.URL.Query()
+		if ctx.Request.Method != http.MethodGet || !urlsign.Signed(query) {
+			ctx.Next()
+			return
+		}
+		if streamRoutes[ctx.FullPath()] {
+			tenant, err := signer.VerifyTicket(query)
+			if err != nil {
+				wbzlog.Logger.Debug().Err(err).Str("path", ctx.FullPath()).Msg("Rejected stream ticket")
+				ctx.AbortWithStatusJSON(http.StatusForbidden, wbgin.H{"error": err.Error()})
+				return
+			}
+			ctx.Set(principalKey, &domain.Principal{Tenant: tenant, Scopes: domain.Scopes{domain.ScopeImagesRead}, SignedURL: true})
+			ctx.Next()
+			return
+		}
+		if ctx.FullPath() != "/api/image/:id" {
+			ctx.Next()
+			return
+		}
+		link, err := signer.Verify(ctx.Param("id"), query, ctx.ClientIP())
+		if err != nil {
+			wbzlog.Logger.Debug().Err(err).Str("image_id", ctx.Param("id")).Msg("Rejected signed url")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, wbgin.H{"error": err.Error()})
+			return
+		}
+		ctx.Set(principalKey, &domain.Principal{Tenant: link.Tenant, Scopes: domain.Scopes{domain.ScopeImagesRead}, SignedURL: true})
+		ctx.Next()
+	}
+}
+
+// requireSignature в режиме signed_urls.required отклоняет чтение файла изображения без подписи и без токена
+func requireSignature(cfg config.SignedURLConfig) wbgin.HandlerFunc {
+	return func(ctx *wbgin.Context) {
+		if cfg.Required && !authenticated(ctx) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, wbgin.H{"error": "signed url required"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/urlsign"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSignedURLs включает подпись ссылок ключом k1; required требует подпись у чтений без токена
+func withSignedURLs(required bool) engineOption {
+	return withConfig(func(cfg *config.AppConfig) {
+		cfg.WebhookConfig.PublicBaseURL = "http://img.local/"
+		cfg.SignedURLConfig = config.SignedURLConfig{
+			Keys:       []config.SigningKey{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}},
+			DefaultTTL: 15 * time.Minute,
+			MaxTTL:     time.Hour,
+			Required:   required,
+		}
+	})
+}
+
+// processedImage — обработанное изображение с файлом результата на диске
+func processedImage(t *testing.T, cfg *config.AppConfig, tenant string) *domain.Image {
+	t.Helper()
+	img := &domain.Image{ID: uuid.New(), TenantID: tenant, Name: "done.png", Status: domain.Processed}
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.StoragePathConfig.OutputDir, img.Name), []byte("processed"), 0644))
+	return img
+}
+
+// mintURL выпускает подписанную ссылку и возвращает её путь с параметрами
+func mintURL(t *testing.T, engine http.Handler, id, token, body string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/image/"+id+"/signed-url", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return ""
+	}
+	var resp SignedURLResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, strings.HasPrefix(resp.URL, "http://img.local/api/image/"+id+"?"), resp.URL)
+	u, err := url.Parse(resp.URL)
+	assert.NoError(t, err)
+	return u.RequestURI()
+}
+
+func TestSignedURL(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false))
+	img := processedImage(t, cfg, "")
+	mockSvc.On("GetImage", "", img.ID.String()).Return(img, nil)
+
+	path := mintURL(t, engine, img.ID.String(), "", "")
+	w := tenantRequest(engine, http.MethodGet, path, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "processed", w.Body.String())
+
+	tampered := strings.Replace(path, "expires=", "expires=9", 1)
+	w = tenantRequest(engine, http.MethodGet, tampered, "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid url signature")
+
+	// подпись привязана к изображению
+	other := strings.Replace(path, img.ID.String(), uuid.New().String(), 1)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, other, "", nil).Code)
+	// и к варианту
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, path+"&variant=original", "", nil).Code)
+}
+
+func TestSignedURL_BoundToIP(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false))
+	img := processedImage(t, cfg, "")
+	mockSvc.On("GetImage", "", img.ID.String()).Return(img, nil)
+
+	// httptest отправляет запросы с 192.0.2.1
+	path := mintURL(t, engine, img.ID.String(), "", `{"ip":"192.0.2.1","ttl":60}`)
+	assert.NotContains(t, path, "192.0.2.1")
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, path, "", nil).Code)
+
+	path = mintURL(t, engine, img.ID.String(), "", `{"ip":"198.51.100.1"}`)
+	assert.Equal(t, http.StatusForbidden, tenantRequest(engine, http.MethodGet, path, "", nil).Code)
+}
+
+func TestSignedURL_BoundToIP_ForgedForwardedFor(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false))
+	img := processedImage(t, cfg, "")
+	mockSvc.On("GetImage", "", img.ID.String()).Return(img, nil)
+	path := mintURL(t, engine, img.ID.String(), "", `{"ip":"198.51.100.1"}`)
+
+	// без доверенных прокси заголовок не выдаёт запрос с 192.0.2.1 за адрес ссылки
+	for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		w := tenantRequest(engine, http.MethodGet, path, "", map[string]string{header: "198.51.100.1"})
+		assert.Equal(t, http.StatusForbidden, w.Code, header)
+	}
+
+	// адрес из X-Forwarded-For доверенного прокси
+	cfg.ServerConfig.TrustedProxies = []string{"192.0.2.0/24"}
+	assert.NoError(t, engine.SetTrustedProxies(cfg.ServerConfig.TrustedProxies))
+	w := tenantRequest(engine, http.MethodGet, path, "", map[string]string{"X-Forwarded-For": "198.51.100.1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSignedURL_OriginalOfTenant(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false), withTenants())
+	img := &domain.Image{ID: uuid.New(), TenantID: "acme", Name: "src.png", Status: domain.Processed, Source: &domain.FileInfo{Key: "tenants/acme/src.png"}}
+	path := filepath.Join(cfg.StoragePathConfig.InputDir, filepath.FromSlash(img.OriginalKey()))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte("original"), 0644))
+	mockSvc.On("GetImage", "acme", img.ID.String()).Return(img, nil)
+
+	signed := mintURL(t, engine, img.ID.String(), "tok-acme", `{"variant":"original"}`)
+	assert.Contains(t, signed, "tenant=acme")
+	// ссылка открывается без токена
+	w := tenantRequest(engine, http.MethodGet, signed, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "original", w.Body.String())
+
+	// без подписи нужен токен
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(engine, http.MethodGet, "/api/image/"+img.ID.String(), "", nil).Code)
+}
+
+func TestSignedURL_Expired(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false))
+	img := processedImage(t, cfg, "")
+	query, err := urlsign.NewSigner(cfg).Sign(urlsign.Link{ImageID: img.ID.String(), Expires: time.Now().Add(-time.Second)})
+	assert.NoError(t, err)
+
+	w := tenantRequest(engine, http.MethodGet, "/api/image/"+img.ID.String()+"?"+query.Encode(), "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "expired")
+	mockSvc.AssertNotCalled(t, "GetImage", mock.Anything, mock.Anything)
+}
+
+func TestSignedURL_Required(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(true))
+	img := processedImage(t, cfg, "")
+	id := img.ID.String()
+	mockSvc.On("GetImage", "", id).Return(img, nil)
+
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/api/image/" + id},
+		{http.MethodGet, "/api/image/" + id + "/file"},
+		{http.MethodHead, "/api/image/" + id + "/file"},
+	} {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, tenantRequest(engine, r.method, r.path, "", nil).Code)
+		})
+	}
+	assert.Equal(t, http.StatusOK, tenantRequest(engine, http.MethodGet, "/api/image/"+id+"/meta", "", nil).Code)
+
+	path := mintURL(t, engine, id, "", "")
+	w := tenantRequest(engine, http.MethodGet, path, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "processed", w.Body.String())
+}
+
+func TestCreateSignedURL_Errors(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withSignedURLs(false))
+	id := uuid.New().String()
+	mockSvc.On("GetImage", "", id).Return((*domain.Image)(nil), domain.ErrImageNotFound)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"ttl above max", `{"ttl":3601}`, http.StatusBadRequest},
+		{"negative ttl", `{"ttl":-1}`, http.StatusBadRequest},
+		{"unknown variant", `{"variant":"thumb"}`, http.StatusBadRequest},
+		{"invalid ip", `{"ip":"localhost"}`, http.StatusBadRequest},
+		{"malformed body", `{`, http.StatusBadRequest},
+		{"original without tenant auth", `{"variant":"original"}`, http.StatusForbidden},
+		{"unknown image", `{}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/image/"+id+"/signed-url", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestCreateSignedURL_Disabled(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, cfg := newTestEngine(t, mockSvc, withSignedURLs(false))
+	cfg.SignedURLConfig.Keys = nil
+
+	w := tenantRequest(engine, http.MethodPost, "/api/image/"+uuid.New().String()+"/signed-url", "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockSvc.AssertNotCalled(t, "GetImage", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,323 @@
+package web
+
+import (
+	"fmt"
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/tus"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	TusVersion          = "1.0.0"
+	tusExtensions       = "creation,termination,expiration"
+	tusOffsetStreamType = "application/offset+octet-stream"
+	// HeaderImageID — ID изображения, созданного по завершении загрузки
+	HeaderImageID = "X-Image-ID"
+)
+
+type ResumableStore interface {
+	Create(tenant string, length int64, metadata map[string]string) (*tus.Upload, error)
+	Get(id string) (*tus.Upload, error)
+	Lock(id string) (func(), error)
+	Write(id string, offset int64, r io.Reader) (*tus.Upload, error)
+	Open(id string) (*os.File, error)
+	Finish(u *tus.Upload, imageID string) error
+	Terminate(id string) error
+}
+
+// TusHandler реализует протокол tus 1.0.0 (core, creation, termination, expiration);
+// по завершении загрузки файл передаётся в обычный поток UploadImage
+type TusHandler struct {
+	store          ResumableStore
+	imageProcessor ImageProcessorProvider
+	cfg            *config.AppConfig
+}
+
+func NewTusHandler(store ResumableStore, imageProcessor ImageProcessorProvider, cfg *config.AppConfig) *TusHandler {
+	return &TusHandler{
+		store:          store,
+		imageProcessor: imageProcessor,
+		cfg:            cfg,
+	}
+}
+
+// Resumable проверяет версию протокола во всех запросах, кроме OPTIONS
+func (h *TusHandler) Resumable(ctx *wbgin.Context) {
+	ctx.Header("Tus-Resumable", TusVersion)
+	if ctx.Request.Method != http.MethodOptions && ctx.GetHeader("Tus-Resumable") != TusVersion {
+		ctx.Header("Tus-Version", TusVersion)
+		ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, wbgin.H{"error": "unsupported tus version"})
+		return
+	}
+	ctx.Next()
+}
+
+// Options godoc
+// @Summary Возможности tus-сервера
+// @Description Возвращает поддерживаемую версию протокола, расширения и максимальный размер загрузки
+// @Tags Tus
+// @Success 204 {string} string "No Content"
+// @Router /api/tus/ [options]
+func (h *TusHandler) Options(ctx *wbgin.Context) {
+	ctx.Header("Tus-Version", TusVersion)
+	ctx.Header("Tus-Extension", tusExtensions)
+	if h.cfg.TusConfig.MaxSize > 0 {
+		ctx.Header("Tus-Max-Size", strconv.FormatInt(h.cfg.TusConfig.MaxSize, 10))
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// CreateUpload godoc
+// @Summary Создание возобновляемой загрузки (tus)
+// @Description Создаёт загрузку заданного размера. Upload-Metadata: filename (обязательно), resize, mini, watermark, callback_url, priority, tags, owner — значения в base64. Адрес загрузки возвращается в Location.
+// @Tags Tus
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param Upload-Length header int true "Total upload size in bytes"
+// @Param Upload-Metadata header string true "Comma-separated 'key base64value' pairs, filename is required"
+// @Success 201 {string} string "Created, see Location and Upload-Expires headers"
+// @Failure 400 {object} UploadErrorResponse
+// @Failure 412 {object} ErrorResponse "Unsupported tus version"
+// @Failure 413 {object} ErrorResponse "Upload-Length exceeds Tus-Max-Size"
+// @Failure 415 {object} UploadErrorResponse "Unsupported file extension"
+// @Failure 500 {object} ErrorResponse
+// @Failure 507 {object} UploadErrorResponse "Tenant quota exceeded"
+// @Router /api/tus/ [post]
+func (h *TusHandler) CreateUpload(ctx *wbgin.Context) {
+	if ctx.GetHeader("Upload-Defer-Length") != "" {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "Upload-Defer-Length is not supported"})
+		return
+	}
+	length, err := strconv.ParseInt(ctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "Upload-Length must be a positive integer"})
+		return
+	}
+	if max := h.cfg.TusConfig.MaxSize; max > 0 && length > max {
+		ctx.JSON(http.StatusRequestEntityTooLarge, wbgin.H{"error": fmt.Sprintf("upload size %d exceeds maximum %d", length, max)})
+		return
+	}
+	meta, err := tus.ParseMetadata(ctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		return
+	}
+	// проверяем имя и параметры заранее, чтобы не принимать гигабайты, которые потом будут отклонены
+	if err := h.validateMetadata(meta); err != nil {
+		ctx.JSON(errorStatus(err), errorResponse(err))
+		return
+	}
+	// загрузка сверх квоты была бы отклонена только после приёма всего файла
+	if err := h.imageProcessor.CheckQuota(tenantOf(ctx), length); err != nil {
+		ctx.JSON(errorStatus(err), errorResponse(err))
+		return
+	}
+
+	upload, err := h.store.Create(tenantOf(ctx), length, meta)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Location", "/api/tus/"+upload.ID)
+	setExpiresHeader(ctx, upload)
+	ctx.Status(http.StatusCreated)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// UploadOffset godoc
+// @Summary Текущее смещение загрузки (tus)
+// @Description Возвращает количество уже полученных байт в Upload-Offset; после завершения — ID изображения в X-Image-ID
+// @Tags Tus
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param id path string true "Upload ID"
+// @Success 200 {string} string "Upload-Offset and Upload-Length headers"
+// @Failure 404 {string} string "Not Found"
+// @Router /api/tus/{id} [head]
+func (h *TusHandler) UploadOffset(ctx *wbgin.Context) {
+	upload, err := h.upload(ctx, ctx.Param("id"))
+	if err != nil {
+		ctx.Status(errorStatus(err))
+		ctx.Writer.WriteHeaderNow()
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	setUploadHeaders(ctx, upload)
+	ctx.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		ctx.Header("Upload-Metadata", tus.EncodeMetadata(upload.Metadata))
+	}
+	ctx.Status(http.StatusOK)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// PatchUpload godoc
+// @Summary Загрузка части файла (tus)
+// @Description Дописывает тело запроса начиная с Upload-Offset. Когда получен весь файл, изображение ставится на обработку, его ID возвращается в X-Image-ID. Если постановка не удалась, её можно повторить пустым PATCH с конечным смещением.
+// @Tags Tus
+// @Accept application/offset+octet-stream
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Param id path string true "Upload ID"
+// @Success 204 {string} string "New Upload-Offset header"
+// @Failure 400 {object} UploadErrorResponse "Invalid Upload-Offset or the completed file was rejected"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Upload-Offset does not match"
+// @Failure 415 {object} UploadErrorResponse "Wrong Content-Type or the completed file is not a supported image"
+// @Failure 423 {object} ErrorResponse "Upload is being written by another request"
+// @Failure 507 {object} UploadErrorResponse "Tenant quota exceeded"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tus/{id} [patch]
+func (h *TusHandler) PatchUpload(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+	if ctx.ContentType() != tusOffsetStreamType {
+		ctx.JSON(http.StatusUnsupportedMediaType, wbgin.H{"error": "Content-Type must be " + tusOffsetStreamType})
+		return
+	}
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "Upload-Offset must be a non-negative integer"})
+		return
+	}
+
+	if _, err := h.upload(ctx, id); err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	unlock, err := h.store.Lock(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	defer unlock()
+
+	upload, err := h.store.Write(id, offset, ctx.Request.Body)
+	if err != nil {
+		if upload != nil {
+			setUploadHeaders(ctx, upload)
+		}
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	if upload.Complete() && upload.ImageID == "" {
+		if err := h.finish(upload); err != nil {
+			setUploadHeaders(ctx, upload)
+			ctx.JSON(errorStatus(err), errorResponse(err))
+			return
+		}
+	}
+	setUploadHeaders(ctx, upload)
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// TerminateUpload godoc
+// @Summary Отмена загрузки (tus)
+// @Description Удаляет незавершённую загрузку и полученные байты
+// @Tags Tus
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param id path string true "Upload ID"
+// @Success 204 {string} string "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 423 {object} ErrorResponse "Upload is being written by another request"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tus/{id} [delete]
+func (h *TusHandler) TerminateUpload(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+	if _, err := h.upload(ctx, id); err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	unlock, err := h.store.Lock(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	defer unlock()
+
+	if err := h.store.Terminate(id); err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// upload возвращает загрузку арендатора запроса; чужая загрузка неотличима от несуществующей
+func (h *TusHandler) upload(ctx *wbgin.Context, id string) (*tus.Upload, error) {
+	upload, err := h.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Tenant != tenantOf(ctx) {
+		return nil, tus.ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func (h *TusHandler) finish(upload *tus.Upload) error {
+	f, err := h.store.Open(upload.ID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to open completed tus upload")
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	img, err := h.imageProcessor.UploadImage(upload.Tenant, upload.Metadata["filename"], metadataParams(upload.Metadata), f)
+	if err != nil {
+		return err
+	}
+	return h.store.Finish(upload, img.ID.String())
+}
+
+// validateMetadata проверяет параметры и расширение; содержимое проверяется по завершении загрузки
+func (h *TusHandler) validateMetadata(meta map[string]string) error {
+	filename := meta["filename"]
+	if filename == "" {
+		return &domain.UploadError{Code: domain.CodeInvalidParams, Message: "Upload-Metadata must contain filename"}
+	}
+	if err := domain.ValidateParams(metadataParams(meta), h.cfg); err != nil {
+		return err
+	}
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if format != "" && !h.cfg.ImageFormats.SupportedFormats[format] {
+		return &domain.UploadError{Code: domain.CodeUnsupportedMedia, Message: "unsupported format:" + format}
+	}
+	return nil
+}
+
+func metadataParams(meta map[string]string) domain.ImageParams {
+	return domain.ImageParams{
+		Watermark:   meta["watermark"],
+		Resize:      meta["resize"],
+		Mini:        meta["mini"] == "1" || meta["mini"] == "true",
+		CallbackURL: meta["callback_url"],
+		Priority:    meta["priority"],
+		Tags:        domain.ParseTags(meta["tags"]),
+		Owner:       meta["owner"],
+	}
+}
+
+func setUploadHeaders(ctx *wbgin.Context, upload *tus.Upload) {
+	ctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.ImageID != "" {
+		ctx.Header(HeaderImageID, upload.ImageID)
+	}
+	setExpiresHeader(ctx, upload)
+}
+
+// setExpiresHeader сообщает, до какого времени незавершённую загрузку можно продолжить
+func setExpiresHeader(ctx *wbgin.Context, upload *tus.Upload) {
+	if upload.ExpiresAt.IsZero() || upload.Complete() {
+		return
+	}
+	ctx.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"encoding/base64"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	wbgin "github.com/wb-go/wbf/ginext"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTusEngine(t *testing.T, mockSvc *MockImageService) *wbgin.Engine {
+	t.Helper()
+	mockSvc.On("CheckQuota", "", mock.Anything).Return(nil).Maybe()
+	engine, _ := newTestEngine(t, mockSvc, withConfig(func(cfg *config.AppConfig) {
+		cfg.TusConfig = config.TusConfig{MaxSize: 100, Expiration: time.Hour}
+		cfg.UploadConfig = config.UploadConfig{MaxWidth: 10000, MaxHeight: 10000}
+	}))
+	return engine
+}
+
+func tusRequest(engine http.Handler, method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", TusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestTus_FullUpload(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine := newTusEngine(t, mockSvc)
+
+	w := tusRequest(engine, http.MethodPost, "/api/tus/", map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": "filename " + b64("scan.png") + ",resize " + b64("100x100") + ",mini " + b64("1"),
+	}, "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/api/tus/"))
+	assert.Equal(t, TusVersion, w.Header().Get("Tus-Resumable"))
+	expires, err := http.ParseTime(w.Header().Get("Upload-Expires"))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+
+	patch := map[string]string{"Content-Type": tusOffsetStreamType, "Upload-Offset": "0"}
+	w = tusRequest(engine, http.MethodPatch, location, patch, "hello")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	// повтор уже отправленного куска после обрыва
+	w = tusRequest(engine, http.MethodPatch, location, patch, "hello")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = tusRequest(engine, http.MethodHead, location, nil, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "10", w.Header().Get("Upload-Length"))
+
+	img := &domain.Image{ID: uuid.New()}
+	mockSvc.On("UploadImage", "", "scan.png", domain.ImageParams{Resize: "100x100", Mini: true}, mock.MatchedBy(func(f io.Reader) bool {
+		data, _ := io.ReadAll(f)
+		return string(data) == "helloworld"
+	})).Return(img, nil).Once()
+
+	patch["Upload-Offset"] = "5"
+	w = tusRequest(engine, http.MethodPatch, location, patch, "world")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, img.ID.String(), w.Header().Get(HeaderImageID))
+	assert.Empty(t, w.Header().Get("Upload-Expires"))
+
+	w = tusRequest(engine, http.MethodHead, location, nil, "")
+	assert.Equal(t, img.ID.String(), w.Header().Get(HeaderImageID))
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTus_CreateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    int
+	}{
+		{"missing length", map[string]string{"Upload-Metadata": "filename " + b64("a.png")}, http.StatusBadRequest},
+		{"too large", map[string]string{"Upload-Length": "101", "Upload-Metadata": "filename " + b64("a.png")}, http.StatusRequestEntityTooLarge},
+		{"no filename", map[string]string{"Upload-Length": "10"}, http.StatusBadRequest},
+		{"no extension", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename " + b64("scan")}, http.StatusCreated},
+		{"unsupported format", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename " + b64("a.tiff")}, http.StatusUnsupportedMediaType},
+		{"invalid params", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename " + b64("a.png") + ",resize " + b64("big")}, http.StatusBadRequest},
+		{"resize too large", map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename " + b64("a.png") + ",resize " + b64("100000x100000")}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTusEngine(t, new(MockImageService))
+			w := tusRequest(engine, http.MethodPost, "/api/tus/", tt.headers, "")
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestTus_CreateOverQuota(t *testing.T) {
+	mockSvc := new(MockImageService)
+	engine, _ := newTestEngine(t, mockSvc, withConfig(func(cfg *config.AppConfig) {
+		cfg.TusConfig = config.TusConfig{MaxSize: 100, Expiration: time.Hour}
+	}))
+	quotaErr := &domain.UploadError{Code: domain.CodeQuotaExceeded, Message: "storage quota exceeded: 90 of 100 bytes used"}
+	mockSvc.On("CheckQuota", "", int64(50)).Return(quotaErr)
+
+	w := tusRequest(engine, http.MethodPost, "/api/tus/", map[string]string{
+		"Upload-Length":   "50",
+		"Upload-Metadata": "filename " + b64("a.png"),
+	}, "")
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	assert.Contains(t, w.Body.String(), string(domain.CodeQuotaExceeded))
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestTus_ProtocolErrors(t *testing.T) {
+	engine := newTusEngine(t, new(MockImageService))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tus/", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, TusVersion, w.Header().Get("Tus-Version"))
+
+	w = tusRequest(engine, http.MethodOptions, "/api/tus/", nil, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "creation,termination,expiration", w.Header().Get("Tus-Extension"))
+	assert.Equal(t, "100", w.Header().Get("Tus-Max-Size"))
+
+	w = tusRequest(engine, http.MethodHead, "/api/tus/"+uuid.New().String(), nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = tusRequest(engine, http.MethodPost, "/api/tus/", map[string]string{"Upload-Length": "3", "Upload-Metadata": "filename " + b64("a.png")}, "")
+	location := w.Header().Get("Location")
+
+	w = tusRequest(engine, http.MethodPatch, location, map[string]string{"Upload-Offset": "0"}, "abc")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = tusRequest(engine, http.MethodDelete, location, nil, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = tusRequest(engine, http.MethodHead, location, nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
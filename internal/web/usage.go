@@ -0,0 +1,23 @@
+package web
+
+import (
+	wbgin "github.com/wb-go/wbf/ginext"
+	"net/http"
+)
+
+// GetUsage godoc
+// @Summary Использование хранилища
+// @Description Возвращает число изображений, объём исходников и результатов арендатора запроса и его квоты; 0 в квоте — без ограничения. Изображения в корзине учитываются до окончательного удаления
+// @Tags Usage
+// @Produce json
+// @Success 200 {object} domain.TenantUsage
+// @Failure 500 {object} ErrorResponse
+// @Router /api/usage [get]
+func (h *ImageHandler) GetUsage(ctx *wbgin.Context) {
+	usage, err := h.imageProcessor.GetUsage(tenantOf(ctx))
+	if err != nil {
+		ctx.JSON(errorStatus(err), wbgin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/pubsub"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetUsage(t *testing.T) {
+	mockSvc := new(MockImageService)
+	handler := NewCommentHandler(mockSvc, pubsub.NewHub(), nil, &config.AppConfig{})
+
+	usage := domain.NewTenantUsage(domain.Usage{Tenant: "team-a", Images: 2, OriginalBytes: 300, ProcessedBytes: 100}, domain.QuotaLimits{MaxBytes: 1000})
+	mockSvc.On("GetUsage", "team-a").Return(usage, nil)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/api/usage", nil)
+	ctx.Set(principalKey, &domain.Principal{Tenant: "team-a", Scopes: domain.Scopes{domain.ScopeImagesRead}})
+
+	handler.GetUsage(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"tenant":"team-a","images":2,"original_bytes":300,"processed_bytes":100,"bytes":400,
+		"limits":{"max_bytes":1000,"max_images":0}}`, w.Body.String())
+}
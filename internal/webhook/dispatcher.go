@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"imageProcessor/internal/fetcher"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+type DeliveryStorage interface {
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	UpdateWebhookDelivery(d *domain.WebhookDelivery) error
+}
+
+type Dispatcher struct {
+	repo   DeliveryStorage
+	client *http.Client
+	cfg    *config.WebhookConfig
+}
+
+// NewDispatcher создаёт клиента, который не ходит по внутренним адресам (кроме webhook.allowlist) и не следует
+// редиректам: callback_url задаёт клиент API, и без этого сервис можно заставить обращаться к внутренним сервисам
+func NewDispatcher(repo DeliveryStorage, cfg *config.AppConfig) *Dispatcher {
+	timeout := cfg.WebhookConfig.Timeout
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: timeout,
+			// прокси из окружения обошёл бы проверку адресов
+			Transport: &http.Transport{
+				Proxy:               nil,
+				DialContext:         fetcher.GuardedDialer(timeout, cfg.WebhookConfig.Allowlist).DialContext,
+				TLSHandshakeTimeout: timeout,
+			},
+			// ответ 3xx считается неуспешной доставкой
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: &cfg.WebhookConfig,
+	}
+}
+
+// Sign возвращает значение заголовка подписи: sha256=<hex(HMAC-SHA256(secret, body))>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run периодически забирает готовые доставки из хранилища и отправляет их до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	if !d.cfg.Enabled() {
+		// без секрета webhook не принимаются, а оставшиеся доставки нечем подписать
+		wbzlog.Logger.Info().Msg("Webhook dispatcher disabled: WEBHOOK_SECRET is not set")
+		return
+	}
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wbzlog.Logger.Info().Msg("Webhook dispatcher stopping...")
+			return
+		case <-ticker.C:
+			d.DispatchPending(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) DispatchPending(ctx context.Context) {
+	// lease с запасом покрывает таймаут запроса, чтобы доставку не забрала другая реплика
+	deliveries, err := d.repo.ClaimWebhookDeliveries(d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to claim webhook deliveries")
+		return
+	}
+	for i := range deliveries {
+		d.deliver(ctx, &deliveries[i])
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	code, err := d.send(ctx, delivery)
+	now := time.Now().UTC()
+
+	delivery.Attempts++
+	delivery.LastStatusCode = code
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil:
+		delivery.Status = domain.DeliveryDelivered
+		delivery.LastError = ""
+	case delivery.Attempts >= d.cfg.MaxAttempts, errors.Is(err, fetcher.ErrForbiddenAddress):
+		// запрещённый адрес не станет разрешённым при повторе
+		delivery.Status = domain.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Str("delivery_id", delivery.ID.String()).Int("attempt", delivery.Attempts).Msg("webhook delivery failed")
+	}
+
+	if err := d.repo.UpdateWebhookDelivery(delivery); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("failed to update webhook delivery")
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(d.cfg.Secret, delivery.Payload))
+	req.Header.Set(EventHeader, string(delivery.Event))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff — экспоненциальная задержка перед попыткой attempt+1, ограниченная MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStorage struct {
+	pending []domain.WebhookDelivery
+	updated []domain.WebhookDelivery
+}
+
+func (f *fakeStorage) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	claimed := f.pending
+	f.pending = nil
+	return claimed, nil
+}
+
+func (f *fakeStorage) UpdateWebhookDelivery(d *domain.WebhookDelivery) error {
+	f.updated = append(f.updated, *d)
+	return nil
+}
+
+func testConfig() *config.AppConfig {
+	return &config.AppConfig{
+		WebhookConfig: config.WebhookConfig{
+			Secret:         "secret",
+			Allowlist:      []string{"127.0.0.1"},
+			Timeout:        time.Second,
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     3 * time.Second,
+			BatchSize:      10,
+		},
+	}
+}
+
+func newDelivery(t *testing.T, url string) domain.WebhookDelivery {
+	t.Helper()
+	img := &domain.Image{ID: uuid.New(), CallbackURL: url}
+	d, err := domain.NewWebhookDelivery(img, domain.Processed, "http://localhost:8080/api/image/"+img.ID.String())
+	assert.NoError(t, err)
+	return *d
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494", Sign("secret", []byte(`{"a":1}`)))
+	assert.NotEqual(t, Sign("secret", []byte("body")), Sign("other", []byte("body")))
+}
+
+func TestDispatchPending_Delivered(t *testing.T) {
+	var gotSignature, gotEvent string
+	var gotPayload domain.WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		_ = json.Unmarshal(body, &gotPayload)
+		assert.Equal(t, Sign("secret", body), gotSignature)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := newDelivery(t, server.URL)
+	storage := &fakeStorage{pending: []domain.WebhookDelivery{delivery}}
+	d := NewDispatcher(storage, testConfig())
+
+	d.DispatchPending(context.Background())
+
+	assert.Equal(t, "image.processed", gotEvent)
+	assert.Equal(t, delivery.ImageID, gotPayload.ImageID)
+	assert.Equal(t, domain.Processed, gotPayload.Status)
+	assert.NotEmpty(t, gotPayload.ResultURL)
+
+	assert.Len(t, storage.updated, 1)
+	assert.Equal(t, domain.DeliveryDelivered, storage.updated[0].Status)
+	assert.Equal(t, 1, storage.updated[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, storage.updated[0].LastStatusCode)
+}
+
+func TestDispatchPending_RetryThenFail(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	storage := &fakeStorage{pending: []domain.WebhookDelivery{newDelivery(t, server.URL)}}
+	d := NewDispatcher(storage, testConfig())
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		d.DispatchPending(context.Background())
+
+		last := storage.updated[len(storage.updated)-1]
+		assert.Equal(t, attempt, last.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, last.LastStatusCode)
+		assert.NotEmpty(t, last.LastError)
+		if attempt < 3 {
+			assert.Equal(t, domain.DeliveryPending, last.Status)
+			assert.True(t, last.NextAttemptAt.After(before))
+		} else {
+			assert.Equal(t, domain.DeliveryFailed, last.Status)
+		}
+		storage.pending = []domain.WebhookDelivery{last}
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDispatchPending_ForbiddenAddress(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.WebhookConfig.Allowlist = nil
+	storage := &fakeStorage{pending: []domain.WebhookDelivery{newDelivery(t, server.URL)}}
+	NewDispatcher(storage, cfg).DispatchPending(context.Background())
+
+	// loopback не в allowlist: запрос не отправлен, и повторять его бессмысленно
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	assert.Len(t, storage.updated, 1)
+	assert.Equal(t, domain.DeliveryFailed, storage.updated[0].Status)
+	assert.Contains(t, storage.updated[0].LastError, "not allowed")
+}
+
+func TestDispatchPending_RedirectNotFollowed(t *testing.T) {
+	var internalCalls int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&internalCalls, 1)
+	}))
+	defer internal.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	storage := &fakeStorage{pending: []domain.WebhookDelivery{newDelivery(t, server.URL)}}
+	NewDispatcher(storage, testConfig()).DispatchPending(context.Background())
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&internalCalls))
+	assert.Len(t, storage.updated, 1)
+	assert.Equal(t, http.StatusTemporaryRedirect, storage.updated[0].LastStatusCode)
+	assert.Equal(t, domain.DeliveryPending, storage.updated[0].Status)
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(&fakeStorage{}, testConfig())
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 3*time.Second, d.backoff(3))
+	assert.Equal(t, 3*time.Second, d.backoff(10))
+}
@@ -0,0 +1,4 @@
+UPDATE images SET status = 'failed' WHERE status = 'cancelled';
+ALTER TABLE images DROP CONSTRAINT IF EXISTS images_status_check;
+ALTER TABLE images ADD CONSTRAINT images_status_check
+    CHECK (status IN ('created', 'processing', 'processed', 'failed', 'deleted'));
@@ -0,0 +1,2 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'interactive'
+    CHECK (priority IN ('interactive', 'bulk'));
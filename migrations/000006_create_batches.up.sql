@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS batches (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    total INT NOT NULL CHECK (total >= 0)
+);
+
+ALTER TABLE images ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES batches(id);
+CREATE INDEX IF NOT EXISTS images_batch_id_idx ON images (batch_id) WHERE batch_id IS NOT NULL;
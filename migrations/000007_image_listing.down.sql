@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS images_tags_idx;
+DROP INDEX IF EXISTS images_owner_created_at_id_idx;
+DROP INDEX IF EXISTS images_status_created_at_id_idx;
+DROP INDEX IF EXISTS images_created_at_id_idx;
+
+ALTER TABLE images DROP COLUMN IF EXISTS owner;
+ALTER TABLE images DROP COLUMN IF EXISTS tags;
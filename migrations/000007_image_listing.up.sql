@@ -0,0 +1,8 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE images ADD COLUMN IF NOT EXISTS owner TEXT CHECK (char_length(owner) <= 100);
+
+-- keyset-пагинация по (created_at, id) в обе стороны
+CREATE INDEX IF NOT EXISTS images_created_at_id_idx ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_status_created_at_id_idx ON images (status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_owner_created_at_id_idx ON images (owner, created_at DESC, id DESC) WHERE owner IS NOT NULL;
+CREATE INDEX IF NOT EXISTS images_tags_idx ON images USING GIN (tags);
@@ -0,0 +1,10 @@
+ALTER TABLE images
+    DROP COLUMN IF EXISTS source_width,
+    DROP COLUMN IF EXISTS source_height,
+    DROP COLUMN IF EXISTS source_size,
+    DROP COLUMN IF EXISTS output_width,
+    DROP COLUMN IF EXISTS output_height,
+    DROP COLUMN IF EXISTS output_size,
+    DROP COLUMN IF EXISTS error_reason,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS processed_at;
@@ -0,0 +1,10 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS source_width INT,
+    ADD COLUMN IF NOT EXISTS source_height INT,
+    ADD COLUMN IF NOT EXISTS source_size BIGINT,
+    ADD COLUMN IF NOT EXISTS output_width INT,
+    ADD COLUMN IF NOT EXISTS output_height INT,
+    ADD COLUMN IF NOT EXISTS output_size BIGINT,
+    ADD COLUMN IF NOT EXISTS error_reason TEXT,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
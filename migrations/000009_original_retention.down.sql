@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS images_original_retention_idx;
+
+ALTER TABLE images DROP COLUMN IF EXISTS original_deleted_at;
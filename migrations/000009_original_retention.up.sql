@@ -0,0 +1,4 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS original_deleted_at TIMESTAMPTZ;
+
+-- janitor выбирает только изображения, исходник которых ещё на диске
+CREATE INDEX IF NOT EXISTS images_original_retention_idx ON images (status, created_at) WHERE original_deleted_at IS NULL;
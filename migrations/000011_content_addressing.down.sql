@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS images_source_key_idx;
+DROP INDEX IF EXISTS images_source_sha256_processed_idx;
+ALTER TABLE images DROP COLUMN IF EXISTS output_key;
+ALTER TABLE images DROP COLUMN IF EXISTS source_key;
+ALTER TABLE images DROP COLUMN IF EXISTS source_sha256;
+ALTER TABLE images DROP COLUMN IF EXISTS mini;
@@ -0,0 +1,9 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS mini BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS source_sha256 TEXT;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS source_key TEXT;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS output_key TEXT;
+
+-- поиск готового результата для того же исходника при повторной загрузке
+CREATE INDEX IF NOT EXISTS images_source_sha256_processed_idx ON images (source_sha256) WHERE status = 'processed' AND output_key IS NOT NULL;
+-- проверка, ссылается ли ещё кто-то на общий исходник, перед его удалением
+CREATE INDEX IF NOT EXISTS images_source_key_idx ON images (source_key) WHERE original_deleted_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS images_output_key_idx;
+DROP INDEX IF EXISTS images_trash_idx;
+ALTER TABLE images DROP COLUMN IF EXISTS deleted_from;
+ALTER TABLE images DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,10 @@
+ALTER TABLE images ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS deleted_from TEXT;
+
+-- у удалённых раньше изображений срок корзины отсчитывается от последнего изменения
+UPDATE images SET deleted_at = COALESCE(updated_at, created_at) WHERE status = 'deleted' AND deleted_at IS NULL;
+
+-- очистка корзины выбирает изображения с истёкшим сроком
+CREATE INDEX IF NOT EXISTS images_trash_idx ON images (deleted_at) WHERE status = 'deleted';
+-- проверка, ссылается ли ещё кто-то на общий результат, перед его удалением
+CREATE INDEX IF NOT EXISTS images_output_key_idx ON images (output_key) WHERE output_key IS NOT NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS images_name_idx;
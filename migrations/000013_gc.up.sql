@@ -0,0 +1,2 @@
+-- сверка хранилища ищет записи по ключу файла; файлы, загруженные до адресации по содержимому, лежат под name
+CREATE INDEX IF NOT EXISTS images_name_idx ON images (name);
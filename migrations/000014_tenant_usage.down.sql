@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS images_tenant_usage_update ON images;
+DROP TRIGGER IF EXISTS images_tenant_usage_insert_delete ON images;
+DROP FUNCTION IF EXISTS images_tenant_usage();
+DROP TABLE IF EXISTS tenant_usage;
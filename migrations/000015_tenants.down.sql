@@ -0,0 +1,69 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION images_tenant_usage() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        INSERT INTO tenant_usage AS u (tenant, images, original_bytes, processed_bytes)
+        VALUES (
+            COALESCE(OLD.owner, ''),
+            -1,
+            -CASE WHEN OLD.original_deleted_at IS NULL THEN COALESCE(OLD.source_size, 0) ELSE 0 END,
+            -COALESCE(OLD.output_size, 0)
+        )
+        ON CONFLICT (tenant) DO UPDATE SET
+            images = u.images + EXCLUDED.images,
+            original_bytes = u.original_bytes + EXCLUDED.original_bytes,
+            processed_bytes = u.processed_bytes + EXCLUDED.processed_bytes,
+            updated_at = now();
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO tenant_usage AS u (tenant, images, original_bytes, processed_bytes)
+        VALUES (
+            COALESCE(NEW.owner, ''),
+            1,
+            CASE WHEN NEW.original_deleted_at IS NULL THEN COALESCE(NEW.source_size, 0) ELSE 0 END,
+            COALESCE(NEW.output_size, 0)
+        )
+        ON CONFLICT (tenant) DO UPDATE SET
+            images = u.images + EXCLUDED.images,
+            original_bytes = u.original_bytes + EXCLUDED.original_bytes,
+            processed_bytes = u.processed_bytes + EXCLUDED.processed_bytes,
+            updated_at = now();
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+LOCK TABLE images IN SHARE ROW EXCLUSIVE MODE;
+
+DROP TRIGGER IF EXISTS images_tenant_usage_update ON images;
+CREATE TRIGGER images_tenant_usage_update
+    AFTER UPDATE OF owner, source_size, output_size, original_deleted_at ON images
+    FOR EACH ROW
+    WHEN (OLD.owner IS DISTINCT FROM NEW.owner
+        OR OLD.source_size IS DISTINCT FROM NEW.source_size
+        OR OLD.output_size IS DISTINCT FROM NEW.output_size
+        OR (OLD.original_deleted_at IS NULL) <> (NEW.original_deleted_at IS NULL))
+    EXECUTE FUNCTION images_tenant_usage();
+
+TRUNCATE tenant_usage;
+INSERT INTO tenant_usage (tenant, images, original_bytes, processed_bytes)
+SELECT
+    COALESCE(owner, ''),
+    count(*),
+    COALESCE(sum(source_size) FILTER (WHERE original_deleted_at IS NULL), 0),
+    COALESCE(sum(output_size), 0)
+FROM images
+GROUP BY COALESCE(owner, '');
+
+DROP INDEX IF EXISTS images_tenant_owner_created_at_id_idx;
+DROP INDEX IF EXISTS images_tenant_status_created_at_id_idx;
+DROP INDEX IF EXISTS images_tenant_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS images_created_at_id_idx ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_status_created_at_id_idx ON images (status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_owner_created_at_id_idx ON images (owner, created_at DESC, id DESC) WHERE owner IS NOT NULL;
+
+ALTER TABLE batches DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE images DROP COLUMN IF EXISTS tenant_id;
+
+COMMIT;
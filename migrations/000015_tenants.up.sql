@@ -0,0 +1,73 @@
+-- изоляция арендаторов: каждое изображение и пакет принадлежат арендатору, '' — арендатор по умолчанию
+-- для сервиса без аутентификации и записей, созданных до появления арендаторов
+BEGIN;
+
+ALTER TABLE images ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+-- все запросы API фильтруют по арендатору, поэтому он идёт первым в индексах выборки
+DROP INDEX IF EXISTS images_created_at_id_idx;
+DROP INDEX IF EXISTS images_status_created_at_id_idx;
+DROP INDEX IF EXISTS images_owner_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS images_tenant_created_at_id_idx ON images (tenant_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_tenant_status_created_at_id_idx ON images (tenant_id, status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS images_tenant_owner_created_at_id_idx ON images (tenant_id, owner, created_at DESC, id DESC) WHERE owner IS NOT NULL;
+
+-- квоты и счётчики использования теперь ведутся по арендатору, а не по метке owner
+CREATE OR REPLACE FUNCTION images_tenant_usage() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        INSERT INTO tenant_usage AS u (tenant, images, original_bytes, processed_bytes)
+        VALUES (
+            OLD.tenant_id,
+            -1,
+            -CASE WHEN OLD.original_deleted_at IS NULL THEN COALESCE(OLD.source_size, 0) ELSE 0 END,
+            -COALESCE(OLD.output_size, 0)
+        )
+        ON CONFLICT (tenant) DO UPDATE SET
+            images = u.images + EXCLUDED.images,
+            original_bytes = u.original_bytes + EXCLUDED.original_bytes,
+            processed_bytes = u.processed_bytes + EXCLUDED.processed_bytes,
+            updated_at = now();
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO tenant_usage AS u (tenant, images, original_bytes, processed_bytes)
+        VALUES (
+            NEW.tenant_id,
+            1,
+            CASE WHEN NEW.original_deleted_at IS NULL THEN COALESCE(NEW.source_size, 0) ELSE 0 END,
+            COALESCE(NEW.output_size, 0)
+        )
+        ON CONFLICT (tenant) DO UPDATE SET
+            images = u.images + EXCLUDED.images,
+            original_bytes = u.original_bytes + EXCLUDED.original_bytes,
+            processed_bytes = u.processed_bytes + EXCLUDED.processed_bytes,
+            updated_at = now();
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+LOCK TABLE images IN SHARE ROW EXCLUSIVE MODE;
+
+DROP TRIGGER IF EXISTS images_tenant_usage_update ON images;
+CREATE TRIGGER images_tenant_usage_update
+    AFTER UPDATE OF tenant_id, source_size, output_size, original_deleted_at ON images
+    FOR EACH ROW
+    WHEN (OLD.tenant_id IS DISTINCT FROM NEW.tenant_id
+        OR OLD.source_size IS DISTINCT FROM NEW.source_size
+        OR OLD.output_size IS DISTINCT FROM NEW.output_size
+        OR (OLD.original_deleted_at IS NULL) <> (NEW.original_deleted_at IS NULL))
+    EXECUTE FUNCTION images_tenant_usage();
+
+TRUNCATE tenant_usage;
+INSERT INTO tenant_usage (tenant, images, original_bytes, processed_bytes)
+SELECT
+    tenant_id,
+    count(*),
+    COALESCE(sum(source_size) FILTER (WHERE original_deleted_at IS NULL), 0),
+    COALESCE(sum(output_size), 0)
+FROM images
+GROUP BY tenant_id;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- token bucket'ы ограничения частоты запросов, общие для реплик (rate_limit.store: postgres).
+-- Счётчики восстанавливаются сами, поэтому таблица не журналируется
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- очистка наполнившихся bucket'ов
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at_idx ON rate_limits (updated_at);